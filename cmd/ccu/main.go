@@ -17,6 +17,8 @@ import (
 	"github.com/sammcj/ccu/internal/data"
 	"github.com/sammcj/ccu/internal/modelcheck"
 	"github.com/sammcj/ccu/internal/models"
+	"github.com/sammcj/ccu/internal/mqtt"
+	"github.com/sammcj/ccu/internal/pricing"
 	"github.com/sammcj/ccu/internal/ui"
 )
 
@@ -28,6 +30,12 @@ var (
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run is main's body. It returns the exit code rather than calling os.Exit,
+// so deferred cleanup such as saving the parse cache runs on every path.
+func run() int {
 	// Set version info in config package for --version flag
 	config.Version = Version
 	config.Commit = Commit
@@ -37,23 +45,67 @@ func main() {
 	cfg, err := config.ParseFlags()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return 1
+	}
+
+	// Merge user model and pricing files before anything names or prices a
+	// model. Models load first: pricing file keys are checked against them.
+	if cfg.ModelsFile != "" {
+		if err := models.LoadRegistryFile(cfg.ModelsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if cfg.PricingFile != "" {
+		if err := pricing.LoadFile(cfg.PricingFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
 	}
 
 	// Handle model table check (non-interactive, exits with status code)
 	if cfg.CheckModels {
-		os.Exit(runModelCheck())
+		return runModelCheck(cfg.ModelCheck)
+	}
+
+	// Handle transcript diagnostics (non-interactive, exits with status code)
+	if cfg.Doctor {
+		return runDoctor(cfg)
+	}
+
+	// Reuse parse results from earlier runs, saving any left unsaved on exit
+	if cfg.ParseCache {
+		if path, err := data.ParseCachePath(); err != nil {
+			log.Printf("Parse cache disabled: %v", err)
+		} else {
+			data.UseParseCache(path)
+			defer func() {
+				if err := data.SaveParseCache(); err != nil {
+					log.Printf("%v", err)
+				}
+			}()
+		}
 	}
 
 	// Handle report mode (non-interactive output to stdout)
 	if cfg.ReportMode != models.ReportModeNone {
-		runReport(cfg)
-		return
+		return runReport(cfg)
 	}
 
 	// Create application model
 	model := app.NewModel(cfg)
 
+	// Watch the JSONL files so new usage shows within a second. The polling
+	// fallback rescans for new files at the refresh rate.
+	if cfg.Watch {
+		if watcher, err := startWatcher(cfg); err != nil {
+			log.Printf("JSONL watcher disabled: %v", err)
+		} else {
+			defer watcher.Close()
+			model.SetWatcher(watcher)
+		}
+	}
+
 	// Start optional API server
 	var (
 		apiServer *api.Server
@@ -74,17 +126,43 @@ func main() {
 		}()
 	}
 
-	// shutdownAPI stops the API server (if running) and waits up to 5s for it to
-	// drain, so in-flight requests finish before the process exits. Safe to call
-	// on both the success and error paths; a no-op when the server is disabled.
+	// Start optional MQTT publisher. An unreachable broker is retried in the
+	// background rather than failing startup.
+	var (
+		mqttPublisher *mqtt.Publisher
+		mqttCancel    context.CancelFunc
+	)
+	if cfg.MQTT.Broker != "" {
+		mqttPublisher = mqtt.New(cfg.MQTT, Version)
+		model.SetMQTTPublisher(mqttPublisher)
+		var ctx context.Context
+		ctx, mqttCancel = context.WithCancel(context.Background())
+		go func() {
+			if err := mqttPublisher.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("MQTT publisher error: %v", err)
+			}
+		}()
+	}
+
+	// shutdownAPI stops the API server and MQTT publisher (if running) and waits
+	// up to 5s for them to drain, so in-flight requests finish and the sensors
+	// are marked offline before the process exits. Safe to call on both the
+	// success and error paths; a no-op when neither is enabled.
 	shutdownAPI := func() {
-		if apiCancel == nil {
-			return
+		deadline := time.Now().Add(5 * time.Second)
+		if apiCancel != nil {
+			apiCancel()
+			select {
+			case <-apiServer.Done():
+			case <-time.After(time.Until(deadline)):
+			}
 		}
-		apiCancel()
-		select {
-		case <-apiServer.Done():
-		case <-time.After(5 * time.Second):
+		if mqttCancel != nil {
+			mqttCancel()
+			select {
+			case <-mqttPublisher.Done():
+			case <-time.After(time.Until(deadline)):
+			}
 		}
 	}
 
@@ -109,18 +187,38 @@ func main() {
 	if _, err := p.Run(); err != nil {
 		shutdownAPI()
 		fmt.Fprintf(os.Stderr, "Error running application: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
 	shutdownAPI()
+	return 0
+}
+
+// startWatcher watches the configured data paths, or every standard location
+// whether or not it exists yet, so one Claude Code creates later is watched
+// too
+func startWatcher(cfg *models.Config) (*data.Watcher, error) {
+	roots := cfg.DataPaths
+	if len(roots) == 0 {
+		var err error
+		if roots, err = data.CandidateDataPaths(); err != nil {
+			return nil, err
+		}
+	}
+	watcher, err := data.Watch(roots, cfg.RefreshRate)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Watching %v for changes (%s)", roots, watcher.Mode())
+	return watcher, nil
 }
 
 // runModelCheck compares ccu's model tables against upstream pricing.
-// Returns 0 when in sync, 1 when drift was found, 2 on fetch/parse errors.
-func runModelCheck() int {
-	data, err := modelcheck.FetchUpstream(context.Background(), modelcheck.UpstreamURL)
+// Returns 0 when in sync, 1 when drift was found, 2 on fetch/parse/write errors.
+func runModelCheck(mc models.ModelCheckConfig) int {
+	data, err := modelcheck.LoadUpstream(context.Background(), mc.Source)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error fetching upstream pricing: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error loading upstream pricing: %v\n", err)
 		return 2
 	}
 
@@ -130,25 +228,64 @@ func runModelCheck() int {
 		return 2
 	}
 
-	fmt.Print(report.Format())
+	if mc.Format == "json" {
+		out, err := report.JSON()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding report: %v\n", err)
+			return 2
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Print(report.Format())
+	}
+
+	if mc.WritePath != "" {
+		// Stderr keeps JSON on stdout parseable
+		n, err := report.WriteOverrides(mc.WritePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		if n > 0 {
+			fmt.Fprintf(os.Stderr, "Wrote upstream rates for %d model(s) to %s\n", n, mc.WritePath)
+		}
+	}
+
 	if len(report.Findings) > 0 {
 		return 1
 	}
 	return 0
 }
 
-// runReport generates a static report and outputs to stdout
-func runReport(cfg *models.Config) {
+// runDoctor scans the data directories for lines ccu can't parse or doesn't
+// recognise. Returns 0 when everything is understood, 1 when something was
+// found, 2 when the data directories can't be read.
+func runDoctor(cfg *models.Config) int {
+	diagnosis, err := data.Diagnose(cfg.DataPaths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error scanning data: %v\n", err)
+		return 2
+	}
+	fmt.Print(diagnosis.Format())
+	if !diagnosis.OK() {
+		return 1
+	}
+	return 0
+}
+
+// runReport generates a static report and outputs to stdout. Returns 0 on
+// success and 1 when the data can't be loaded.
+func runReport(cfg *models.Config) int {
 	// Load usage data
-	entries, err := data.LoadUsageData(cfg.DataPath, cfg.HoursBack)
+	entries, err := data.LoadUsageData(cfg.DataPaths, cfg.HoursBack)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading data: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
 	if len(entries) == 0 {
 		fmt.Println("No usage data found.")
-		return
+		return 0
 	}
 
 	// Generate report based on mode
@@ -163,4 +300,5 @@ func runReport(cfg *models.Config) {
 	}
 
 	fmt.Print(report)
+	return 0
 }
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sammcj/ccu/internal/ui"
+)
+
+// Badge colours, as shields.io uses them
+const (
+	badgeLabelColour   = "#555"
+	badgeUnknownColour = "#9F9F9F"
+	badgeCostColour    = "#007EC6"
+)
+
+// badgeMetrics are the values a badge can show, by the name in its path
+var badgeMetrics = map[string]func(d DisplayResponse) (label, value, colour string){
+	"session": func(d DisplayResponse) (string, string, string) {
+		return "session", formatPct(d.SessionPct), string(ui.GetPercentageColour(d.SessionPct))
+	},
+	"weekly": func(d DisplayResponse) (string, string, string) {
+		if d.WeeklyPct < 0 {
+			return "weekly", "n/a", badgeUnknownColour
+		}
+		return "weekly", formatPct(d.WeeklyPct), string(ui.GetPercentageColour(d.WeeklyPct))
+	},
+	"today": func(d DisplayResponse) (string, string, string) {
+		if d.TodayCostUSD < 0 {
+			return "today", "n/a", badgeUnknownColour
+		}
+		return "today", fmt.Sprintf("$%.2f", d.TodayCostUSD), badgeCostColour
+	},
+}
+
+// handleBadge serves /api/v1/badge/{metric}.svg, a shields-style badge of one
+// value from the display summary. ?label= replaces the left-hand text.
+func (s *Server) handleBadge(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(r.PathValue("file"), ".svg")
+	metric, known := badgeMetrics[name]
+	if !ok || !known {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown badge %q: use %s.svg", r.PathValue("file"), strings.Join(slices.Sorted(maps.Keys(badgeMetrics)), ".svg, ")))
+		return
+	}
+
+	s.mu.RLock()
+	d, at := s.displayData, s.display.at
+	s.mu.RUnlock()
+	if at.IsZero() {
+		writeError(w, http.StatusServiceUnavailable, "no data")
+		return
+	}
+
+	label, value, colour := metric(d)
+	if l := r.URL.Query().Get("label"); l != "" {
+		label = l
+	}
+	svg := badgeSVG(label, value, colour)
+	snapshot{data: svg, etag: snapshotETag(svg), at: at}.serve(w, r, "image/svg+xml")
+}
+
+// badgeSVG renders a flat shields-style badge. Text widths are estimated from
+// Verdana's metrics, as shields does, since no font is available to measure.
+func badgeSVG(label, value, colour string) []byte {
+	const pad = 10
+	lw := int(math.Round(textWidth(label))) + pad
+	vw := int(math.Round(textWidth(value))) + pad
+	total := lw + vw
+
+	valueText := "#fff"
+	if luminance(colour) > 0.6 {
+		valueText = "#333" // light backgrounds need dark text
+	}
+	title := xmlEscape(label + ": " + value)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s">`, total, title)
+	fmt.Fprintf(&b, `<title>%s</title>`, title)
+	b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, total)
+	fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="%s"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`,
+		lw, badgeLabelColour, lw, vw, xmlEscape(colour), total)
+	b.WriteString(`<g text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	fmt.Fprintf(&b, `<text x="%s" y="14" fill="#fff">%s</text>`, half(lw), xmlEscape(label))
+	fmt.Fprintf(&b, `<text x="%s" y="14" fill="%s">%s</text>`, half(2*lw+vw), valueText, xmlEscape(value))
+	b.WriteString(`</g></svg>`)
+	return []byte(b.String())
+}
+
+func half(n int) string {
+	return strconv.FormatFloat(float64(n)/2, 'f', -1, 64)
+}
+
+// textWidth estimates the width in pixels of s in 11px Verdana
+func textWidth(s string) float64 {
+	var w float64
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("iljt.,:;'|!", r):
+			w += 3.6
+		case strings.ContainsRune(" frI()[]/-", r):
+			w += 4.6
+		case r == '%' || r == 'm' || r == 'w' || r == 'M' || r == 'W':
+			w += 10.5
+		case r >= 'A' && r <= 'Z':
+			w += 7.6
+		default:
+			w += 6.9
+		}
+	}
+	return w
+}
+
+// luminance returns the relative luminance (0-1) of a "#RRGGBB" colour
+func luminance(hex string) float64 {
+	r, g, b, ok := parseHex(hex)
+	if !ok {
+		return 0
+	}
+	return (0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)) / 255
+}
+
+// parseHex splits a "#RRGGBB" colour into its components
+func parseHex(hex string) (r, g, b uint8, ok bool) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(hex, "#")) != 6 {
+		return 0, 0, 0, false
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v), true
+}
+
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;").Replace(s)
+}
+
+// formatPct formats a percentage with at most one decimal place
+func formatPct(pct float64) string {
+	return strconv.FormatFloat(roundTo(pct, 1), 'f', -1, 64) + "%"
+}
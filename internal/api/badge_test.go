@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgeMetrics(t *testing.T) {
+	d := DisplayResponse{SessionPct: 42.46, WeeklyPct: -1, TodayCostUSD: 12.345}
+	tests := []struct {
+		metric, label, value, colour string
+	}{
+		{"session", "session", "42.5%", "#E0FF28"},
+		{"weekly", "weekly", "n/a", badgeUnknownColour},
+		{"today", "today", "$12.35", badgeCostColour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			label, value, colour := badgeMetrics[tt.metric](d)
+			assert.Equal(t, tt.label, label)
+			assert.Equal(t, tt.value, value)
+			assert.Equal(t, tt.colour, colour)
+		})
+	}
+}
+
+func TestBadgeSVG(t *testing.T) {
+	svg := string(badgeSVG(`<a&b>`, "42%", "#00FF88"))
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg"`))
+	assert.Contains(t, svg, "<title>&lt;a&amp;b&gt;: 42%</title>")
+	assert.NotContains(t, svg, "<a&b>", "text is escaped")
+	assert.Contains(t, svg, `fill="#00FF88"`)
+	assert.Contains(t, svg, `fill="#333">42%</text>`, "light backgrounds get dark text")
+
+	assert.Contains(t, string(badgeSVG("today", "$1.00", badgeCostColour)), `fill="#fff">$1.00</text>`)
+	assert.Greater(t, textWidth("session"), textWidth("today"))
+}
+
+func TestServer_Badge(t *testing.T) {
+	s := newTestServer(models.APIConfig{})
+	mux := s.handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, get("/api/v1/badge/session.svg").Code)
+
+	s.UpdateDisplay(DisplayResponse{SchemaVersion: 1, SessionPct: 12, WeeklyPct: 30, TodayCostUSD: 4.5})
+
+	rr := get("/api/v1/badge/session.svg")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/svg+xml", rr.Header().Get("Content-Type"))
+	assert.NotEmpty(t, rr.Header().Get("ETag"))
+	assert.Contains(t, rr.Body.String(), "session: 12%")
+
+	rr = get("/api/v1/badge/today.svg?label=claude")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "claude: $4.50")
+
+	for _, path := range []string{"/api/v1/badge/monthly.svg", "/api/v1/badge/session.png", "/api/v1/badge/session"} {
+		rr = get(path)
+		assert.Equal(t, http.StatusNotFound, rr.Code, path)
+		assert.Contains(t, rr.Body.String(), "session.svg, today.svg, weekly.svg", path)
+	}
+}
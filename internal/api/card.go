@@ -0,0 +1,322 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/sammcj/ccu/internal/ui"
+)
+
+// Card size limits and defaults. The default suits an 800x480 e-ink panel.
+const (
+	cardDefaultWidth  = 800
+	cardDefaultHeight = 480
+	cardMinSize       = 64
+	cardMaxSize       = 2048
+	cardCacheSize     = 16 // renders kept per display snapshot
+)
+
+// Card palettes
+const (
+	paletteColour = "colour" // dark background, the dashboard's gradients
+	paletteGrey   = "grey"   // the colour card in greyscale
+	palette1Bit   = "1bit"   // black on white, two colours, for e-ink
+)
+
+// cardScheme is the colours a card is drawn in. barColour picks a bar's fill
+// from its percentage and gradient.
+type cardScheme struct {
+	background, text, muted, track color.Color
+	barColour                      func(pct float64, gradient func(float64) string) color.Color
+}
+
+var cardSchemes = map[string]cardScheme{
+	paletteColour: {
+		background: hexColour("#12121C"),
+		text:       hexColour(string(ui.ColorText)),
+		muted:      hexColour(string(ui.ColorMuted)),
+		track:      hexColour("#2A2A3A"),
+		barColour: func(pct float64, gradient func(float64) string) color.Color {
+			return hexColour(gradient(pct))
+		},
+	},
+	palette1Bit: {
+		background: color.White,
+		text:       color.Black,
+		muted:      color.Black,
+		track:      color.White,
+		barColour:  func(float64, func(float64) string) color.Color { return color.Black },
+	},
+}
+
+// cardRequest is a parsed /api/v1/card.png query
+type cardRequest struct {
+	width, height int
+	palette       string
+}
+
+// parseCardRequest reads width, height and palette from the query
+func parseCardRequest(r *http.Request) (cardRequest, error) {
+	q := r.URL.Query()
+	req := cardRequest{width: cardDefaultWidth, height: cardDefaultHeight, palette: paletteColour}
+	for _, dim := range []struct {
+		name string
+		dst  *int
+	}{{"width", &req.width}, {"height", &req.height}} {
+		v := q.Get(dim.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < cardMinSize || n > cardMaxSize {
+			return req, fmt.Errorf("invalid %s %q: must be %d to %d", dim.name, v, cardMinSize, cardMaxSize)
+		}
+		*dim.dst = n
+	}
+	switch p := q.Get("palette"); p {
+	case "":
+	case paletteColour, paletteGrey, palette1Bit:
+		req.palette = p
+	default:
+		return req, fmt.Errorf("invalid palette %q: use %s, %s or %s", p, paletteColour, paletteGrey, palette1Bit)
+	}
+	return req, nil
+}
+
+// handleCard serves /api/v1/card.png, the dashboard's key bars rendered from
+// the display summary at the requested size and palette. Renders are cached
+// until the next refresh.
+func (s *Server) handleCard(w http.ResponseWriter, r *http.Request) {
+	req, err := parseCardRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.RLock()
+	d, at, cached := s.displayData, s.display.at, s.cards[req]
+	s.mu.RUnlock()
+	if at.IsZero() {
+		writeError(w, http.StatusServiceUnavailable, "no data")
+		return
+	}
+
+	if cached.data == nil {
+		data, err := renderCard(d, req)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "rendering card")
+			return
+		}
+		cached = snapshot{data: data, etag: snapshotETag(data), at: at}
+		s.mu.Lock()
+		// Keep the render only if the display hasn't moved on meanwhile
+		if s.display.at.Equal(at) && len(s.cards) < cardCacheSize {
+			s.cards[req] = cached
+		}
+		s.mu.Unlock()
+	}
+	cached.serve(w, r, "image/png")
+}
+
+// cardRow is one labelled bar on the card
+type cardRow struct {
+	label, value string
+	pct          float64
+	gradient     func(float64) string
+}
+
+// cardRows returns the bars to draw: session usage and time left, then weekly
+// limits when OAuth reports them
+func cardRows(d DisplayResponse) []cardRow {
+	percent := func(pct float64) string { return string(ui.GetPercentageColour(pct)) }
+	remaining := func(pct float64) string { return string(ui.GetTimeRemainingColour(pct)) }
+
+	rows := []cardRow{
+		{label: "Session", value: formatPct(d.SessionPct), pct: d.SessionPct, gradient: percent},
+		{
+			label:    "Session reset",
+			value:    "in " + formatCountdown(d.SessionResetSecs),
+			pct:      float64(d.SessionResetSecs) / (5 * 3600) * 100,
+			gradient: remaining,
+		},
+	}
+	if d.WeeklyPct >= 0 {
+		rows = append(rows, cardRow{
+			label: "Weekly", value: formatPct(d.WeeklyPct) + " · resets in " + formatCountdown(d.WeeklyResetSecs),
+			pct: d.WeeklyPct, gradient: percent,
+		})
+	}
+	if d.ScopedPct >= 0 {
+		row := cardRow{label: "Weekly " + d.ScopedModel, value: formatPct(d.ScopedPct), pct: d.ScopedPct, gradient: percent}
+		if d.ScopedResetSecs >= 0 {
+			row.value += " · resets in " + formatCountdown(d.ScopedResetSecs)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// renderCard draws the card and encodes it as PNG
+func renderCard(d DisplayResponse, req cardRequest) ([]byte, error) {
+	scheme := cardSchemes[req.palette]
+	if req.palette == paletteGrey {
+		scheme = cardSchemes[paletteColour]
+	}
+	fonts, err := loadCardFonts()
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := req.width, req.height
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(scheme.background), image.Point{}, draw.Src)
+
+	pad := max(h/24, 2)
+	headerH, footerH := h/8, h/8
+
+	// Header: title left, plan and update time right
+	title := face(fonts.bold, float64(headerH)*0.6)
+	baseline := pad + headerH*3/4
+	drawText(img, title, scheme.text, pad, baseline, "Claude usage")
+	updated := time.Unix(d.UpdatedEpoch, 0).Format("15:04")
+	drawTextRight(img, face(fonts.regular, float64(headerH)*0.45), scheme.muted, w-pad, baseline, fmt.Sprintf("%s · %s", upper(d.Plan), updated))
+
+	// Bars
+	rows := cardRows(d)
+	rowH := (h - 2*pad - headerH - footerH) / len(rows)
+	labelFace := face(fonts.regular, float64(rowH)*0.3)
+	valueFace := face(fonts.bold, float64(rowH)*0.3)
+	for i, row := range rows {
+		top := pad + headerH + i*rowH
+		textBase := top + rowH*2/5
+		drawText(img, labelFace, scheme.text, pad, textBase, row.label)
+		drawTextRight(img, valueFace, scheme.text, w-pad, textBase, row.value)
+
+		barTop, barBottom := top+rowH/2, top+rowH*17/20
+		bar := image.Rect(pad, barTop, w-pad, max(barBottom, barTop+2))
+		drawBar(img, bar, row.pct, scheme.track, scheme.text, scheme.barColour(row.pct, row.gradient))
+	}
+
+	// Footer: costs
+	footer := fmt.Sprintf("Session $%.2f   Burn $%.2f/h", d.SessionCostUSD, d.BurnUSDPerHour)
+	if d.TodayCostUSD >= 0 {
+		footer = fmt.Sprintf("Today $%.2f   ", d.TodayCostUSD) + footer
+	}
+	drawText(img, face(fonts.regular, float64(footerH)*0.5), scheme.muted, pad, h-pad-footerH/4, footer)
+
+	var out image.Image = img
+	switch req.palette {
+	case paletteGrey:
+		gray := image.NewGray(img.Bounds())
+		draw.Draw(gray, gray.Bounds(), img, image.Point{}, draw.Src)
+		out = gray
+	case palette1Bit:
+		out = threshold(img)
+	}
+
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&buf, out); err != nil {
+		return nil, fmt.Errorf("encoding card: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawBar draws a bar's track and outline, then fills it to pct
+func drawBar(img draw.Image, r image.Rectangle, pct float64, track, outline, fill color.Color) {
+	draw.Draw(img, r, image.NewUniform(outline), image.Point{}, draw.Src)
+	inner := r.Inset(1)
+	draw.Draw(img, inner, image.NewUniform(track), image.Point{}, draw.Src)
+	filled := int(float64(inner.Dx()) * min(max(pct, 0), 100) / 100)
+	if filled > 0 {
+		draw.Draw(img, image.Rect(inner.Min.X, inner.Min.Y, inner.Min.X+filled, inner.Max.Y), image.NewUniform(fill), image.Point{}, draw.Src)
+	}
+}
+
+// threshold reduces an image to a two-colour palette, which PNG stores at one
+// bit per pixel
+func threshold(img image.Image) *image.Paletted {
+	b := img.Bounds()
+	out := image.NewPaletted(b, color.Palette{color.Black, color.White})
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y >= 128 {
+				out.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	return out
+}
+
+// cardFonts are the parsed Go fonts used to draw cards
+type cardFonts struct {
+	regular, bold *opentype.Font
+}
+
+var loadCardFonts = sync.OnceValues(func() (cardFonts, error) {
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return cardFonts{}, fmt.Errorf("parsing font: %w", err)
+	}
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return cardFonts{}, fmt.Errorf("parsing font: %w", err)
+	}
+	return cardFonts{regular: regular, bold: bold}, nil
+})
+
+// face returns f at size pixels, at least 6 so tiny cards stay legible-ish
+func face(f *opentype.Font, size float64) font.Face {
+	fc, err := opentype.NewFace(f, &opentype.FaceOptions{Size: max(size, 6), DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		// NewFace only fails on invalid options, which these never are
+		panic(err)
+	}
+	return fc
+}
+
+func drawText(img draw.Image, f font.Face, c color.Color, x, baseline int, s string) {
+	d := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: f, Dot: fixed.P(x, baseline)}
+	d.DrawString(s)
+}
+
+func drawTextRight(img draw.Image, f font.Face, c color.Color, right, baseline int, s string) {
+	width := font.MeasureString(f, s).Ceil()
+	drawText(img, f, c, right-width, baseline, s)
+}
+
+// formatCountdown formats seconds as "2d 4h", "3h 5m" or "12m"
+func formatCountdown(secs int64) string {
+	d := time.Duration(max(secs, 0)) * time.Second
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+}
+
+func hexColour(hex string) color.Color {
+	r, g, b, _ := parseHex(hex)
+	return color.RGBA{R: r, G: g, B: b, A: 0xff}
+}
+
+func upper(s string) string {
+	return string(bytes.ToUpper([]byte(s)))
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCardDisplay() DisplayResponse {
+	return DisplayResponse{
+		SchemaVersion: 1, Plan: "max5",
+		SessionPct: 45, SessionResetSecs: 3 * 3600, SessionCostUSD: 15.75, TodayCostUSD: 22.1,
+		WeeklyPct: 30, WeeklyResetSecs: 4 * 24 * 3600,
+		ScopedPct: -1, ScopedResetSecs: -1,
+		BurnUSDPerHour: 2.88, UpdatedEpoch: baseTime.Unix(),
+	}
+}
+
+func TestParseCardRequest(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    cardRequest
+		wantErr string
+	}{
+		{"", cardRequest{width: 800, height: 480, palette: paletteColour}, ""},
+		{"width=296&height=128&palette=1bit", cardRequest{width: 296, height: 128, palette: palette1Bit}, ""},
+		{"palette=grey", cardRequest{width: 800, height: 480, palette: paletteGrey}, ""},
+		{"width=10", cardRequest{}, "invalid width"},
+		{"height=4096", cardRequest{}, "invalid height"},
+		{"width=wide", cardRequest{}, "invalid width"},
+		{"palette=sepia", cardRequest{}, "invalid palette"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parseCardRequest(httptest.NewRequest(http.MethodGet, "/api/v1/card.png?"+tt.query, nil))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCardRows(t *testing.T) {
+	d := testCardDisplay()
+	rows := cardRows(d)
+	require.Len(t, rows, 3, "session, session reset and weekly; no scoped limit")
+	assert.Equal(t, "45%", rows[0].value)
+	assert.Equal(t, "in 3h 0m", rows[1].value)
+	assert.InDelta(t, 60, rows[1].pct, 0.01, "3h of a 5h session left")
+	assert.Equal(t, "30% · resets in 4d 0h", rows[2].value)
+
+	d.ScopedPct, d.ScopedModel, d.ScopedResetSecs = 25, "Sonnet", 90
+	rows = cardRows(d)
+	require.Len(t, rows, 4)
+	assert.Equal(t, "Weekly Sonnet", rows[3].label)
+	assert.Equal(t, "25% · resets in 1m", rows[3].value)
+}
+
+func TestRenderCard(t *testing.T) {
+	tests := []struct {
+		palette string
+		check   func(t *testing.T, img image.Image)
+	}{
+		{paletteColour, func(t *testing.T, img image.Image) {
+			assert.IsType(t, &image.RGBA{}, img, "opaque, so no alpha channel")
+		}},
+		{paletteGrey, func(t *testing.T, img image.Image) {
+			assert.IsType(t, &image.Gray{}, img)
+		}},
+		{palette1Bit, func(t *testing.T, img image.Image) {
+			p, ok := img.(*image.Paletted)
+			require.True(t, ok, "1bit renders to a paletted image")
+			assert.Len(t, p.Palette, 2)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.palette, func(t *testing.T) {
+			data, err := renderCard(testCardDisplay(), cardRequest{width: 400, height: 240, palette: tt.palette})
+			require.NoError(t, err)
+			img, err := png.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, image.Rect(0, 0, 400, 240), img.Bounds())
+			tt.check(t, img)
+		})
+	}
+
+	// The smallest card still renders
+	_, err := renderCard(testCardDisplay(), cardRequest{width: cardMinSize, height: cardMinSize, palette: palette1Bit})
+	assert.NoError(t, err)
+}
+
+func TestServer_Card(t *testing.T) {
+	s := newTestServer(models.APIConfig{})
+	mux := s.handler()
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, get("/api/v1/card.png", "").Code)
+
+	s.UpdateDisplay(testCardDisplay())
+
+	rr := get("/api/v1/card.png?width=296&height=128&palette=1bit", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	cfg, err := png.DecodeConfig(bytes.NewReader(rr.Body.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 296, cfg.Width)
+	assert.Equal(t, 128, cfg.Height)
+
+	etag := rr.Header().Get("ETag")
+	assert.Equal(t, http.StatusNotModified, get("/api/v1/card.png?width=296&height=128&palette=1bit", etag).Code)
+	assert.Len(t, s.cards, 1, "the render is cached")
+
+	// A new display drops the cached renders
+	d := testCardDisplay()
+	d.SessionPct = 80
+	s.UpdateDisplay(d)
+	assert.Empty(t, s.cards)
+	assert.Equal(t, http.StatusOK, get("/api/v1/card.png?width=296&height=128&palette=1bit", etag).Code)
+
+	rr = get("/api/v1/card.png?palette=sepia", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "invalid palette")
+}
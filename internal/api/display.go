@@ -0,0 +1,190 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sammcj/ccu/internal/ui"
+)
+
+// Severity levels for DisplayResponse, from the highest of the session,
+// weekly and scoped weekly percentages
+const (
+	severityOK       = 0 // under 50%
+	severityWarn     = 1 // 50% or over, or a limit is predicted before reset
+	severityHigh     = 2 // 80% or over
+	severityCritical = 3 // at or over the limit
+)
+
+var severityLevels = [...]string{"ok", "warn", "high", "critical"}
+
+// BuildDisplayResponse assembles the flat display summary from the current
+// app state and, for today's cost, the history snapshot (nil if unavailable)
+func BuildDisplayResponse(state StateProvider, history *History, now time.Time) DisplayResponse {
+	d := displayFromStatus(BuildStatus(state, now), now)
+	if history != nil {
+		d.TodayCostUSD = roundTo(history.dayCost(now), 2)
+	}
+	return d
+}
+
+// displayFromStatus flattens a status response
+func displayFromStatus(status StatusResponse, now time.Time) DisplayResponse {
+	d := DisplayResponse{
+		SchemaVersion:   SchemaVersion,
+		Plan:            status.Plan,
+		TodayCostUSD:    -1,
+		WeeklyPct:       -1,
+		WeeklyResetSecs: -1,
+		ScopedPct:       -1,
+		ScopedResetSecs: -1,
+		UpdatedEpoch:    now.Unix(),
+	}
+
+	if s := status.Session; s != nil {
+		d.SessionPct = roundTo(s.UtilisationPct, 1)
+		d.SessionResetSecs = s.ResetsInSeconds
+		d.SessionCostUSD = roundTo(s.CostUSD, 2)
+	}
+	if w := status.Weekly; w != nil {
+		if w.AllModels != nil {
+			d.WeeklyPct = roundTo(w.AllModels.UtilisationPct, 1)
+			d.WeeklyResetSecs = w.AllModels.ResetsInSeconds
+		}
+		if scoped := highestScoped(w.Scoped); scoped != nil {
+			d.ScopedPct = roundTo(scoped.UtilisationPct, 1)
+			d.ScopedModel = scoped.Model
+			if scoped.ResetsInSeconds != nil {
+				d.ScopedResetSecs = *scoped.ResetsInSeconds
+			}
+		}
+	}
+	if b := status.BurnRate; b != nil {
+		d.BurnUSDPerHour = roundTo(b.CostPerHourUSD, 2)
+	}
+	if p := status.Prediction; p != nil {
+		d.LimitPredicted = p.SessionWillHitLimit || p.WeeklyWillHitLimit
+	}
+
+	highest := max(d.SessionPct, d.WeeklyPct, d.ScopedPct, 0)
+	switch {
+	case highest >= 100:
+		d.Severity = severityCritical
+	case highest >= 80:
+		d.Severity = severityHigh
+	case highest >= 50 || d.LimitPredicted:
+		d.Severity = severityWarn
+	default:
+		d.Severity = severityOK
+	}
+	d.Level = severityLevels[d.Severity]
+	d.Colour = strings.ToUpper(string(ui.GetPercentageColour(highest)))
+	d.ColourRGB565 = rgb565(d.Colour)
+	return d
+}
+
+// highestScoped returns the per-model weekly limit closest to running out,
+// breaking ties by model name so the choice is stable
+func highestScoped(scoped map[string]*WeeklyModelSection) *WeeklyModelSection {
+	var best *WeeklyModelSection
+	for _, s := range scoped {
+		if best == nil || cmp.Or(cmp.Compare(s.UtilisationPct, best.UtilisationPct), cmp.Compare(best.Model, s.Model)) > 0 {
+			best = s
+		}
+	}
+	return best
+}
+
+// rgb565 packs a "#RRGGBB" colour into 16 bits, 5 red, 6 green and 5 blue
+func rgb565(hex string) int {
+	r, g, b, ok := parseHex(hex)
+	if !ok {
+		return 0
+	}
+	return int(r)>>3<<11 | int(g)>>2<<5 | int(b)>>3
+}
+
+func roundTo(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
+
+// displayText renders d as one key=value line per field, in the JSON keys
+// and order. Booleans are 1 or 0 so they parse as numbers.
+func displayText(d DisplayResponse) []byte {
+	var b strings.Builder
+	v := reflect.ValueOf(d)
+	for i := range v.NumField() {
+		key, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		b.WriteString(key)
+		b.WriteByte('=')
+		switch f := v.Field(i); f.Kind() {
+		case reflect.Bool:
+			if f.Bool() {
+				b.WriteByte('1')
+			} else {
+				b.WriteByte('0')
+			}
+		case reflect.Float64:
+			b.WriteString(strconv.FormatFloat(f.Float(), 'f', -1, 64))
+		case reflect.String:
+			b.WriteString(strings.NewReplacer("\n", " ", "\r", " ").Replace(f.String()))
+		default:
+			fmt.Fprint(&b, f.Interface())
+		}
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
+
+// UpdateDisplay replaces the display summary served at /api/v1/display,
+// unless only its countdowns and updated_epoch changed. Safe to call from any
+// goroutine.
+func (s *Server) UpdateDisplay(d DisplayResponse) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		log.Printf("api: failed to encode display: %v", err)
+		return
+	}
+	// As for the status, a refresh that only moved the clock keeps the
+	// current summary, whose countdowns are as of its updated_epoch
+	clockless := d
+	clockless.UpdatedEpoch, clockless.SessionResetSecs, clockless.WeeklyResetSecs, clockless.ScopedResetSecs = 0, 0, 0, 0
+	key, _ := json.Marshal(clockless)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.display.replace(data, key)
+	if next.etag == s.display.etag {
+		return
+	}
+	s.displayData = d
+	s.display = next
+	s.displayText = s.displayText.replace(displayText(d), displayText(clockless))
+	s.cards = make(map[cardRequest]snapshot)
+}
+
+// handleDisplay serves the display summary as JSON, or with ?format=text as
+// key=value lines. Both answer conditional requests like the status route.
+func (s *Server) handleDisplay(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	jsonSnap, textSnap := s.display, s.displayText
+	s.mu.RUnlock()
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		jsonSnap.serve(w, r, "application/json")
+	case "text":
+		textSnap.serve(w, r, "text/plain; charset=utf-8")
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid format %q: use json or text", format))
+	}
+}
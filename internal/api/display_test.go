@@ -0,0 +1,213 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildDisplayResponse(t *testing.T) {
+	now := baseTime
+	session := newTestSession(now)
+
+	t.Run("with OAuth", func(t *testing.T) {
+		state := &mockState{
+			oauthData:      newTestOAuthData(now),
+			currentSession: session,
+			sessions:       []models.SessionBlock{*session},
+			limits:         newTestLimits(),
+			config:         newTestConfig(),
+			lastRefresh:    now,
+			hasData:        true,
+		}
+		d := BuildDisplayResponse(state, nil, now)
+		assert.Equal(t, SchemaVersion, d.SchemaVersion)
+		assert.Equal(t, "max5", d.Plan)
+		assert.Equal(t, 45.0, d.SessionPct)
+		assert.Equal(t, int64(3*3600), d.SessionResetSecs)
+		assert.Equal(t, 30.0, d.WeeklyPct)
+		assert.Equal(t, int64(4*24*3600), d.WeeklyResetSecs)
+		assert.Equal(t, 25.0, d.ScopedPct)
+		assert.Equal(t, "Sonnet", d.ScopedModel)
+		assert.Equal(t, int64(4*24*3600), d.ScopedResetSecs)
+		assert.Equal(t, "ok", d.Level)
+		assert.Equal(t, "#E0FF28", d.Colour, "colour follows the highest percentage, 45%")
+		assert.Equal(t, now.Unix(), d.UpdatedEpoch)
+		assert.Equal(t, -1.0, d.TodayCostUSD, "unknown without history")
+	})
+
+	t.Run("today's cost from history", func(t *testing.T) {
+		state := &mockState{currentSession: session, limits: newTestLimits(), config: newTestConfig(), hasData: true}
+		h := testHistory(t)
+		assert.Equal(t, 3.0, BuildDisplayResponse(state, h, time.Date(2025, 12, 1, 20, 0, 0, 0, time.UTC)).TodayCostUSD)
+		assert.Equal(t, 0.0, BuildDisplayResponse(state, h, time.Date(2025, 12, 5, 9, 0, 0, 0, time.UTC)).TodayCostUSD)
+	})
+
+	t.Run("without OAuth", func(t *testing.T) {
+		state := &mockState{
+			currentSession: session,
+			sessions:       []models.SessionBlock{*session},
+			limits:         newTestLimits(),
+			config:         newTestConfig(),
+			lastRefresh:    now,
+			hasData:        true,
+		}
+		d := BuildDisplayResponse(state, nil, now)
+		assert.InDelta(t, session.CostUSD/35*100, d.SessionPct, 0.05)
+		assert.Equal(t, -1.0, d.WeeklyPct)
+		assert.Equal(t, int64(-1), d.WeeklyResetSecs)
+		assert.Equal(t, -1.0, d.ScopedPct)
+		assert.Empty(t, d.ScopedModel)
+		assert.Equal(t, int64(-1), d.ScopedResetSecs)
+	})
+}
+
+func TestDisplayFromStatus_Severity(t *testing.T) {
+	resets := int64(60)
+	tests := []struct {
+		name         string
+		session      float64
+		weekly       float64
+		scoped       map[string]float64
+		predicted    bool
+		wantSeverity int
+		wantLevel    string
+		wantScoped   string
+	}{
+		{name: "idle", wantSeverity: severityOK, wantLevel: "ok"},
+		{name: "session half used", session: 50, wantSeverity: severityWarn, wantLevel: "warn"},
+		{name: "limit predicted", session: 10, predicted: true, wantSeverity: severityWarn, wantLevel: "warn"},
+		{name: "weekly high", session: 10, weekly: 85, wantSeverity: severityHigh, wantLevel: "high"},
+		{
+			name: "scoped limit reached", session: 10, weekly: 60,
+			scoped:       map[string]float64{"sonnet": 40, "fable": 100},
+			wantSeverity: severityCritical, wantLevel: "critical", wantScoped: "Fable",
+		},
+		{
+			name: "scoped tie picks by name", session: 10,
+			scoped:       map[string]float64{"sonnet": 40, "fable": 40},
+			wantSeverity: severityOK, wantLevel: "ok", wantScoped: "Fable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := StatusResponse{
+				Session:    &SessionSection{UtilisationPct: tt.session},
+				Weekly:     &WeeklySection{AllModels: &WeeklyAllSection{UtilisationPct: tt.weekly}},
+				Prediction: &PredictionSection{WeeklyWillHitLimit: tt.predicted},
+			}
+			for key, pct := range tt.scoped {
+				if status.Weekly.Scoped == nil {
+					status.Weekly.Scoped = make(map[string]*WeeklyModelSection)
+				}
+				status.Weekly.Scoped[key] = &WeeklyModelSection{Model: strings.ToUpper(key[:1]) + key[1:], UtilisationPct: pct, ResetsInSeconds: &resets}
+			}
+
+			d := displayFromStatus(status, baseTime)
+			assert.Equal(t, tt.wantSeverity, d.Severity)
+			assert.Equal(t, tt.wantLevel, d.Level)
+			assert.Equal(t, tt.predicted, d.LimitPredicted)
+			if tt.wantScoped != "" {
+				assert.Equal(t, tt.wantScoped, d.ScopedModel)
+				assert.Equal(t, resets, d.ScopedResetSecs)
+			}
+		})
+	}
+}
+
+func TestRGB565(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want int
+	}{
+		{"#FF0000", 0xF800},
+		{"#00FF00", 0x07E0},
+		{"#0000FF", 0x001F},
+		{"#FFFFFF", 0xFFFF},
+		{"#00FF88", 0x07F1},
+		{"bogus", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hex, func(t *testing.T) {
+			assert.Equal(t, tt.want, rgb565(tt.hex))
+		})
+	}
+}
+
+func TestDisplayText(t *testing.T) {
+	d := DisplayResponse{
+		SchemaVersion: 1, Plan: "max5", SessionPct: 42.5, SessionResetSecs: 3600, TodayCostUSD: -1,
+		WeeklyPct: -1, WeeklyResetSecs: -1, ScopedPct: -1, ScopedResetSecs: -1,
+		BurnUSDPerHour: 2.88, LimitPredicted: true, Severity: 1, Level: "warn",
+		Colour: "#FFB000", ColourRGB565: 0xFD80, UpdatedEpoch: 1771416000,
+	}
+	want := `schema_version=1
+plan=max5
+session_pct=42.5
+session_reset_secs=3600
+session_cost_usd=0
+today_cost_usd=-1
+weekly_pct=-1
+weekly_reset_secs=-1
+scoped_pct=-1
+scoped_model=
+scoped_reset_secs=-1
+burn_usd_per_hour=2.88
+limit_predicted=1
+severity=1
+level=warn
+colour=#FFB000
+colour_rgb565=64896
+updated_epoch=1771416000
+`
+	assert.Equal(t, want, string(displayText(d)))
+}
+
+func TestServer_Display(t *testing.T) {
+	s := newTestServer(models.APIConfig{})
+	mux := s.handler()
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, get("/api/v1/display", "").Code)
+
+	s.UpdateDisplay(DisplayResponse{SchemaVersion: 1, Plan: "pro", Level: "ok", Colour: "#00FF88"})
+
+	rr := get("/api/v1/display", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `"plan":"pro"`)
+	assert.Equal(t, http.StatusNotModified, get("/api/v1/display", rr.Header().Get("ETag")).Code)
+
+	rr = get("/api/v1/display?format=text", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "plan=pro\n")
+	assert.Equal(t, http.StatusNotModified, get("/api/v1/display?format=text", rr.Header().Get("ETag")).Code)
+
+	// A later refresh that only moved the clock keeps the summary and its ETag
+	etag := rr.Header().Get("ETag")
+	s.UpdateDisplay(DisplayResponse{SchemaVersion: 1, Plan: "pro", Level: "ok", Colour: "#00FF88", UpdatedEpoch: 60, SessionResetSecs: 3540})
+	assert.Equal(t, http.StatusNotModified, get("/api/v1/display?format=text", etag).Code)
+	assert.Zero(t, s.displayData.UpdatedEpoch)
+
+	s.UpdateDisplay(DisplayResponse{SchemaVersion: 1, Plan: "max5", Level: "ok", Colour: "#00FF88", UpdatedEpoch: 120})
+	assert.Equal(t, http.StatusOK, get("/api/v1/display?format=text", etag).Code)
+
+	rr = get("/api/v1/display?format=xml", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "invalid format")
+}
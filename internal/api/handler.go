@@ -10,6 +10,7 @@ import (
 	"github.com/sammcj/ccu/internal/analysis"
 	"github.com/sammcj/ccu/internal/models"
 	"github.com/sammcj/ccu/internal/oauth"
+	"github.com/sammcj/ccu/internal/pricing"
 )
 
 // StateProvider allows BuildStatusResponse to read app state without
@@ -21,12 +22,18 @@ type StateProvider interface {
 	GetLimits() models.Limits
 	GetConfig() *models.Config
 	GetLastRefresh() time.Time
+	GetUnpricedModels() []pricing.UnpricedModel
 	HasData() bool
 }
 
 // BuildStatusResponse assembles a StatusResponse from the current app state
 // and serialises it to JSON.
 func BuildStatusResponse(state StateProvider, now time.Time) ([]byte, error) {
+	return json.Marshal(BuildStatus(state, now))
+}
+
+// BuildStatus assembles a StatusResponse from the current app state
+func BuildStatus(state StateProvider, now time.Time) StatusResponse {
 	limits := state.GetLimits()
 	cfg := state.GetConfig()
 	oauthData := state.GetOAuthData()
@@ -35,6 +42,7 @@ func BuildStatusResponse(state StateProvider, now time.Time) ([]byte, error) {
 	lastRefresh := state.GetLastRefresh()
 
 	resp := StatusResponse{
+		SchemaVersion:  SchemaVersion,
 		Plan:           strings.ToLower(limits.PlanName),
 		ServerTime:     now.UTC().Format(time.RFC3339),
 		DataAgeSeconds: int(now.Sub(lastRefresh).Seconds()),
@@ -56,7 +64,19 @@ func BuildStatusResponse(state StateProvider, now time.Time) ([]byte, error) {
 	// Prediction section
 	resp.Prediction = buildPredictionSection(currentSession, oauthData, limits, now)
 
-	return json.Marshal(resp)
+	for _, u := range state.GetUnpricedModels() {
+		resp.UnpricedModels = append(resp.UnpricedModels, UnpricedModel{
+			Model:           u.Model,
+			Provider:        u.Provider,
+			Requests:        u.Requests,
+			Tokens:          u.Tokens,
+			FallbackCostUSD: u.FallbackCost,
+			FirstSeen:       u.FirstSeen.UTC().Format(time.RFC3339),
+			LastSeen:        u.LastSeen.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return resp
 }
 
 func buildWeeklySection(oauthData *oauth.UsageData, cfg *models.Config, now time.Time) *WeeklySection {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/sammcj/ccu/internal/models"
 	"github.com/sammcj/ccu/internal/oauth"
+	"github.com/sammcj/ccu/internal/pricing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -19,6 +20,7 @@ type mockState struct {
 	limits         models.Limits
 	config         *models.Config
 	lastRefresh    time.Time
+	unpriced       []pricing.UnpricedModel
 	hasData        bool
 }
 
@@ -28,7 +30,10 @@ func (m *mockState) GetSessions() []models.SessionBlock      { return m.sessions
 func (m *mockState) GetLimits() models.Limits                { return m.limits }
 func (m *mockState) GetConfig() *models.Config               { return m.config }
 func (m *mockState) GetLastRefresh() time.Time               { return m.lastRefresh }
-func (m *mockState) HasData() bool                           { return m.hasData }
+func (m *mockState) GetUnpricedModels() []pricing.UnpricedModel {
+	return m.unpriced
+}
+func (m *mockState) HasData() bool { return m.hasData }
 
 // baseTime is the fixed reference time used across all tests.
 var baseTime = time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)
@@ -243,6 +248,7 @@ func TestBuildStatusResponse_FullData(t *testing.T) {
 	var resp StatusResponse
 	require.NoError(t, json.Unmarshal(raw, &resp))
 
+	assert.Equal(t, SchemaVersion, resp.SchemaVersion)
 	assert.Equal(t, "max5", resp.Plan)
 	assert.Equal(t, now.UTC().Format(time.RFC3339), resp.ServerTime)
 	assert.InDelta(t, 30, resp.DataAgeSeconds, 2)
@@ -349,6 +355,43 @@ func TestBuildStatusResponse_NoData(t *testing.T) {
 	assert.Equal(t, 0.0, resp.BurnRate.TokensPerMin)
 }
 
+func TestBuildStatusResponse_UnpricedModels(t *testing.T) {
+	now := baseTime
+	state := &mockState{
+		limits:      newTestLimits(),
+		config:      newTestConfig(),
+		lastRefresh: now,
+		unpriced: []pricing.UnpricedModel{{
+			Model:        "claude-zenith-1",
+			Requests:     3,
+			Tokens:       4500,
+			FallbackCost: 0.25,
+			FirstSeen:    now.Add(-2 * time.Hour),
+			LastSeen:     now,
+		}},
+	}
+
+	raw, err := BuildStatusResponse(state, now)
+	require.NoError(t, err)
+
+	var resp StatusResponse
+	require.NoError(t, json.Unmarshal(raw, &resp))
+	require.Len(t, resp.UnpricedModels, 1)
+	u := resp.UnpricedModels[0]
+	assert.Equal(t, "claude-zenith-1", u.Model)
+	assert.Equal(t, 3, u.Requests)
+	assert.Equal(t, 4500, u.Tokens)
+	assert.InDelta(t, 0.25, u.FallbackCostUSD, 1e-9)
+	assert.Equal(t, "2026-02-18T10:00:00Z", u.FirstSeen)
+	assert.Equal(t, "2026-02-18T12:00:00Z", u.LastSeen)
+
+	// The field is omitted when every model is priced
+	state.unpriced = nil
+	raw, err = BuildStatusResponse(state, now)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "unpriced_models")
+}
+
 func TestBuildStatusResponse_SizeUnder4KB(t *testing.T) {
 	now := baseTime
 	session := newTestSession(now)
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/sammcj/ccu/internal/ui"
+)
+
+// History is the snapshot the history endpoints serve from. It is rebuilt
+// from the loaded entries on every refresh, so it covers the same window as
+// the dashboard (-hours) and requests never trigger a JSONL reload.
+type History struct {
+	Sessions []SessionSummary // newest first, gaps excluded
+	Daily    []UsagePeriod    // oldest first
+	Monthly  []UsagePeriod    // oldest first
+	Timezone *time.Location
+}
+
+// BuildHistory assembles a History from the loaded entries and session
+// blocks, grouping days and months in tz
+func BuildHistory(entries []models.UsageEntry, sessions []models.SessionBlock, tz *time.Location) *History {
+	if tz == nil {
+		tz = time.Local
+	}
+	return &History{
+		Sessions: buildSessionSummaries(sessions),
+		Daily:    buildUsagePeriods(ui.AggregateForReport(entries, "daily", tz), "2006-01-02"),
+		Monthly:  buildUsagePeriods(ui.AggregateForReport(entries, "monthly", tz), "2006-01"),
+		Timezone: tz,
+	}
+}
+
+// WithSessions returns a copy of h with the sessions rebuilt, for refreshes
+// where the entries are unchanged but session state (active, end time) may
+// have moved on
+func (h *History) WithSessions(sessions []models.SessionBlock) *History {
+	next := *h
+	next.Sessions = buildSessionSummaries(sessions)
+	return &next
+}
+
+// dayCost returns the cost of the day containing t, in the history's timezone
+func (h *History) dayCost(t time.Time) float64 {
+	day := t.In(h.Timezone).Format("2006-01-02")
+	for i := len(h.Daily) - 1; i >= 0; i-- {
+		if h.Daily[i].Start == day {
+			return h.Daily[i].CostUSD
+		}
+	}
+	return 0
+}
+
+// buildSessionSummaries converts session blocks, newest first, leaving out gaps
+func buildSessionSummaries(sessions []models.SessionBlock) []SessionSummary {
+	out := make([]SessionSummary, 0, len(sessions))
+	for i := len(sessions) - 1; i >= 0; i-- {
+		s := &sessions[i]
+		if s.IsGap {
+			continue
+		}
+		summary := SessionSummary{
+			ID:           s.ID,
+			StartTime:    s.StartTime.UTC().Format(time.RFC3339),
+			EndTime:      s.EndTime.UTC().Format(time.RFC3339),
+			IsActive:     s.IsActive,
+			CostUSD:      s.CostUSD,
+			TotalTokens:  s.TotalTokens,
+			MessageCount: s.MessageCount,
+			Models:       make([]SessionModelStats, 0, len(s.PerModelStats)),
+			start:        s.StartTime,
+		}
+		if s.ActualEndTime != nil {
+			summary.ActualEndTime = s.ActualEndTime.UTC().Format(time.RFC3339)
+		}
+		for model, ms := range s.PerModelStats {
+			summary.Models = append(summary.Models, SessionModelStats{
+				Model:               model,
+				InputTokens:         ms.InputTokens,
+				OutputTokens:        ms.OutputTokens,
+				CacheCreationTokens: ms.CacheCreationTokens,
+				CacheReadTokens:     ms.CacheReadTokens,
+				CostUSD:             ms.CostUSD,
+				MessageCount:        ms.MessageCount,
+			})
+		}
+		slices.SortFunc(summary.Models, func(a, b SessionModelStats) int {
+			return cmp.Or(cmp.Compare(b.CostUSD, a.CostUSD), cmp.Compare(a.Model, b.Model))
+		})
+		out = append(out, summary)
+	}
+	return out
+}
+
+// buildUsagePeriods converts report stats, merging model IDs that normalise
+// to the same model (e.g. dated and undated releases)
+func buildUsagePeriods(stats []ui.ReportStats, layout string) []UsagePeriod {
+	out := make([]UsagePeriod, 0, len(stats))
+	for _, s := range stats {
+		byModel := make(map[string]*UsageModelStats)
+		for name, ms := range s.ModelStats {
+			addModelStats(byModel, UsageModelStats{
+				Model:                 name,
+				InputTokens:           ms.InputTokens,
+				OutputTokens:          ms.OutputTokens,
+				CacheCreationTokens:   ms.CacheCreationTokens,
+				CacheCreation5mTokens: ms.CacheCreation5mTokens,
+				CacheCreation1hTokens: ms.CacheCreation1hTokens,
+				CacheReadTokens:       ms.CacheReadTokens,
+				TotalTokens:           ms.TotalTokens,
+				CostUSD:               ms.TotalCost,
+				Requests:              ms.Requests,
+				LongContextRequests:   ms.LongContextRequests,
+			})
+		}
+
+		period := UsagePeriod{Start: s.Period.Format(layout), Models: sortedModelStats(byModel), start: s.Period}
+		for _, ms := range period.Models {
+			period.CostUSD += ms.CostUSD
+			period.TotalTokens += ms.TotalTokens
+			period.Requests += ms.Requests
+		}
+		out = append(out, period)
+	}
+	return out
+}
+
+// addModelStats adds ms into the stats for its normalised model name
+func addModelStats(byModel map[string]*UsageModelStats, ms UsageModelStats) {
+	name := models.NormaliseModelName(ms.Model)
+	t, ok := byModel[name]
+	if !ok {
+		ms.Model = name
+		ms.Provider = models.ProviderOf(name).ID
+		byModel[name] = &ms
+		return
+	}
+	t.InputTokens += ms.InputTokens
+	t.OutputTokens += ms.OutputTokens
+	t.CacheCreationTokens += ms.CacheCreationTokens
+	t.CacheCreation5mTokens += ms.CacheCreation5mTokens
+	t.CacheCreation1hTokens += ms.CacheCreation1hTokens
+	t.CacheReadTokens += ms.CacheReadTokens
+	t.TotalTokens += ms.TotalTokens
+	t.CostUSD += ms.CostUSD
+	t.Requests += ms.Requests
+	t.LongContextRequests += ms.LongContextRequests
+}
+
+// sortedModelStats returns the stats highest cost first
+func sortedModelStats(byModel map[string]*UsageModelStats) []UsageModelStats {
+	out := make([]UsageModelStats, 0, len(byModel))
+	for _, ms := range byModel {
+		out = append(out, *ms)
+	}
+	slices.SortFunc(out, func(a, b UsageModelStats) int {
+		return cmp.Or(cmp.Compare(b.CostUSD, a.CostUSD), cmp.Compare(a.Model, b.Model))
+	})
+	return out
+}
+
+// historyQuery holds the query parameters the history endpoints accept:
+// from and to bound the range (inclusive), and limit caps how many items are
+// returned, keeping the most recent (or, for models, the highest cost)
+type historyQuery struct {
+	from, until time.Time // until is exclusive; zero = unbounded
+	limit       int       // 0 = no limit
+}
+
+// parseHistoryQuery reads from, to and limit. from and to take a date
+// (YYYY-MM-DD), a month (YYYY-MM, to meaning the whole month) or an RFC3339
+// time; dates and months are in tz.
+func parseHistoryQuery(q url.Values, tz *time.Location) (historyQuery, error) {
+	var hq historyQuery
+	if v := q.Get("from"); v != "" {
+		from, _, err := parseRangeBound(v, tz)
+		if err != nil {
+			return hq, fmt.Errorf("invalid from: %w", err)
+		}
+		hq.from = from
+	}
+	if v := q.Get("to"); v != "" {
+		_, until, err := parseRangeBound(v, tz)
+		if err != nil {
+			return hq, fmt.Errorf("invalid to: %w", err)
+		}
+		hq.until = until
+	}
+	if !hq.from.IsZero() && !hq.until.IsZero() && !hq.from.Before(hq.until) {
+		return hq, fmt.Errorf("from must be before to")
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return hq, fmt.Errorf("invalid limit %q: must be a positive integer", v)
+		}
+		hq.limit = n
+	}
+	return hq, nil
+}
+
+// parseRangeBound returns the start of the day, month or instant v names,
+// and the end of it (exclusive)
+func parseRangeBound(v string, tz *time.Location) (start, end time.Time, err error) {
+	if t, err := time.ParseInLocation("2006-01-02", v, tz); err == nil {
+		return t, t.AddDate(0, 0, 1), nil
+	}
+	if t, err := time.ParseInLocation("2006-01", v, tz); err == nil {
+		return t, t.AddDate(0, 1, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, t.Add(time.Nanosecond), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("%q is not YYYY-MM-DD, YYYY-MM or RFC3339", v)
+}
+
+// contains reports whether t falls within the query's range
+func (hq historyQuery) contains(t time.Time) bool {
+	return (hq.from.IsZero() || !t.Before(hq.from)) && (hq.until.IsZero() || t.Before(hq.until))
+}
+
+// sessions returns the sessions that started within the range, newest first
+func (hq historyQuery) sessions(all []SessionSummary) []SessionSummary {
+	out := []SessionSummary{}
+	for _, s := range all {
+		if hq.contains(s.start) {
+			out = append(out, s)
+		}
+	}
+	if hq.limit > 0 && len(out) > hq.limit {
+		out = out[:hq.limit]
+	}
+	return out
+}
+
+// periods returns the periods that start within the range, oldest first,
+// keeping the latest limit of them
+func (hq historyQuery) periods(all []UsagePeriod) []UsagePeriod {
+	out := hq.inRange(all)
+	if hq.limit > 0 && len(out) > hq.limit {
+		out = out[len(out)-hq.limit:]
+	}
+	return out
+}
+
+// inRange returns the periods starting within the range, ignoring limit
+func (hq historyQuery) inRange(all []UsagePeriod) []UsagePeriod {
+	out := []UsagePeriod{}
+	for _, p := range all {
+		if hq.contains(p.start) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// models totals each model across every day within the range, highest cost
+// first, keeping the top limit. The limit counts models, not days.
+func (hq historyQuery) models(daily []UsagePeriod) []UsageModelStats {
+	byModel := make(map[string]*UsageModelStats)
+	for _, p := range hq.inRange(daily) {
+		for _, ms := range p.Models {
+			addModelStats(byModel, ms)
+		}
+	}
+	out := sortedModelStats(byModel)
+	if hq.limit > 0 && len(out) > hq.limit {
+		out = out[:hq.limit]
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func historyEntry(ts time.Time, model string, input, output int, cost float64) models.UsageEntry {
+	return models.UsageEntry{Timestamp: ts, Model: model, InputTokens: input, OutputTokens: output, CostUSD: cost}
+}
+
+func testHistory(t *testing.T) *History {
+	t.Helper()
+	tz := time.UTC
+	day := func(m time.Month, d int) time.Time { return time.Date(2025, m, d, 10, 0, 0, 0, tz) }
+	entries := []models.UsageEntry{
+		historyEntry(day(11, 30), "claude-sonnet-4-20250514", 100, 50, 1.0),
+		historyEntry(day(12, 1), "claude-sonnet-4-20250514", 100, 50, 1.0),
+		historyEntry(day(12, 1), "claude-sonnet-4", 100, 50, 2.0),
+		historyEntry(day(12, 2), "claude-opus-4-1-20250805", 10, 5, 4.0),
+		historyEntry(day(12, 3), "claude-sonnet-4-20250514", 100, 50, 1.0),
+	}
+	end := day(12, 3).Add(time.Hour)
+	sessions := []models.SessionBlock{
+		{
+			ID: "s1", StartTime: day(12, 1), EndTime: day(12, 1).Add(5 * time.Hour), CostUSD: 3, MessageCount: 2,
+			PerModelStats: map[string]*models.ModelStats{
+				"claude-sonnet-4": {InputTokens: 200, OutputTokens: 100, CostUSD: 3, MessageCount: 2},
+			},
+		},
+		{ID: "gap", IsGap: true, StartTime: day(12, 1).Add(5 * time.Hour), EndTime: day(12, 3)},
+		{
+			ID: "s2", StartTime: day(12, 3), EndTime: day(12, 3).Add(5 * time.Hour), ActualEndTime: &end, IsActive: true, CostUSD: 1, MessageCount: 1,
+			PerModelStats: map[string]*models.ModelStats{
+				"claude-sonnet-4": {InputTokens: 100, OutputTokens: 50, CostUSD: 1, MessageCount: 1},
+			},
+		},
+	}
+	return BuildHistory(entries, sessions, tz)
+}
+
+func TestBuildHistory(t *testing.T) {
+	h := testHistory(t)
+
+	require.Len(t, h.Sessions, 2, "gaps are left out")
+	assert.Equal(t, "s2", h.Sessions[0].ID, "newest first")
+	assert.Equal(t, "2025-12-03T11:00:00Z", h.Sessions[0].ActualEndTime)
+	assert.Empty(t, h.Sessions[1].ActualEndTime)
+	assert.Equal(t, []SessionModelStats{{Model: "claude-sonnet-4", InputTokens: 200, OutputTokens: 100, CostUSD: 3, MessageCount: 2}}, h.Sessions[1].Models)
+
+	require.Len(t, h.Daily, 4)
+	assert.Equal(t, []string{"2025-11-30", "2025-12-01", "2025-12-02", "2025-12-03"},
+		[]string{h.Daily[0].Start, h.Daily[1].Start, h.Daily[2].Start, h.Daily[3].Start})
+	dec1 := h.Daily[1]
+	require.Len(t, dec1.Models, 1, "dated and undated IDs merge")
+	assert.Equal(t, "claude-sonnet-4", dec1.Models[0].Model)
+	assert.Equal(t, "anthropic", dec1.Models[0].Provider)
+	assert.Equal(t, 2, dec1.Models[0].Requests)
+	assert.InDelta(t, 3.0, dec1.CostUSD, 1e-9)
+	assert.Equal(t, 300, dec1.TotalTokens)
+	assert.Equal(t, 2, dec1.Requests)
+
+	require.Len(t, h.Monthly, 2)
+	assert.Equal(t, "2025-11", h.Monthly[0].Start)
+	assert.Equal(t, "2025-12", h.Monthly[1].Start)
+	assert.Equal(t, 4, h.Monthly[1].Requests)
+
+	refreshed := h.WithSessions(nil)
+	assert.Empty(t, refreshed.Sessions)
+	assert.Len(t, h.Sessions, 2, "the original is unchanged")
+	assert.Equal(t, h.Daily, refreshed.Daily)
+}
+
+func TestParseHistoryQuery(t *testing.T) {
+	tz := time.FixedZone("AEST", 10*3600)
+	tests := []struct {
+		name      string
+		query     string
+		wantFrom  time.Time
+		wantUntil time.Time
+		wantLimit int
+		wantErr   string
+	}{
+		{name: "empty"},
+		{
+			name:      "dates are inclusive, in the timezone",
+			query:     "from=2025-12-01&to=2025-12-02",
+			wantFrom:  time.Date(2025, 12, 1, 0, 0, 0, 0, tz),
+			wantUntil: time.Date(2025, 12, 3, 0, 0, 0, 0, tz),
+		},
+		{
+			name:      "months cover the whole month",
+			query:     "from=2025-11&to=2025-12&limit=3",
+			wantFrom:  time.Date(2025, 11, 1, 0, 0, 0, 0, tz),
+			wantUntil: time.Date(2026, 1, 1, 0, 0, 0, 0, tz),
+			wantLimit: 3,
+		},
+		{
+			name:     "RFC3339",
+			query:    "from=2025-12-01T12:00:00Z",
+			wantFrom: time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{name: "bad from", query: "from=yesterday", wantErr: "invalid from"},
+		{name: "bad to", query: "to=2025-13-01", wantErr: "invalid to"},
+		{name: "reversed", query: "from=2025-12-02&to=2025-12-01", wantErr: "from must be before to"},
+		{name: "zero limit", query: "limit=0", wantErr: "invalid limit"},
+		{name: "non-numeric limit", query: "limit=all", wantErr: "invalid limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			q, err := parseHistoryQuery(values, tz)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.wantFrom.Equal(q.from), "from %v", q.from)
+			assert.True(t, tt.wantUntil.Equal(q.until), "until %v", q.until)
+			assert.Equal(t, tt.wantLimit, q.limit)
+		})
+	}
+}
+
+func TestHistoryQuery_Filters(t *testing.T) {
+	h := testHistory(t)
+	query := func(s string) historyQuery {
+		values, err := url.ParseQuery(s)
+		require.NoError(t, err)
+		q, err := parseHistoryQuery(values, time.UTC)
+		require.NoError(t, err)
+		return q
+	}
+	starts := func(periods []UsagePeriod) []string {
+		out := []string{}
+		for _, p := range periods {
+			out = append(out, p.Start)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"2025-12-01", "2025-12-02"}, starts(query("from=2025-12-01&to=2025-12-02").periods(h.Daily)))
+	assert.Equal(t, []string{"2025-12-02", "2025-12-03"}, starts(query("limit=2").periods(h.Daily)), "limit keeps the latest")
+	assert.Equal(t, []string{"2025-12"}, starts(query("from=2025-12").periods(h.Monthly)))
+
+	sessions := query("limit=1").sessions(h.Sessions)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "s2", sessions[0].ID)
+	assert.Empty(t, query("to=2025-11-30").sessions(h.Sessions))
+
+	all := query("").models(h.Daily)
+	require.Len(t, all, 2)
+	assert.Equal(t, "claude-sonnet-4", all[0].Model)
+	assert.InDelta(t, 5.0, all[0].CostUSD, 1e-9)
+	assert.Equal(t, 4, all[0].Requests)
+	assert.Equal(t, "claude-opus-4-1", all[1].Model)
+
+	decemberSonnet := query("from=2025-12-03").models(h.Daily)
+	require.Len(t, decemberSonnet, 1)
+	assert.InDelta(t, 1.0, decemberSonnet[0].CostUSD, 1e-9)
+	top := query("limit=1").models(h.Daily)
+	require.Len(t, top, 1)
+	assert.Equal(t, "claude-sonnet-4", top[0].Model)
+	assert.InDelta(t, 5.0, top[0].CostUSD, 1e-9, "ranked over the whole range, not the last day")
+}
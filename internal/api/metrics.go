@@ -0,0 +1,177 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reasons a request is refused before reaching its handler
+const (
+	rejectAllowlist = "allowlist" // client address not in the allowlist
+	rejectAuth      = "auth"      // missing or unknown token
+	rejectScope     = "scope"     // token lacks the route's scope
+	rejectRate      = "rate"      // client over its rate limit
+)
+
+var rejectReasons = []string{rejectAllowlist, rejectAuth, rejectScope, rejectRate}
+
+// requestInfo is what authorise learns about a request, for the access log
+type requestInfo struct {
+	client string // token name, "unix" for the socket, or the client address
+	token  string
+	reject string
+}
+
+type requestInfoKey struct{}
+
+// infoFor returns r's requestInfo, or a throwaway one for requests that didn't
+// come through the mux (handlers called directly in tests)
+func infoFor(r *http.Request) *requestInfo {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		return info
+	}
+	return &requestInfo{}
+}
+
+// statusRecorder captures the status and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(p)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.NewResponseController reach the connection, for the long
+// poll's write deadline
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// requestKey labels a request counter
+type requestKey struct {
+	route string
+	code  int
+}
+
+// apiMetrics counts requests by route and status, and refusals by reason,
+// since startup
+type apiMetrics struct {
+	mu         sync.Mutex
+	requests   map[requestKey]int64
+	rejections map[string]int64
+}
+
+func newAPIMetrics() *apiMetrics {
+	return &apiMetrics{requests: make(map[requestKey]int64), rejections: make(map[string]int64)}
+}
+
+func (m *apiMetrics) record(route string, code int, reject string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{route, code}]++
+	if reject != "" {
+		m.rejections[reject]++
+	}
+}
+
+// instrument wraps a route's handler to count it and write an access log line
+func (s *Server) instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{}
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+
+		status := cmp.Or(rec.status, http.StatusOK)
+		s.metrics.record(route, status, info.reject)
+		attrs := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", rec.bytes,
+			"duration", time.Since(start).Round(time.Microsecond),
+			"client", info.client,
+		}
+		if info.token != "" {
+			attrs = append(attrs, "token", info.token)
+		}
+		if info.reject != "" {
+			attrs = append(attrs, "rejected", info.reject)
+		}
+		slog.Info("api request", attrs...)
+	}
+}
+
+// handleMetrics serves request counters and current usage in the Prometheus
+// text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	s.metrics.mu.Lock()
+	keys := make([]requestKey, 0, len(s.metrics.requests))
+	for k := range s.metrics.requests {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b requestKey) int {
+		return cmp.Or(strings.Compare(a.route, b.route), a.code-b.code)
+	})
+	b.WriteString("# HELP ccu_api_requests_total API requests by route and status code.\n# TYPE ccu_api_requests_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "ccu_api_requests_total{route=%q,code=\"%d\"} %d\n", k.route, k.code, s.metrics.requests[k])
+	}
+	b.WriteString("# HELP ccu_api_rejections_total API requests refused by the allowlist, authentication, token scope or rate limit.\n# TYPE ccu_api_rejections_total counter\n")
+	for _, reason := range rejectReasons {
+		fmt.Fprintf(&b, "ccu_api_rejections_total{reason=%q} %d\n", reason, s.metrics.rejections[reason])
+	}
+	s.metrics.mu.Unlock()
+
+	s.mu.RLock()
+	d, haveDisplay := s.displayData, !s.display.at.IsZero()
+	s.mu.RUnlock()
+	if haveDisplay {
+		for _, g := range []struct {
+			name, help string
+			value      float64
+		}{
+			{"ccu_session_utilisation_pct", "Session usage as a percentage of the plan limit.", d.SessionPct},
+			{"ccu_session_cost_usd", "Cost of the current session.", d.SessionCostUSD},
+			{"ccu_today_cost_usd", "Cost so far today.", d.TodayCostUSD},
+			{"ccu_weekly_utilisation_pct", "Weekly usage as a percentage, from OAuth.", d.WeeklyPct},
+			{"ccu_burn_rate_usd_per_hour", "Current burn rate.", d.BurnUSDPerHour},
+		} {
+			if g.value < 0 {
+				continue // unknown
+			}
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", g.name, g.help, g.name, g.name, g.value)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		log.Printf("api: write error (metrics response): %v", err)
+	}
+}
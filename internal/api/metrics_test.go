@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Metrics(t *testing.T) {
+	s := newTestServer(models.APIConfig{AllowedCIDRs: []string{"10.0.0.0/8"}, TokensFile: testTokensFile(t), RateLimit: 0.001, RateBurst: 3})
+	s.UpdateSnapshot([]byte(`{"schema_version":1}`))
+	s.UpdateDisplay(DisplayResponse{SchemaVersion: 1, SessionPct: 42.5, TodayCostUSD: 3.25, WeeklyPct: -1, BurnUSDPerHour: 2.88})
+	mux := s.handler()
+	get := func(path, remoteAddr, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusOK, get("/api/v1/status", "10.0.0.1:1", esp32Token))
+	require.Equal(t, http.StatusOK, get("/api/status", "10.0.0.1:1", esp32Token))
+	require.Equal(t, http.StatusForbidden, get("/api/v1/status", "192.168.1.1:1", esp32Token))
+	require.Equal(t, http.StatusUnauthorized, get("/api/v1/status", "10.0.0.2:1", "wrong"))
+	require.Equal(t, http.StatusForbidden, get("/api/v1/sessions", "10.0.0.1:1", esp32Token))
+	require.Equal(t, http.StatusTooManyRequests, get("/api/v1/status", "10.0.0.1:1", esp32Token))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/metrics", nil)
+	req.RemoteAddr = "10.0.0.5:1"
+	req.Header.Set("Authorization", "Bearer "+grafanaToken)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rr.Header().Get("Content-Type"))
+
+	body := rr.Body.String()
+	for _, line := range []string{
+		"# TYPE ccu_api_requests_total counter\n",
+		`ccu_api_requests_total{route="legacy/status",code="200"} 1` + "\n",
+		`ccu_api_requests_total{route="status",code="200"} 1` + "\n",
+		`ccu_api_requests_total{route="status",code="403"} 1` + "\n",
+		`ccu_api_requests_total{route="status",code="429"} 1` + "\n",
+		`ccu_api_rejections_total{reason="allowlist"} 1` + "\n",
+		`ccu_api_rejections_total{reason="auth"} 1` + "\n",
+		`ccu_api_rejections_total{reason="scope"} 1` + "\n",
+		`ccu_api_rejections_total{reason="rate"} 1` + "\n",
+		"ccu_session_utilisation_pct 42.5\n",
+		"ccu_today_cost_usd 3.25\n",
+		"ccu_burn_rate_usd_per_hour 2.88\n",
+	} {
+		assert.Contains(t, body, line)
+	}
+	assert.NotContains(t, body, "ccu_weekly_utilisation_pct", "unknown values are left out")
+	assert.NotContains(t, body, `route="metrics"`, "a request is counted once it completes")
+}
+
+func TestServer_AccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	s := newTestServer(models.APIConfig{TokensFile: testTokensFile(t)})
+	s.UpdateSnapshot([]byte(`{"schema_version":1}`))
+	mux := s.handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Authorization", "Bearer "+esp32Token)
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/sessions", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Authorization", "Bearer "+esp32Token)
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	log := buf.String()
+	assert.Contains(t, log, `msg="api request" method=GET path=/api/v1/status status=200`)
+	assert.Contains(t, log, "client=10.0.0.1 token=esp32\n")
+	assert.Contains(t, log, "path=/api/v1/sessions status=403")
+	assert.Contains(t, log, "token=esp32 rejected=scope\n")
+	assert.NotContains(t, log, esp32Token, "secrets are never logged")
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// openAPIDocument is the checked-in OpenAPI document served at
+// /api/openapi.json. It is generated from the route table and the response
+// types by GenerateOpenAPI; a test fails when it is out of date, and
+// `make openapi` rewrites it.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// GenerateOpenAPI builds the OpenAPI 3.1 document for the API from the route
+// table and, by reflection, the JSON shape of each response type
+func GenerateOpenAPI() ([]byte, error) {
+	g := &schemaGenerator{schemas: make(map[string]any)}
+	errorRef := g.schema(reflect.TypeFor[ErrorResponse]())
+
+	paths := make(map[string]any)
+	for _, rt := range routes {
+		media := map[string]any{"type": "string", "format": "binary"}
+		if strings.HasPrefix(rt.media, "text/") {
+			media = map[string]any{"type": "string"}
+		}
+		ok := map[string]any{
+			"description": "OK",
+			"content":     map[string]any{rt.media: map[string]any{"schema": media}},
+		}
+		if rt.response != nil {
+			ok = jsonResponse("OK", g.schema(reflect.TypeOf(rt.response)))
+		}
+		op := map[string]any{
+			"operationId": operationID.Replace(rt.name),
+			"summary":     rt.summary,
+			// Bearer scopes name the token scope the route needs
+			"security": []any{map[string]any{}, map[string]any{"bearerAuth": []any{rt.scope}}},
+			"responses": map[string]any{
+				"200": ok,
+				"401": jsonResponse("Missing or unknown bearer token", errorRef),
+				"403": jsonResponse(fmt.Sprintf("Client address not in the allowlist, or the token lacks the %s scope", rt.scope), errorRef),
+				"429": rateLimitedResponse(errorRef),
+				"503": jsonResponse("No data loaded yet", errorRef),
+			},
+		}
+		responses := op["responses"].(map[string]any)
+		if rt.params != nil {
+			op["parameters"] = rt.params()
+			responses["400"] = jsonResponse("Invalid query parameter", errorRef)
+		}
+		if rt.pattern != "" {
+			responses["404"] = jsonResponse("Unknown path parameter", errorRef)
+		}
+		if rt.validators {
+			ok["headers"] = validatorHeaders()
+			responses["304"] = map[string]any{"description": "Not modified since the ETag or date the client sent", "headers": validatorHeaders()}
+		}
+		if rt.text {
+			ok["content"].(map[string]any)["text/plain"] = map[string]any{
+				"schema": map[string]any{"type": "string", "description": "One key=value line per JSON field, in the same order; booleans are 1 or 0"},
+			}
+		}
+		paths[versionedPath(rt.name)] = map[string]any{"get": op}
+
+		if rt.legacy {
+			legacy := make(map[string]any, len(op)+1)
+			for k, v := range op {
+				legacy[k] = v
+			}
+			legacy["operationId"] = "legacy_" + op["operationId"].(string)
+			legacy["deprecated"] = true
+			legacy["description"] = fmt.Sprintf("Deprecated alias of %s since %s, which may be removed after %s. Served with Deprecation, Sunset and Link headers.",
+				versionedPath(rt.name), legacyDeprecated.Format(time.DateOnly), legacySunset.Format(time.DateOnly))
+			paths["/api/"+rt.name] = map[string]any{"get": legacy}
+		}
+	}
+	paths["/api/openapi.json"] = map[string]any{"get": map[string]any{
+		"operationId": "openapi",
+		"summary":     "This document",
+		"responses": map[string]any{
+			"200": map[string]any{"description": "OK", "content": map[string]any{"application/json": map[string]any{}}},
+			"401": jsonResponse("Missing or unknown bearer token", errorRef),
+			"403": jsonResponse("Client address not in the allowlist", errorRef),
+			"429": rateLimitedResponse(errorRef),
+		},
+	}}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "ccu API",
+			"version":     fmt.Sprint(SchemaVersion),
+			"description": "Claude Code usage served by ccu -api. Every response carries schema_version, which matches the /api/v<N>/ prefix. Fields are only added within a version.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer",
+					"description": "-api-token, or a token from the tokens file. Tokens file entries are limited to their scopes: status, history, metrics or admin (all routes)."},
+			},
+		},
+		// Auth is only required when a token is configured
+		"security": []any{map[string]any{}, map[string]any{"bearerAuth": []any{}}},
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding OpenAPI document: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// operationID turns a route name into an operationId
+var operationID = strings.NewReplacer("/", "_", ".", "_", "{", "", "}", "")
+
+func jsonResponse(description string, schema any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{"application/json": map[string]any{"schema": schema}},
+	}
+}
+
+func rateLimitedResponse(errorRef map[string]any) map[string]any {
+	r := jsonResponse("Client over its rate limit", errorRef)
+	r["headers"] = map[string]any{
+		"Retry-After": map[string]any{"schema": map[string]any{"type": "integer"}, "description": "Seconds until a request would be allowed"},
+	}
+	return r
+}
+
+func historyParameters() []any {
+	bound := map[string]any{"type": "string", "examples": []any{"2026-02-18", "2026-02", "2026-02-18T09:00:00Z"}}
+	return []any{
+		map[string]any{"name": "from", "in": "query", "schema": bound,
+			"description": "Start of the range, inclusive: YYYY-MM-DD, YYYY-MM or RFC 3339. Dates and months are in the configured timezone."},
+		map[string]any{"name": "to", "in": "query", "schema": bound,
+			"description": "End of the range, inclusive: YYYY-MM-DD, YYYY-MM (the whole month) or RFC 3339."},
+		map[string]any{"name": "limit", "in": "query", "schema": map[string]any{"type": "integer", "minimum": 1},
+			"description": "Maximum items: the most recent sessions or periods, or the highest cost models over the whole range."},
+	}
+}
+
+func statusParameters() []any {
+	return append([]any{
+		map[string]any{"name": "wait", "in": "query", "schema": map[string]any{"type": "string", "examples": []any{"30s", "30"}},
+			"description": fmt.Sprintf("Long poll: hold the request until a snapshot newer than If-None-Match (or, without it, the current one) exists, for up to this duration or number of seconds (at most %s). On timeout the current snapshot is served, so with If-None-Match the response is 304.", maxStatusWait)},
+	}, conditionalHeaders()...)
+}
+
+func displayParameters() []any {
+	return append([]any{
+		map[string]any{"name": "format", "in": "query", "schema": map[string]any{"type": "string", "enum": []any{"json", "text"}, "default": "json"},
+			"description": "json, or text for one key=value line per field"},
+	}, conditionalHeaders()...)
+}
+
+func badgeParameters() []any {
+	return append([]any{
+		map[string]any{"name": "metric", "in": "path", "required": true,
+			"schema":      map[string]any{"type": "string", "enum": []any{"session", "today", "weekly"}},
+			"description": "session or weekly usage %, or today's cost"},
+		map[string]any{"name": "label", "in": "query", "schema": map[string]any{"type": "string"},
+			"description": "Replaces the badge's left-hand text"},
+	}, conditionalHeaders()...)
+}
+
+func cardParameters() []any {
+	size := func(def int) map[string]any {
+		return map[string]any{"type": "integer", "minimum": cardMinSize, "maximum": cardMaxSize, "default": def}
+	}
+	return append([]any{
+		map[string]any{"name": "width", "in": "query", "schema": size(cardDefaultWidth), "description": "Width in pixels"},
+		map[string]any{"name": "height", "in": "query", "schema": size(cardDefaultHeight), "description": "Height in pixels"},
+		map[string]any{"name": "palette", "in": "query",
+			"schema":      map[string]any{"type": "string", "enum": []any{paletteColour, paletteGrey, palette1Bit}, "default": paletteColour},
+			"description": "colour, grey (8-bit greyscale) or 1bit (black on white, for e-ink)"},
+	}, conditionalHeaders()...)
+}
+
+func conditionalHeaders() []any {
+	return []any{
+		map[string]any{"name": "If-None-Match", "in": "header", "schema": map[string]any{"type": "string"},
+			"description": "ETag of the snapshot the client has; answered with 304 if it is still current."},
+		map[string]any{"name": "If-Modified-Since", "in": "header", "schema": map[string]any{"type": "string"},
+			"description": "Last-Modified of the snapshot the client has; ignored when If-None-Match is sent."},
+	}
+}
+
+func validatorHeaders() map[string]any {
+	return map[string]any{
+		"ETag":          map[string]any{"schema": map[string]any{"type": "string"}, "description": "Changes whenever the snapshot does"},
+		"Last-Modified": map[string]any{"schema": map[string]any{"type": "string"}, "description": "When the snapshot was last updated"},
+	}
+}
+
+// schemaGenerator builds JSON Schemas for Go types, collecting named structs
+// under components/schemas
+type schemaGenerator struct {
+	schemas map[string]any
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// schema returns the schema for t, following encoding/json's rules: pointers
+// are transparent, omitempty fields are optional and unexported or "-" fields
+// are left out. It panics on a type with no JSON mapping, which the document
+// test catches.
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s := map[string]any{"type": "integer"}
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Int {
+			s["format"] = "int64"
+		}
+		return s
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+		if _, done := g.schemas[t.Name()]; !done {
+			g.schemas[t.Name()] = nil // placeholder so recursive types terminate
+			g.schemas[t.Name()] = g.object(t)
+		}
+		return ref
+	}
+	panic(fmt.Sprintf("api: no OpenAPI schema for %s", t))
+}
+
+// object returns the schema for a struct's JSON fields
+func (g *schemaGenerator) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := []string{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = g.schema(f.Type)
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			required = append(required, name)
+		}
+	}
+	// Extra properties are allowed: fields may be added within a version
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
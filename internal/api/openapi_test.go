@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateOpenAPI = flag.Bool("update", false, "rewrite openapi.json from the Go types")
+
+// TestOpenAPIDocument fails when openapi.json no longer matches the route
+// table and response types. Run `make openapi` to regenerate it.
+func TestOpenAPIDocument(t *testing.T) {
+	generated, err := GenerateOpenAPI()
+	require.NoError(t, err)
+	if *updateOpenAPI {
+		require.NoError(t, os.WriteFile("openapi.json", generated, 0o644))
+		return
+	}
+	assert.Equal(t, string(generated), string(openAPIDocument), "openapi.json is stale: run `make openapi`")
+}
+
+func TestOpenAPIDocument_CoversRoutes(t *testing.T) {
+	var doc struct {
+		Info  struct{ Version string }
+		Paths map[string]struct {
+			Get struct {
+				Deprecated bool
+			}
+		}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any
+				Required   []string
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(openAPIDocument, &doc))
+	assert.Equal(t, "1", doc.Info.Version)
+
+	// Every documented path is served, and only legacy aliases are deprecated
+	s := newTestServer(models.APIConfig{})
+	s.UpdateSnapshot([]byte(`{"schema_version":1}`))
+	s.UpdateHistory(BuildHistory(nil, nil, time.UTC))
+	s.UpdateDisplay(DisplayResponse{SchemaVersion: SchemaVersion})
+	mux := s.handler()
+	for path, item := range doc.Paths {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, strings.ReplaceAll(path, "{metric}", "session"), nil))
+		assert.Equal(t, http.StatusOK, rr.Code, path)
+		assert.Equal(t, item.Get.Deprecated, rr.Header().Get("Deprecation") != "", path)
+	}
+	assert.Len(t, doc.Paths, len(routes)+2, "each route, the legacy status alias and the document itself")
+
+	// Every top-level response carries the schema version
+	for _, rt := range routes {
+		if rt.response == nil {
+			continue // media routes
+		}
+		name := reflect.TypeOf(rt.response).Name()
+		require.Contains(t, doc.Components.Schemas, name)
+		assert.Contains(t, doc.Components.Schemas[name].Required, "schema_version", name)
+	}
+
+	status := doc.Components.Schemas["StatusResponse"]
+	assert.Contains(t, status.Properties, "weekly")
+	assert.NotContains(t, status.Required, "weekly", "omitempty fields are optional")
+	assert.NotContains(t, doc.Components.Schemas["SessionSummary"].Properties, "start", "unexported fields are left out")
+}
+
+func TestSchemaGenerator(t *testing.T) {
+	type inner struct {
+		N int `json:"n"`
+	}
+	type sample struct {
+		S       string            `json:"s"`
+		B       bool              `json:"b,omitempty"`
+		I64     *int64            `json:"i64,omitempty"`
+		F       float64           `json:"f"`
+		T       *time.Time        `json:"t,omitempty"`
+		List    []inner           `json:"list"`
+		ByName  map[string]*inner `json:"by_name"`
+		Skipped string            `json:"-"`
+		hidden  string
+	}
+
+	g := &schemaGenerator{schemas: make(map[string]any)}
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/sample"}, g.schema(reflect.TypeFor[sample]()))
+
+	innerRef := map[string]any{"$ref": "#/components/schemas/inner"}
+	want := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"s":       map[string]any{"type": "string"},
+			"b":       map[string]any{"type": "boolean"},
+			"i64":     map[string]any{"type": "integer", "format": "int64"},
+			"f":       map[string]any{"type": "number", "format": "double"},
+			"t":       map[string]any{"type": "string", "format": "date-time"},
+			"list":    map[string]any{"type": "array", "items": innerRef},
+			"by_name": map[string]any{"type": "object", "additionalProperties": innerRef},
+		},
+		"required": []string{"s", "f", "list", "by_name"},
+	}
+	assert.Equal(t, want, g.schemas["sample"])
+	assert.Contains(t, g.schemas, "inner")
+
+	assert.Panics(t, func() { g.schema(reflect.TypeFor[chan int]()) })
+}
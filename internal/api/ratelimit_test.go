@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(2, 3) // 2/s, bursts of 3
+	now := time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)
+
+	for i := range 3 {
+		ok, _ := l.allow("a", now)
+		assert.True(t, ok, "burst request %d", i+1)
+	}
+	ok, wait := l.allow("a", now)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait, "one request refills in 1/rate seconds")
+
+	ok, _ = l.allow("b", now)
+	assert.True(t, ok, "clients have separate buckets")
+
+	ok, _ = l.allow("a", now.Add(500*time.Millisecond))
+	assert.True(t, ok, "refilled")
+	ok, _ = l.allow("a", now.Add(500*time.Millisecond))
+	assert.False(t, ok)
+
+	// Idle buckets are pruned once full
+	l.allow("a", now.Add(2*time.Minute))
+	assert.NotContains(t, l.buckets, "b")
+
+	unlimited := newRateLimiter(0, 0)
+	for range 1000 {
+		ok, _ := unlimited.allow("a", now)
+		require.True(t, ok)
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, 1, retryAfterSeconds(0))
+	assert.Equal(t, 1, retryAfterSeconds(200*time.Millisecond))
+	assert.Equal(t, 2, retryAfterSeconds(1500*time.Millisecond))
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	s := newTestServer(models.APIConfig{RateLimit: 0.001, RateBurst: 2, TokensFile: testTokensFile(t)})
+	s.UpdateSnapshot([]byte(`{"schema_version":1}`))
+	mux := s.handler()
+	get := func(remoteAddr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Limited by token, wherever the requests come from
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1000", esp32Token).Code)
+	assert.Equal(t, http.StatusOK, get("10.0.0.2:1000", esp32Token).Code)
+	rr := get("10.0.0.3:1000", esp32Token)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1000", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"rate limit exceeded"}`, rr.Body.String())
+	assert.Equal(t, http.StatusOK, get("10.0.0.3:1000", adminToken).Code, "other tokens are unaffected")
+
+	// Without a valid token, limited by address so guesses are slowed too
+	assert.Equal(t, http.StatusUnauthorized, get("10.0.0.9:1000", "guess-1").Code)
+	assert.Equal(t, http.StatusUnauthorized, get("10.0.0.9:2000", "guess-2").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.9:3000", "guess-3").Code)
+}
@@ -1,32 +1,172 @@
 package api
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/sammcj/ccu/internal/models"
 )
 
+// route is one versioned API endpoint. The table drives both the mux and the
+// OpenAPI document, so the two can't drift apart.
+type route struct {
+	name       string // path under /api/v1/, as documented
+	pattern    string // mux pattern under /api/v1/, when it differs from name
+	summary    string
+	scope      string       // token scope required when tokens are configured, checked before handle runs
+	response   any          // zero value of the 200 JSON response body, or nil for media
+	media      string       // content type of a non-JSON 200 response
+	params     func() []any // OpenAPI parameters the route accepts; a bad one is a 400
+	validators bool         // sends ETag and Last-Modified, answering conditional requests with 304
+	text       bool         // ?format=text serves the response as key=value lines
+	legacy     bool         // also served, deprecated, at the unversioned /api/ path
+	handle     func(*Server, http.ResponseWriter, *http.Request)
+}
+
+// The unversioned aliases of legacy routes were deprecated when the versioned
+// routes were added, and may be removed after the sunset date
+var (
+	legacyDeprecated = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	legacySunset     = time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC)
+)
+
+var routes = []route{
+	{
+		name:       "status",
+		scope:      ScopeStatus,
+		summary:    "Current plan, weekly and session usage, burn rate and predictions",
+		response:   StatusResponse{},
+		params:     statusParameters,
+		validators: true,
+		legacy:     true,
+		handle:     (*Server).handleStatus,
+	},
+	{
+		name:     "sessions",
+		scope:    ScopeHistory,
+		summary:  "5-hour session blocks with per-model stats, newest first",
+		response: SessionsResponse{},
+		params:   historyParameters,
+		handle:   (*Server).handleSessions,
+	},
+	{
+		name:     "usage/daily",
+		scope:    ScopeHistory,
+		summary:  "Usage per day in the configured timezone, oldest first",
+		response: UsageResponse{},
+		params:   historyParameters,
+		handle:   (*Server).handleDaily,
+	},
+	{
+		name:     "usage/monthly",
+		scope:    ScopeHistory,
+		summary:  "Usage per month in the configured timezone, oldest first",
+		response: UsageResponse{},
+		params:   historyParameters,
+		handle:   (*Server).handleMonthly,
+	},
+	{
+		name:     "models",
+		scope:    ScopeHistory,
+		summary:  "Per-model totals over the range, highest cost first",
+		response: ModelsResponse{},
+		params:   historyParameters,
+		handle:   (*Server).handleModels,
+	},
+	{
+		name:       "display",
+		scope:      ScopeStatus,
+		summary:    "Flat, fixed-key summary for microcontrollers and e-ink displays",
+		response:   DisplayResponse{},
+		params:     displayParameters,
+		validators: true,
+		text:       true,
+		handle:     (*Server).handleDisplay,
+	},
+	{
+		name:       "badge/{metric}.svg",
+		scope:      ScopeStatus,
+		pattern:    "badge/{file}",
+		summary:    "Shields-style SVG badge of session %, weekly % or today's cost",
+		media:      "image/svg+xml",
+		params:     badgeParameters,
+		validators: true,
+		handle:     (*Server).handleBadge,
+	},
+	{
+		name:       "card.png",
+		scope:      ScopeStatus,
+		summary:    "PNG card of the usage bars at a chosen size and palette, for dashboards and e-ink",
+		media:      "image/png",
+		params:     cardParameters,
+		validators: true,
+		handle:     (*Server).handleCard,
+	},
+	{
+		name:    "metrics",
+		summary: "Request counts by route and status, refusals by reason and current usage, in the Prometheus text format",
+		scope:   ScopeMetrics,
+		media:   "text/plain; version=0.0.4",
+		handle:  (*Server).handleMetrics,
+	},
+	{
+		name:     "tokens",
+		summary:  "Configured tokens with their scopes, request counts and when each was last used; never the secrets",
+		scope:    ScopeAdmin,
+		response: TokensResponse{},
+		handle:   (*Server).handleTokens,
+	},
+}
+
+// versionedPath returns the current versioned path of a route name
+func versionedPath(name string) string {
+	return fmt.Sprintf("/api/v%d/%s", SchemaVersion, name)
+}
+
 // Server is an optional embedded HTTP API server that serves a JSON snapshot
 // of CCU's current usage state. It is safe for concurrent access.
 type Server struct {
-	mu          sync.RWMutex
-	snapshot    []byte
-	snapshotAt  time.Time
-	config      models.APIConfig
-	allowedNets []*net.IPNet
-	done        chan struct{}
+	mu            sync.RWMutex
+	status        snapshot
+	displayData   DisplayResponse
+	display       snapshot                 // displayData as JSON
+	displayText   snapshot                 // displayData as key=value lines
+	cards         map[cardRequest]snapshot // rendered card.png, cleared with display
+	statusChanged chan struct{}            // closed and replaced when status changes
+	history       *History
+	config        models.APIConfig
+	tokens        *tokenStore
+	limiter       *rateLimiter
+	metrics       *apiMetrics
+	allowedNets   []*net.IPNet
+	done          chan struct{}
 }
 
 // New creates a new Server with the given configuration.
 // CIDR ranges are parsed eagerly so any configuration errors are caught at startup.
 func New(cfg models.APIConfig) *Server {
-	s := &Server{config: cfg, done: make(chan struct{})}
+	s := &Server{
+		config:        cfg,
+		tokens:        newTokenStore(cfg.Token, cfg.TokensFile),
+		limiter:       newRateLimiter(cfg.RateLimit, cfg.RateBurst),
+		metrics:       newAPIMetrics(),
+		statusChanged: make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	// An unreadable tokens file fails closed: only the -api-token is accepted
+	// until a valid file is loaded
+	if err := s.tokens.load(); err != nil {
+		log.Printf("api: %v", err)
+	}
 	for _, cidr := range cfg.AllowedCIDRs {
 		_, ipNet, err := net.ParseCIDR(cidr)
 		if err != nil {
@@ -38,58 +178,150 @@ func New(cfg models.APIConfig) *Server {
 	return s
 }
 
-// UpdateSnapshot replaces the cached JSON snapshot. Safe to call from any goroutine.
-func (s *Server) UpdateSnapshot(data []byte) {
+// UpdateHistory replaces the snapshot the session, usage and model endpoints
+// serve from. Safe to call from any goroutine.
+func (s *Server) UpdateHistory(h *History) {
 	s.mu.Lock()
-	s.snapshot = data
-	s.snapshotAt = time.Now()
+	s.history = h
 	s.mu.Unlock()
 }
 
-// Start listens on the configured address and serves requests until ctx is cancelled.
+// History returns the current history snapshot, or nil before the first
+// refresh
+func (s *Server) History() *History {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.history
+}
+
+// Start listens on the configured address and Unix socket, if any, and
+// serves requests on both until ctx is cancelled.
 func (s *Server) Start(ctx context.Context) error {
 	// Signal shutdown completion so callers can wait for a clean stop.
 	defer close(s.done)
 
-	if len(s.allowedNets) == 0 && s.config.Token == "" {
+	tcp := s.config.Port != 0
+	if !tcp && s.config.SocketPath == "" {
+		return errors.New("api: no port or socket to listen on")
+	}
+	if tcp && len(s.allowedNets) == 0 && !s.tokens.required() && s.config.TLSClientCA == "" {
 		log.Printf("api: WARNING – server is unauthenticated and open to all hosts (consider setting -api-token or -api-allow)")
 	}
 
-	addr := fmt.Sprintf("%s:%d", s.config.BindAddr, s.config.Port)
+	tlsCfg, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/api/status", s.handleStatus)
+	// One server per listener: the socket never uses TLS and marks its
+	// requests so they skip the network checks
+	var servers []*http.Server
+	serve := make(chan error, 2)
+	handler := s.handler()
+	newServer := func() *http.Server {
+		srv := &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       10 * time.Second,
+			WriteTimeout:      10 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			// Request contexts end with ctx, so long-poll requests return on shutdown
+			BaseContext: func(net.Listener) context.Context { return ctx },
+		}
+		servers = append(servers, srv)
+		return srv
+	}
+	defer func() {
+		for _, srv := range servers {
+			srv.Close()
+		}
+	}()
 
-	srv := &http.Server{
-		Addr:              addr,
-		Handler:           mux,
-		ReadHeaderTimeout: 5 * time.Second,
-		ReadTimeout:       10 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		IdleTimeout:       60 * time.Second,
+	if s.config.SocketPath != "" {
+		ln, err := listenUnix(s.config.SocketPath)
+		if err != nil {
+			return err
+		}
+		srv := newServer()
+		srv.ConnContext = markSocketConn
+		log.Printf("api: listening on unix:%s", s.config.SocketPath)
+		go func() { serve <- srv.Serve(ln) }()
 	}
 
-	ln, err := net.Listen("tcp", addr)
-	if err != nil {
-		return fmt.Errorf("api: listen %s: %w", addr, err)
+	if tcp {
+		addr := fmt.Sprintf("%s:%d", s.config.BindAddr, s.config.Port)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("api: listen %s: %w", addr, err)
+		}
+		srv := newServer()
+		srv.Addr, srv.TLSConfig = addr, tlsCfg
+
+		scheme := "http"
+		if tlsCfg != nil {
+			scheme = "https"
+			if tlsCfg.ClientCAs != nil {
+				log.Printf("api: requiring client certificates signed by %s", s.config.TLSClientCA)
+			}
+		}
+		log.Printf("api: listening on %s://%s", scheme, addr)
+		go func() {
+			if tlsCfg != nil {
+				// The certificate is in TLSConfig, so no files are passed here
+				serve <- srv.ServeTLS(ln, "", "")
+				return
+			}
+			serve <- srv.Serve(ln)
+		}()
 	}
 
-	log.Printf("api: listening on %s", addr)
+	go s.tokens.watch(ctx, tokensPollInterval)
 
-	// Shut down gracefully when ctx is cancelled
-	go func() {
-		<-ctx.Done()
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+	// Serve until ctx is cancelled or a listener fails, then shut every
+	// server down gracefully
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case err := <-serve:
+		serveErr = fmt.Errorf("api: serve: %w", err)
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, srv := range servers {
 		if err := srv.Shutdown(shutdownCtx); err != nil {
 			log.Printf("api: shutdown error: %v", err)
 		}
-	}()
+	}
+	return serveErr
+}
 
-	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("api: serve: %w", err)
+// handler routes every endpoint: the versioned routes, their deprecated
+// unversioned aliases and the OpenAPI document
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range routes {
+		handle, scope := rt.handle, rt.scope
+		serve := func(w http.ResponseWriter, r *http.Request) {
+			if !s.authorise(w, r, scope) {
+				return
+			}
+			handle(s, w, r)
+		}
+		path := versionedPath(rt.name)
+		mux.HandleFunc(versionedPath(cmp.Or(rt.pattern, rt.name)), s.instrument(rt.name, serve))
+		if rt.legacy {
+			mux.HandleFunc("/api/"+rt.name, s.instrument("legacy/"+rt.name, func(w http.ResponseWriter, r *http.Request) {
+				// RFC 9745 deprecation (a structured-field date) and RFC 8594
+				// sunset, pointing clients at the replacement
+				w.Header().Set("Deprecation", fmt.Sprintf("@%d", legacyDeprecated.Unix()))
+				w.Header().Set("Sunset", legacySunset.Format(http.TimeFormat))
+				w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", path))
+				serve(w, r)
+			}))
+		}
 	}
-	return nil
+	mux.HandleFunc("/api/openapi.json", s.instrument("openapi.json", s.handleOpenAPI))
+	return mux
 }
 
 // Done returns a channel that is closed once Start has returned, i.e. the server
@@ -98,54 +330,181 @@ func (s *Server) Done() <-chan struct{} {
 	return s.done
 }
 
-// handleStatus serves the cached JSON snapshot with optional auth and IP allowlist checks.
-func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+// authorise applies the IP allowlist, bearer token and rate limit checks,
+// writing an error response and returning false if the request is refused.
+// When tokens are configured the request's token must grant scope; an empty
+// scope accepts any valid token. Clients are rate limited by token name, or by
+// address when there is no valid token.
+func (s *Server) authorise(w http.ResponseWriter, r *http.Request, scope string) bool {
+	info := infoFor(r)
+	refuse := func(reason string, status int) bool {
+		info.reject = reason
+		writeError(w, status, http.StatusText(status))
+		return false
+	}
+
+	// Only the socket's owner can connect to it, so file permissions have
+	// already done the checking
+	if viaSocket(r) {
+		info.client = "unix"
+		return s.allowRate(w, info, "unix")
+	}
+	info.client = clientHost(r.RemoteAddr)
+
 	// IP allowlist check (before auth to avoid leaking that auth exists)
 	if len(s.allowedNets) > 0 {
 		if !s.isAllowedIP(r.RemoteAddr) {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
+			return refuse(rejectAllowlist, http.StatusForbidden)
 		}
 	}
 
 	// Bearer token check
-	if s.config.Token != "" {
-		token := extractBearerToken(r)
-		if token != s.config.Token {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
+	if !s.tokens.required() {
+		return s.allowRate(w, info, "addr:"+info.client)
+	}
+	token, ok := s.tokens.lookup(extractBearerToken(r))
+	if !ok {
+		// Limit guesses from an address before answering them
+		if !s.allowRate(w, info, "addr:"+info.client) {
+			return false
 		}
+		return refuse(rejectAuth, http.StatusUnauthorized)
+	}
+	info.token = token.name
+	if !s.allowRate(w, info, "token:"+token.name) {
+		return false
 	}
+	if !token.allows(scope) {
+		return refuse(rejectScope, http.StatusForbidden)
+	}
+	s.tokens.recordUse(token.name, time.Now())
+	return true
+}
 
-	s.mu.RLock()
-	data := s.snapshot
-	s.mu.RUnlock()
-
-	if len(data) == 0 {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		if _, err := w.Write([]byte(`{"error":"no data"}`)); err != nil {
-			log.Printf("api: write error (no data response): %v", err)
+// allowRate applies the rate limit to key, answering 429 with Retry-After
+// when the client is over it
+func (s *Server) allowRate(w http.ResponseWriter, info *requestInfo, key string) bool {
+	ok, wait := s.limiter.allow(key, time.Now())
+	if ok {
+		return true
+	}
+	info.reject = rejectRate
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+	writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+	return false
+}
+
+// handleStatus serves the cached JSON snapshot.
+// It answers If-None-Match and If-Modified-Since with 304 Not Modified, and
+// with ?wait=<duration> holds the request until a snapshot the client hasn't
+// seen exists.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	snap, _ := s.currentStatus()
+	if v := r.URL.Query().Get("wait"); v != "" {
+		wait, err := parseWait(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// Allow for the wait on top of the server's write timeout
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 10*time.Second)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Printf("api: extending write deadline: %v", err)
 		}
+		snap = s.waitForStatus(r.Context(), r.Header.Get("If-None-Match"), wait)
+	}
+	snap.serve(w, r, "application/json")
+}
+
+// handleSessions serves recent session blocks with per-model stats, newest first.
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	s.serveHistory(w, r, func(h *History, q historyQuery) any {
+		return SessionsResponse{SchemaVersion: SchemaVersion, Sessions: q.sessions(h.Sessions)}
+	})
+}
+
+// handleDaily serves usage per day, oldest first.
+func (s *Server) handleDaily(w http.ResponseWriter, r *http.Request) {
+	s.serveHistory(w, r, func(h *History, q historyQuery) any {
+		return UsageResponse{SchemaVersion: SchemaVersion, Period: "daily", Timezone: h.Timezone.String(), Periods: q.periods(h.Daily)}
+	})
+}
+
+// handleMonthly serves usage per month, oldest first.
+func (s *Server) handleMonthly(w http.ResponseWriter, r *http.Request) {
+	s.serveHistory(w, r, func(h *History, q historyQuery) any {
+		return UsageResponse{SchemaVersion: SchemaVersion, Period: "monthly", Timezone: h.Timezone.String(), Periods: q.periods(h.Monthly)}
+	})
+}
+
+// handleModels serves per-model totals, highest cost first.
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	s.serveHistory(w, r, func(h *History, q historyQuery) any {
+		return ModelsResponse{SchemaVersion: SchemaVersion, Models: q.models(h.Daily)}
+	})
+}
+
+// serveHistory parses the range and limit query parameters for the history
+// endpoints and writes the response build returns.
+func (s *Server) serveHistory(w http.ResponseWriter, r *http.Request, build func(*History, historyQuery) any) {
+	h := s.History()
+	if h == nil {
+		writeError(w, http.StatusServiceUnavailable, "no data")
+		return
+	}
+	q, err := parseHistoryQuery(r.URL.Query(), h.Timezone)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	data, err := json.Marshal(build(h, q))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "encoding response")
+		log.Printf("api: encode error (%s): %v", r.URL.Path, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "max-age=5")
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write(data); err != nil {
-		log.Printf("api: write error (status response): %v", err)
+		log.Printf("api: write error (%s response): %v", r.URL.Path, err)
+	}
+}
+
+// handleOpenAPI serves the OpenAPI document describing every route.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !s.authorise(w, r, "") {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(openAPIDocument); err != nil {
+		log.Printf("api: write error (openapi response): %v", err)
+	}
+}
+
+// writeError writes a JSON error body with the given status.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	data, _ := json.Marshal(ErrorResponse{Error: msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("api: write error (%d response): %v", status, err)
 	}
 }
 
+// clientHost returns the host part of a remote address
+func clientHost(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
 // isAllowedIP returns true if the remote address falls within any configured CIDR.
 func (s *Server) isAllowedIP(remoteAddr string) bool {
-	host, _, err := net.SplitHostPort(remoteAddr)
-	if err != nil {
-		// RemoteAddr without port (unusual but possible in tests)
-		host = remoteAddr
-	}
-	ip := net.ParseIP(host)
+	// RemoteAddr without port is unusual but possible in tests
+	ip := net.ParseIP(clientHost(remoteAddr))
 	if ip == nil {
 		return false
 	}
@@ -42,9 +42,9 @@ func TestServer_ConcurrentRequests(t *testing.T) {
 	for range goroutines {
 		go func() {
 			defer wg.Done()
-			req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
 			rr := httptest.NewRecorder()
-			s.handleStatus(rr, req)
+			s.handler().ServeHTTP(rr, req)
 			assert.Equal(t, http.StatusOK, rr.Code)
 		}()
 	}
@@ -111,12 +111,12 @@ func TestServer_AuthToken(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
 			if tt.authHeader != "" {
 				req.Header.Set("Authorization", tt.authHeader)
 			}
 			rr := httptest.NewRecorder()
-			s.handleStatus(rr, req)
+			s.handler().ServeHTTP(rr, req)
 			assert.Equal(t, tt.wantStatus, rr.Code)
 		})
 	}
@@ -126,9 +126,9 @@ func TestServer_NoSnapshotReturns503(t *testing.T) {
 	s := newTestServer(models.APIConfig{})
 	// No UpdateSnapshot call
 
-	req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
 	rr := httptest.NewRecorder()
-	s.handleStatus(rr, req)
+	s.handler().ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
 	body, _ := io.ReadAll(rr.Body)
@@ -154,10 +154,10 @@ func TestServer_IPAllowlist(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
 			req.RemoteAddr = tt.remoteAddr
 			rr := httptest.NewRecorder()
-			s.handleStatus(rr, req)
+			s.handler().ServeHTTP(rr, req)
 			assert.Equal(t, tt.wantStatus, rr.Code)
 		})
 	}
@@ -172,10 +172,79 @@ func TestServer_IPAllowlistEmpty(t *testing.T) {
 
 	// Any remote address should be allowed when allowlist is empty
 	for _, addr := range []string{"1.2.3.4:5678", "192.168.1.1:9999", "10.0.0.1:1234"} {
-		req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
 		req.RemoteAddr = addr
 		rr := httptest.NewRecorder()
-		s.handleStatus(rr, req)
+		s.handler().ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusOK, rr.Code, "addr %s should be allowed", addr)
 	}
 }
+
+func TestServer_HistoryEndpoints(t *testing.T) {
+	s := newTestServer(models.APIConfig{Token: "secret"})
+
+	mux := s.handler()
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/api/v1/sessions", "secret")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "no history before the first refresh")
+	assert.JSONEq(t, `{"error":"no data"}`, rr.Body.String())
+
+	s.UpdateHistory(testHistory(t))
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"unauthorised", "/api/v1/models", "", http.StatusUnauthorized, ""},
+		{"sessions", "/api/v1/sessions?limit=1", "secret", http.StatusOK, `"id":"s2"`},
+		{"daily", "/api/v1/usage/daily?from=2025-12-02&to=2025-12-02", "secret", http.StatusOK, `"start":"2025-12-02"`},
+		{"monthly", "/api/v1/usage/monthly", "secret", http.StatusOK, `"schema_version":1,"period":"monthly","timezone":"UTC"`},
+		{"models", "/api/v1/models?limit=1", "secret", http.StatusOK, `"total_tokens":600,"cost_usd":5,"requests":4`},
+		{"bad query", "/api/v1/usage/daily?limit=-1", "secret", http.StatusBadRequest, `"error":"invalid limit`},
+		{"unversioned history routes are not served", "/api/sessions", "secret", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := get(tt.path, tt.token)
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestServer_VersionedRoutes(t *testing.T) {
+	s := newTestServer(models.APIConfig{})
+	s.UpdateSnapshot([]byte(`{"schema_version":1,"plan":"max5"}`))
+	mux := s.handler()
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/status", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Deprecation"))
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	assert.Equal(t, http.StatusOK, rr.Code, "the unversioned route still works")
+	assert.Equal(t, "@1792281600", rr.Header().Get("Deprecation"), "an RFC 9745 structured-field date")
+	assert.Equal(t, "Thu, 01 Apr 2027 00:00:00 GMT", rr.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v1/status>; rel="successor-version"`, rr.Header().Get("Link"))
+	assert.JSONEq(t, `{"schema_version":1,"plan":"max5"}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `"/api/v1/status"`)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxStatusWait caps how long a long-poll status request may block
+const maxStatusWait = 2 * time.Minute
+
+// snapshot is one serialised response and its validators
+type snapshot struct {
+	data []byte
+	etag string // quoted strong ETag, empty before the first snapshot
+	at   time.Time
+}
+
+// replace returns the snapshot for data, whose content apart from the clock
+// is key, or prev unchanged if key is the same as prev's. Keeping prev whole,
+// bytes and validators together, means a refresh that only moved the clock
+// neither wakes long polls nor defeats If-None-Match, and its countdowns stay
+// consistent with the time it reports.
+func (prev snapshot) replace(data, key []byte) snapshot {
+	etag := snapshotETag(key)
+	if etag == prev.etag {
+		return prev
+	}
+	return snapshot{data: data, etag: etag, at: time.Now()}
+}
+
+// serve writes the snapshot with its validators, answering If-None-Match and
+// If-Modified-Since with 304 Not Modified, or 503 if there is no data yet
+func (snap snapshot) serve(w http.ResponseWriter, r *http.Request, contentType string) {
+	if len(snap.data) == 0 {
+		writeError(w, http.StatusServiceUnavailable, "no data")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "max-age=5")
+	w.Header().Set("ETag", snap.etag)
+	// ServeContent sets Last-Modified and answers the conditional headers
+	http.ServeContent(w, r, "", snap.at, bytes.NewReader(snap.data))
+}
+
+// UpdateStatus replaces the status snapshot and wakes long-poll requests
+// waiting for it, unless only its clock-driven fields changed (see
+// StatusResponse.withoutClock). Safe to call from any goroutine.
+func (s *Server) UpdateStatus(status StatusResponse) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		log.Printf("api: failed to build snapshot: %v", err)
+		return
+	}
+	key, _ := json.Marshal(status.withoutClock())
+	s.updateStatus(data, key)
+}
+
+// UpdateSnapshot replaces the status snapshot with pre-serialised JSON and
+// wakes long-poll requests waiting for it. A snapshot identical to the
+// current one is ignored. Safe to call from any goroutine.
+func (s *Server) UpdateSnapshot(data []byte) {
+	s.updateStatus(data, data)
+}
+
+func (s *Server) updateStatus(data, key []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.status.replace(data, key)
+	if next.etag == s.status.etag {
+		return
+	}
+	s.status = next
+	close(s.statusChanged)
+	s.statusChanged = make(chan struct{})
+}
+
+// withoutClock returns a copy of the status with the fields that change as
+// time passes zeroed: the server time, data age, countdowns and predicted
+// limit times. The burn rate, which decays over its one-hour window, is
+// rounded to the precision displays show. What is left changes only when
+// usage, limits or OAuth data do.
+func (r StatusResponse) withoutClock() StatusResponse {
+	r.ServerTime, r.DataAgeSeconds = "", 0
+	if r.Weekly != nil {
+		weekly := *r.Weekly
+		if weekly.AllModels != nil {
+			all := *weekly.AllModels
+			all.ResetsInSeconds = 0
+			weekly.AllModels = &all
+		}
+		if weekly.Scoped != nil {
+			scoped := make(map[string]*WeeklyModelSection, len(weekly.Scoped))
+			for k, v := range weekly.Scoped {
+				m := *v
+				m.ResetsInSeconds = nil
+				scoped[k] = &m
+			}
+			weekly.Scoped = scoped
+		}
+		r.Weekly = &weekly
+	}
+	if r.Session != nil {
+		session := *r.Session
+		session.ResetsInSeconds, session.ElapsedSeconds, session.RemainingSeconds, session.RemainingPct = 0, 0, 0, 0
+		r.Session = &session
+	}
+	if r.BurnRate != nil {
+		r.BurnRate = &BurnRateSection{
+			TokensPerMin:   math.Round(r.BurnRate.TokensPerMin),
+			CostPerHourUSD: roundTo(r.BurnRate.CostPerHourUSD, 2),
+		}
+	}
+	if r.Prediction != nil {
+		r.Prediction = &PredictionSection{
+			SessionWillHitLimit: r.Prediction.SessionWillHitLimit,
+			WeeklyWillHitLimit:  r.Prediction.WeeklyWillHitLimit,
+		}
+	}
+	return r
+}
+
+// currentStatus returns the current snapshot and a channel closed when it is
+// replaced
+func (s *Server) currentStatus() (snapshot, <-chan struct{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status, s.statusChanged
+}
+
+// waitForStatus blocks until the snapshot differs from the one the client has,
+// identified by its If-None-Match header or, without one, the snapshot
+// current when the request arrived. It returns the latest snapshot once it
+// changes, wait elapses or ctx is done.
+func (s *Server) waitForStatus(ctx context.Context, ifNoneMatch string, wait time.Duration) snapshot {
+	arrived, _ := s.currentStatus()
+	have := func(etag string) bool { return etag == arrived.etag }
+	if ifNoneMatch != "" {
+		have = func(etag string) bool { return etagMatches(ifNoneMatch, etag) }
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		current, changed := s.currentStatus()
+		if !have(current.etag) {
+			return current
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return current
+		case <-ctx.Done():
+			return current
+		}
+	}
+}
+
+// snapshotETag returns a strong ETag for a snapshot's bytes
+func snapshotETag(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	h := fnv.New64a()
+	h.Write(data)
+	return fmt.Sprintf(`"%016x"`, h.Sum64())
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 requires for If-None-Match
+func etagMatches(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWait reads the wait query parameter: a Go duration ("30s", "2m") or a
+// number of seconds, capped at maxStatusWait
+func parseWait(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		secs, serr := strconv.Atoi(v)
+		if serr != nil {
+			return 0, fmt.Errorf("invalid wait %q: use a duration such as 30s", v)
+		}
+		d = time.Duration(secs) * time.Second
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid wait %q: must not be negative", v)
+	}
+	return min(d, maxStatusWait), nil
+}
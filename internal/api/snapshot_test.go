@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateSnapshot(t *testing.T) {
+	s := newTestServer(models.APIConfig{})
+	empty, _ := s.currentStatus()
+	assert.Empty(t, empty.etag)
+
+	s.UpdateSnapshot([]byte(`{"plan":"max5"}`))
+	first, changed := s.currentStatus()
+	assert.Regexp(t, `^"[0-9a-f]{16}"$`, first.etag)
+	assert.False(t, first.at.IsZero())
+
+	s.UpdateSnapshot([]byte(`{"plan":"max5"}`))
+	same, _ := s.currentStatus()
+	assert.Equal(t, first, same, "an identical snapshot keeps its validators")
+	select {
+	case <-changed:
+		t.Fatal("an identical snapshot must not wake waiters")
+	default:
+	}
+
+	s.UpdateSnapshot([]byte(`{"plan":"max20"}`))
+	second, _ := s.currentStatus()
+	assert.NotEqual(t, first.etag, second.etag)
+	select {
+	case <-changed:
+	default:
+		t.Fatal("a new snapshot wakes waiters")
+	}
+}
+
+func TestUpdateStatus_IgnoresClock(t *testing.T) {
+	s := newTestServer(models.APIConfig{})
+	state := &mockState{
+		oauthData:      newTestOAuthData(baseTime),
+		currentSession: newTestSession(baseTime),
+		limits:         newTestLimits(),
+		config:         newTestConfig(),
+		lastRefresh:    baseTime,
+		hasData:        true,
+	}
+
+	s.UpdateStatus(BuildStatus(state, baseTime))
+	first, changed := s.currentStatus()
+
+	// A second later every countdown and server_time differ, but nothing
+	// the client can act on has changed
+	s.UpdateStatus(BuildStatus(state, baseTime.Add(time.Second)))
+	same, _ := s.currentStatus()
+	assert.Equal(t, first, same, "only the clock moved, so the snapshot is kept whole")
+	select {
+	case <-changed:
+		t.Fatal("a clock-only change must not wake waiters")
+	default:
+	}
+
+	state.currentSession.CostUSD = 6.0
+	s.UpdateStatus(BuildStatus(state, baseTime.Add(2*time.Second)))
+	second, _ := s.currentStatus()
+	assert.NotEqual(t, first.etag, second.etag)
+	select {
+	case <-changed:
+	default:
+		t.Fatal("a new snapshot wakes waiters")
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	const etag = `"0123456789abcdef"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{etag, true},
+		{`W/` + etag, true},
+		{`"other", ` + etag, true},
+		{`*`, true},
+		{`"other"`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.want, etagMatches(tt.header, etag))
+		})
+	}
+	assert.False(t, etagMatches("*", ""), "nothing matches before the first snapshot")
+}
+
+func TestParseWait(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30s", 30 * time.Second, false},
+		{"45", 45 * time.Second, false},
+		{"1m30s", 90 * time.Second, false},
+		{"1h", maxStatusWait, false},
+		{"0", 0, false},
+		{"-5s", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseWait(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestServer_StatusConditional(t *testing.T) {
+	s := newTestServer(models.APIConfig{})
+	s.UpdateSnapshot([]byte(`{"plan":"max5"}`))
+	mux := s.handler()
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	lastModified := rr.Header().Get("Last-Modified")
+	assert.NotEmpty(t, etag)
+	assert.NotEmpty(t, lastModified)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `{"plan":"max5"}`, rr.Body.String())
+
+	rr = get("If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+
+	assert.Equal(t, http.StatusOK, get("If-None-Match", `"stale"`).Code)
+	assert.Equal(t, http.StatusNotModified, get("If-Modified-Since", lastModified).Code)
+	assert.Equal(t, http.StatusOK, get("If-Modified-Since", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)).Code)
+}
+
+func TestServer_StatusLongPoll(t *testing.T) {
+	s := newTestServer(models.APIConfig{})
+	s.UpdateSnapshot([]byte(`{"plan":"max5"}`))
+	first, _ := s.currentStatus()
+	mux := s.handler()
+
+	// poll starts a long-poll request and returns a channel yielding its response
+	poll := func(ctx context.Context, query, ifNoneMatch string) <-chan *httptest.ResponseRecorder {
+		done := make(chan *httptest.ResponseRecorder, 1)
+		req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/status?"+query, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		go func() {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+			done <- rr
+		}()
+		return done
+	}
+	assertBlocked := func(done <-chan *httptest.ResponseRecorder) {
+		t.Helper()
+		select {
+		case rr := <-done:
+			t.Fatalf("returned early with %d", rr.Code)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	t.Run("returns when a newer snapshot arrives", func(t *testing.T) {
+		done := poll(t.Context(), "wait=30s", first.etag)
+		assertBlocked(done)
+		s.UpdateSnapshot([]byte(`{"plan":"max20"}`))
+		rr := <-done
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `{"plan":"max20"}`, rr.Body.String())
+	})
+
+	t.Run("returns at once when the client is behind", func(t *testing.T) {
+		rr := <-poll(t.Context(), "wait=30s", first.etag)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `{"plan":"max20"}`, rr.Body.String())
+	})
+
+	t.Run("without an ETag waits for the next snapshot", func(t *testing.T) {
+		done := poll(t.Context(), "wait=30s", "")
+		assertBlocked(done)
+		s.UpdateSnapshot([]byte(`{"plan":"pro"}`))
+		assert.Equal(t, `{"plan":"pro"}`, (<-done).Body.String())
+	})
+
+	t.Run("times out with 304", func(t *testing.T) {
+		current, _ := s.currentStatus()
+		rr := <-poll(t.Context(), "wait=50ms", current.etag)
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+	})
+
+	t.Run("returns when the request is cancelled", func(t *testing.T) {
+		current, _ := s.currentStatus()
+		ctx, cancel := context.WithCancel(t.Context())
+		done := poll(ctx, "wait=30s", current.etag)
+		assertBlocked(done)
+		cancel()
+		select {
+		case rr := <-done:
+			assert.Equal(t, http.StatusNotModified, rr.Code)
+		case <-time.After(3 * time.Second):
+			t.Fatal("long poll ignored cancellation")
+		}
+	})
+
+	t.Run("invalid wait", func(t *testing.T) {
+		rr := <-poll(t.Context(), "wait=soon", "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "invalid wait")
+	})
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// socketConnKey marks the context of requests that arrived over the Unix
+// socket. Only the socket's owner can connect to it, so they skip the
+// allowlist and token checks.
+type socketConnKey struct{}
+
+// markSocketConn is the socket server's ConnContext
+func markSocketConn(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, socketConnKey{}, true)
+}
+
+// viaSocket reports whether r arrived over the Unix socket
+func viaSocket(r *http.Request) bool {
+	v, _ := r.Context().Value(socketConnKey{}).(bool)
+	return v
+}
+
+// listenUnix listens on a Unix socket at path that only the current user can
+// connect to. A stale socket left by a crashed process is replaced; a live
+// one, or any other file, is an error.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("api: %s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("api: %s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("api: removing stale socket: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("api: checking socket: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("api: creating socket directory: %w", err)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("api: listen %s: %w", path, err)
+	}
+	// Connecting needs write permission on the socket, so 0600 limits it to
+	// this user. Closing the listener removes the file.
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("api: restricting socket permissions: %w", err)
+	}
+	return ln, nil
+}
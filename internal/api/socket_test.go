@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// socketClient returns an HTTP client that dials the Unix socket at path
+func socketClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestServer_Socket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "run", "ccu.sock")
+	port := getFreePort(t)
+	s := newTestServer(models.APIConfig{Port: port, BindAddr: "127.0.0.1", Token: "secret", SocketPath: sock})
+	s.UpdateSnapshot([]byte(`{"plan":"max5"}`))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Start(ctx) }()
+
+	client := socketClient(sock)
+	var resp *http.Response
+	var err error
+	for range 20 {
+		resp, err = client.Get("http://ccu/api/v1/status") //nolint:noctx
+		if err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "socket requests need no token")
+	resp.Body.Close()
+
+	info, err := os.Stat(sock)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// TCP still requires the token
+	resp, err = http.Get(fmt.Sprintf("http://127.0.0.1:%d/api/v1/status", port)) //nolint:noctx
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	// A second server can't take over a live socket
+	err = newTestServer(models.APIConfig{SocketPath: sock}).Start(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "in use")
+
+	cancel()
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not shut down in time")
+	}
+	_, err = os.Stat(sock)
+	assert.True(t, os.IsNotExist(err), "the socket is removed on shutdown")
+}
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("replaces a stale socket", func(t *testing.T) {
+		path := filepath.Join(dir, "stale.sock")
+		ln, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		ln.(*net.UnixListener).SetUnlinkOnClose(false)
+		ln.Close() // leaves the file behind, as a crash would
+
+		ln, err = listenUnix(path)
+		require.NoError(t, err)
+		ln.Close()
+	})
+
+	t.Run("refuses to replace other files", func(t *testing.T) {
+		path := filepath.Join(dir, "file.sock")
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+		_, err := listenUnix(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not a socket")
+	})
+}
+
+func TestServer_SocketOnly(t *testing.T) {
+	err := newTestServer(models.APIConfig{}).Start(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no port or socket")
+
+	sock := filepath.Join(t.TempDir(), "ccu.sock")
+	s := newTestServer(models.APIConfig{SocketPath: sock})
+	s.UpdateSnapshot([]byte(`{"plan":"max5"}`))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.Start(ctx) }()
+
+	require.Eventually(t, func() bool {
+		resp, err := socketClient(sock).Get("http://ccu/api/v1/status") //nolint:noctx
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 2*time.Second, 25*time.Millisecond)
+
+	cancel()
+	<-s.Done()
+}
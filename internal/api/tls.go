@@ -0,0 +1,164 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Self-signed certificate files, kept in the config directory so clients can
+// pin the certificate across restarts
+const (
+	selfSignedCertFile = "api_self_signed_cert.pem"
+	selfSignedKeyFile  = "api_self_signed_key.pem"
+	selfSignedValidity = 10 * 365 * 24 * time.Hour
+)
+
+// tlsConfig returns the server's TLS configuration from its certificate,
+// self-signed and client CA settings, or nil to serve plain HTTP
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	c := s.config
+	var cert tls.Certificate
+	switch {
+	case c.TLSCert != "" || c.TLSKey != "":
+		if c.TLSCert == "" || c.TLSKey == "" {
+			return nil, errors.New("api: TLS needs both a certificate and a key file")
+		}
+		var err error
+		if cert, err = tls.LoadX509KeyPair(c.TLSCert, c.TLSKey); err != nil {
+			return nil, fmt.Errorf("api: loading TLS certificate: %w", err)
+		}
+	case c.TLSSelfSigned:
+		if c.ConfigDir == "" {
+			return nil, errors.New("api: no config directory to keep a self-signed certificate in")
+		}
+		var err error
+		if cert, err = loadOrCreateSelfSigned(c.ConfigDir, time.Now()); err != nil {
+			return nil, err
+		}
+		log.Printf("api: self-signed certificate %s, SHA-256 fingerprint %s",
+			filepath.Join(c.ConfigDir, selfSignedCertFile), certFingerprint(cert.Certificate[0]))
+	default:
+		if c.TLSClientCA != "" {
+			return nil, errors.New("api: client certificate verification needs TLS: set a certificate or use a self-signed one")
+		}
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	if c.TLSClientCA != "" {
+		data, err := os.ReadFile(c.TLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("api: reading client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("api: no PEM certificates in client CA %s", c.TLSClientCA)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// loadOrCreateSelfSigned loads the self-signed certificate from dir, creating
+// it if it is missing, unreadable or expired at now. One that can sign other
+// certificates, as early builds created, is replaced too: its key sits on
+// disk, so trusting it would trust anything signed with that key.
+func loadOrCreateSelfSigned(dir string, now time.Time) (tls.Certificate, error) {
+	certPath, keyPath := filepath.Join(dir, selfSignedCertFile), filepath.Join(dir, selfSignedKeyFile)
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil && now.Before(leaf.NotAfter) && !leaf.IsCA {
+			return cert, nil
+		}
+		log.Printf("api: replacing self-signed certificate %s", certPath)
+	}
+
+	certPEM, keyPEM, err := generateSelfSigned(now)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return tls.Certificate{}, fmt.Errorf("api: creating %s: %w", dir, err)
+	}
+	// The key first, so a certificate is never left without its key
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		return tls.Certificate{}, fmt.Errorf("api: writing self-signed key: %w", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0o644); err != nil {
+		return tls.Certificate{}, fmt.Errorf("api: writing self-signed certificate: %w", err)
+	}
+	log.Printf("api: created self-signed certificate %s", certPath)
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// generateSelfSigned creates a P-256 certificate valid for localhost, the
+// host name and the machine's current addresses
+func generateSelfSigned(now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("api: generating key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("api: generating serial: %w", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "ccu API", Organization: []string{"ccu"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if host, err := os.Hostname(); err == nil && host != "" && host != "localhost" {
+		tmpl.DNSNames = append(tmpl.DNSNames, host)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.IsGlobalUnicast() {
+				tmpl.IPAddresses = append(tmpl.IPAddresses, ipNet.IP)
+			}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("api: creating certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("api: encoding key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// certFingerprint returns the SHA-256 fingerprint of a DER certificate as
+// colon-separated hex, as openssl x509 -fingerprint -sha256 prints it
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, ":")
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrCreateSelfSigned(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".ccu")
+	now := time.Now()
+
+	first, err := loadOrCreateSelfSigned(dir, now)
+	require.NoError(t, err)
+	info, err := os.Stat(filepath.Join(dir, selfSignedKeyFile))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "the key is private")
+
+	leaf, err := x509.ParseCertificate(first.Certificate[0])
+	require.NoError(t, err)
+	assert.Contains(t, leaf.DNSNames, "localhost")
+	assert.NoError(t, leaf.VerifyHostname("127.0.0.1"))
+	assert.False(t, leaf.IsCA, "trusting the certificate must not trust a CA")
+	assert.Zero(t, leaf.KeyUsage&x509.KeyUsageCertSign)
+
+	// Restarts reuse the certificate, so a pinned fingerprint stays valid
+	again, err := loadOrCreateSelfSigned(dir, now)
+	require.NoError(t, err)
+	assert.Equal(t, certFingerprint(first.Certificate[0]), certFingerprint(again.Certificate[0]))
+
+	// An expired one is replaced
+	renewed, err := loadOrCreateSelfSigned(dir, now.Add(selfSignedValidity+time.Hour))
+	require.NoError(t, err)
+	assert.NotEqual(t, certFingerprint(first.Certificate[0]), certFingerprint(renewed.Certificate[0]))
+
+	// So is a CA certificate left by an earlier build
+	ca := newTestCA(t)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(ca.key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, selfSignedCertFile), ca.certPEM, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, selfSignedKeyFile), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600))
+	replaced, err := loadOrCreateSelfSigned(dir, now)
+	require.NoError(t, err)
+	leaf, err = x509.ParseCertificate(replaced.Certificate[0])
+	require.NoError(t, err)
+	assert.False(t, leaf.IsCA)
+}
+
+func TestCertFingerprint(t *testing.T) {
+	fp := certFingerprint([]byte("certificate"))
+	assert.Len(t, fp, 32*3-1)
+	assert.Regexp(t, `^([0-9A-F]{2}:){31}[0-9A-F]{2}$`, fp)
+}
+
+func TestServer_TLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+	notPEM := filepath.Join(dir, "not.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("hello"), 0o600))
+
+	tests := []struct {
+		name       string
+		cfg        models.APIConfig
+		wantTLS    bool
+		wantClient bool
+		wantErr    string
+	}{
+		{name: "plain HTTP", cfg: models.APIConfig{}},
+		{name: "self-signed", cfg: models.APIConfig{TLSSelfSigned: true, ConfigDir: dir}, wantTLS: true},
+		{name: "client CA", cfg: models.APIConfig{TLSSelfSigned: true, ConfigDir: dir, TLSClientCA: caFile}, wantTLS: true, wantClient: true},
+		{name: "certificate without key", cfg: models.APIConfig{TLSCert: caFile}, wantErr: "both a certificate and a key"},
+		{name: "missing certificate", cfg: models.APIConfig{TLSCert: filepath.Join(dir, "nope.pem"), TLSKey: filepath.Join(dir, "nope.key")}, wantErr: "loading TLS certificate"},
+		{name: "client CA without TLS", cfg: models.APIConfig{TLSClientCA: caFile}, wantErr: "needs TLS"},
+		{name: "client CA not PEM", cfg: models.APIConfig{TLSSelfSigned: true, ConfigDir: dir, TLSClientCA: notPEM}, wantErr: "no PEM certificates"},
+		{name: "self-signed without config dir", cfg: models.APIConfig{TLSSelfSigned: true}, wantErr: "no config directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := newTestServer(tt.cfg).tlsConfig()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if !tt.wantTLS {
+				assert.Nil(t, cfg)
+				return
+			}
+			require.NotNil(t, cfg)
+			assert.Len(t, cfg.Certificates, 1)
+			assert.Equal(t, tt.wantClient, cfg.ClientAuth == tls.RequireAndVerifyClientCert)
+		})
+	}
+}
+
+func TestServer_StartTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+
+	port := getFreePort(t)
+	s := newTestServer(models.APIConfig{Port: port, BindAddr: "127.0.0.1", TLSSelfSigned: true, ConfigDir: dir, TLSClientCA: caFile})
+	s.UpdateSnapshot([]byte(`{"plan":"max5"}`))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Start(ctx) }()
+
+	serverPEM := func() []byte {
+		for range 40 {
+			if data, err := os.ReadFile(filepath.Join(dir, selfSignedCertFile)); err == nil {
+				return data
+			}
+			time.Sleep(25 * time.Millisecond)
+		}
+		t.Fatal("self-signed certificate not written")
+		return nil
+	}()
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(serverPEM))
+
+	get := func(certs ...tls.Certificate) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		var resp *http.Response
+		var err error
+		for range 20 {
+			resp, err = client.Get(fmt.Sprintf("https://127.0.0.1:%d/api/v1/status", port)) //nolint:noctx
+			if err == nil {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		return resp, err
+	}
+
+	resp, err := get(ca.issue(t))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	_, err = get()
+	assert.Error(t, err, "clients without a certificate are refused")
+
+	cancel()
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not shut down in time")
+	}
+}
+
+// testCA is a throwaway CA for issuing client certificates
+type testCA struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return testCA{cert: cert, key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a client certificate signed by the CA
+func (ca testCA) issue(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
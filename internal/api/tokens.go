@@ -0,0 +1,268 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Scopes a token can be granted. Each route requires one; admin grants all.
+const (
+	ScopeStatus  = "status"  // current usage: status, display, badges and card
+	ScopeHistory = "history" // sessions, daily and monthly usage, models
+	ScopeMetrics = "metrics" // Prometheus metrics
+	ScopeAdmin   = "admin"   // every route, including the token list
+)
+
+var knownScopes = []string{ScopeStatus, ScopeHistory, ScopeMetrics, ScopeAdmin}
+
+// minTokenLength keeps file tokens long enough not to be guessable
+const minTokenLength = 16
+
+// flagTokenName names the -api-token. File tokens can't use it, as usage is
+// recorded by name.
+const flagTokenName = "default"
+
+// tokensPollInterval is how often the tokens file is checked for changes
+const tokensPollInterval = 2 * time.Second
+
+// TokensFile is the JSON layout of the tokens file
+type TokensFile struct {
+	Tokens []TokenEntry `json:"tokens"`
+}
+
+// TokenEntry is one named consumer in the tokens file. Removing its entry
+// revokes it once the file is reloaded.
+type TokenEntry struct {
+	Name   string   `json:"name"`
+	Token  string   `json:"token"`
+	Scopes []string `json:"scopes"`
+}
+
+// apiToken is a loaded token. Only its hash is kept, so every comparison is
+// over the same length.
+type apiToken struct {
+	name   string
+	source string // "flag" for the single -api-token, "file" for the tokens file
+	hash   [sha256.Size]byte
+	scopes []string
+}
+
+func (t apiToken) allows(scope string) bool {
+	return scope == "" || slices.Contains(t.scopes, scope) || slices.Contains(t.scopes, ScopeAdmin)
+}
+
+// tokenUsage is what is recorded about a token's requests, by name, so it
+// survives reloads
+type tokenUsage struct {
+	lastUsed time.Time
+	requests int64
+}
+
+// tokenStore holds the tokens requests are checked against: the single
+// configured token, if any, and those in the tokens file
+type tokenStore struct {
+	path string // tokens file; empty = none
+
+	mu      sync.RWMutex
+	static  []apiToken
+	file    []apiToken
+	modTime time.Time // of the loaded file, to spot changes
+	size    int64
+	usage   map[string]tokenUsage
+}
+
+// newTokenStore returns a store with the single -api-token, as an admin token
+// named "default", and the tokens file at path, loaded by load
+func newTokenStore(token, path string) *tokenStore {
+	ts := &tokenStore{path: path, usage: make(map[string]tokenUsage)}
+	if token != "" {
+		ts.static = []apiToken{{name: flagTokenName, source: "flag", hash: sha256.Sum256([]byte(token)), scopes: []string{ScopeAdmin}}}
+	}
+	return ts
+}
+
+// required reports whether requests need a token
+func (ts *tokenStore) required() bool {
+	return len(ts.static) > 0 || ts.path != ""
+}
+
+// load reads the tokens file, replacing the loaded tokens. On error the
+// previous tokens stay in place. A missing file revokes every file token.
+func (ts *tokenStore) load() error {
+	if ts.path == "" {
+		return nil
+	}
+	info, err := os.Stat(ts.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		ts.mu.Lock()
+		ts.file, ts.modTime, ts.size = nil, time.Time{}, 0
+		ts.mu.Unlock()
+		return fmt.Errorf("tokens file %s is missing: no file tokens are accepted", ts.path)
+	}
+	if err != nil {
+		return fmt.Errorf("reading tokens file: %w", err)
+	}
+	data, err := os.ReadFile(ts.path)
+	if err != nil {
+		return fmt.Errorf("reading tokens file: %w", err)
+	}
+	tokens, err := parseTokens(data)
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	// Note the file even when it is invalid, so it is retried (and the error
+	// logged) once per edit rather than on every poll
+	ts.modTime, ts.size = info.ModTime(), info.Size()
+	if err != nil {
+		return fmt.Errorf("tokens file %s: %w", ts.path, err)
+	}
+	ts.file = tokens
+	return nil
+}
+
+// changed reports whether the tokens file differs from the one loaded
+func (ts *tokenStore) changed() bool {
+	info, err := os.Stat(ts.path)
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	if err != nil {
+		return !ts.modTime.IsZero()
+	}
+	return !info.ModTime().Equal(ts.modTime) || info.Size() != ts.size
+}
+
+// watch reloads the tokens file whenever it changes until ctx is done, so
+// tokens can be added and revoked without a restart
+func (ts *tokenStore) watch(ctx context.Context, interval time.Duration) {
+	if ts.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !ts.changed() {
+				continue
+			}
+			if err := ts.load(); err != nil {
+				log.Printf("api: %v", err)
+				continue
+			}
+			log.Printf("api: reloaded %s", ts.path)
+		}
+	}
+}
+
+// parseTokens parses and validates a tokens file
+func parseTokens(data []byte) ([]apiToken, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields() // a misspelt "scopes" would otherwise grant nothing silently
+	var file TokensFile
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+
+	tokens := make([]apiToken, 0, len(file.Tokens))
+	names := make(map[string]bool, len(file.Tokens))
+	hashes := make(map[[sha256.Size]byte]bool, len(file.Tokens))
+	for i, e := range file.Tokens {
+		switch {
+		case e.Name == "":
+			return nil, fmt.Errorf("token %d has no name", i+1)
+		case e.Name == flagTokenName:
+			return nil, fmt.Errorf("token name %q is reserved for -api-token", e.Name)
+		case names[e.Name]:
+			return nil, fmt.Errorf("duplicate token name %q", e.Name)
+		case len(e.Token) < minTokenLength:
+			return nil, fmt.Errorf("token %q is shorter than %d characters", e.Name, minTokenLength)
+		case len(e.Scopes) == 0:
+			return nil, fmt.Errorf("token %q has no scopes", e.Name)
+		}
+		for _, scope := range e.Scopes {
+			if !slices.Contains(knownScopes, scope) {
+				return nil, fmt.Errorf("token %q: unknown scope %q (use %v)", e.Name, scope, knownScopes)
+			}
+		}
+		hash := sha256.Sum256([]byte(e.Token))
+		if hashes[hash] {
+			return nil, fmt.Errorf("token %q reuses another token's secret", e.Name)
+		}
+		names[e.Name], hashes[hash] = true, true
+		tokens = append(tokens, apiToken{name: e.Name, source: "file", hash: hash, scopes: slices.Clone(e.Scopes)})
+	}
+	return tokens, nil
+}
+
+// lookup returns the token matching secret. Every token is compared in
+// constant time, so response timing doesn't reveal how close a guess was.
+func (ts *tokenStore) lookup(secret string) (apiToken, bool) {
+	hash := sha256.Sum256([]byte(secret))
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	var match apiToken
+	found := 0
+	for _, t := range slices.Concat(ts.static, ts.file) {
+		eq := subtle.ConstantTimeCompare(hash[:], t.hash[:])
+		if eq == 1 {
+			match = t
+		}
+		found |= eq
+	}
+	return match, found == 1
+}
+
+// recordUse notes a request made with a token
+func (ts *tokenStore) recordUse(name string, at time.Time) {
+	ts.mu.Lock()
+	u := ts.usage[name]
+	u.lastUsed = at
+	u.requests++
+	ts.usage[name] = u
+	ts.mu.Unlock()
+}
+
+// list returns every loaded token with its usage, without the secrets
+func (ts *tokenStore) list() []TokenInfo {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	out := []TokenInfo{}
+	for _, t := range slices.Concat(ts.static, ts.file) {
+		info := TokenInfo{Name: t.name, Source: t.source, Scopes: t.scopes, Requests: ts.usage[t.name].requests}
+		if at := ts.usage[t.name].lastUsed; !at.IsZero() {
+			info.LastUsed = &at
+		}
+		out = append(out, info)
+	}
+	return out
+}
+
+// handleTokens serves the configured tokens, their scopes and when each was
+// last used. Secrets are never included.
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(TokensResponse{SchemaVersion: SchemaVersion, Tokens: s.tokens.list()})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "encoding response")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("api: write error (tokens response): %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	esp32Token   = "esp32-0123456789abcdef"
+	grafanaToken = "grafana-0123456789abcdef"
+	adminToken   = "admin-0123456789abcdef"
+)
+
+func writeTokensFile(t *testing.T, path string, entries ...TokenEntry) {
+	t.Helper()
+	data, err := json.Marshal(TokensFile{Tokens: entries})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func testTokensFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "api_tokens.json")
+	writeTokensFile(t, path,
+		TokenEntry{Name: "esp32", Token: esp32Token, Scopes: []string{ScopeStatus}},
+		TokenEntry{Name: "grafana", Token: grafanaToken, Scopes: []string{ScopeHistory, ScopeMetrics}},
+		TokenEntry{Name: "admin", Token: adminToken, Scopes: []string{ScopeAdmin}},
+	)
+	return path
+}
+
+func TestParseTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    int
+		wantErr string
+	}{
+		{"empty list", `{"tokens":[]}`, 0, ""},
+		{"valid", `{"tokens":[{"name":"a","token":"aaaaaaaaaaaaaaaa","scopes":["status","history"]}]}`, 1, ""},
+		{"not JSON", `tokens`, 0, "parsing"},
+		{"unknown field", `{"tokens":[{"name":"a","token":"aaaaaaaaaaaaaaaa","scope":["status"]}]}`, 0, "unknown field"},
+		{"no name", `{"tokens":[{"token":"aaaaaaaaaaaaaaaa","scopes":["status"]}]}`, 0, "token 1 has no name"},
+		{"short token", `{"tokens":[{"name":"a","token":"short","scopes":["status"]}]}`, 0, "shorter than 16"},
+		{"no scopes", `{"tokens":[{"name":"a","token":"aaaaaaaaaaaaaaaa","scopes":[]}]}`, 0, "no scopes"},
+		{"unknown scope", `{"tokens":[{"name":"a","token":"aaaaaaaaaaaaaaaa","scopes":["root"]}]}`, 0, `unknown scope "root"`},
+		{"reserved name", `{"tokens":[{"name":"default","token":"aaaaaaaaaaaaaaaa","scopes":["status"]}]}`, 0, `token name "default" is reserved`},
+		{"duplicate name", `{"tokens":[{"name":"a","token":"aaaaaaaaaaaaaaaa","scopes":["status"]},{"name":"a","token":"bbbbbbbbbbbbbbbb","scopes":["status"]}]}`, 0, "duplicate token name"},
+		{"shared secret", `{"tokens":[{"name":"a","token":"aaaaaaaaaaaaaaaa","scopes":["status"]},{"name":"b","token":"aaaaaaaaaaaaaaaa","scopes":["status"]}]}`, 0, "reuses another token's secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := parseTokens([]byte(tt.data))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, tokens, tt.want)
+		})
+	}
+}
+
+func TestTokenStore_Lookup(t *testing.T) {
+	ts := newTokenStore("legacy", testTokensFile(t))
+	require.NoError(t, ts.load())
+	assert.True(t, ts.required())
+
+	tests := []struct {
+		secret  string
+		want    string
+		allowed []string
+		denied  []string
+	}{
+		{esp32Token, "esp32", []string{ScopeStatus, ""}, []string{ScopeHistory, ScopeMetrics, ScopeAdmin}},
+		{grafanaToken, "grafana", []string{ScopeHistory, ScopeMetrics}, []string{ScopeStatus, ScopeAdmin}},
+		{adminToken, "admin", knownScopes, nil},
+		{"legacy", "default", knownScopes, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			token, ok := ts.lookup(tt.secret)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, token.name)
+			for _, scope := range tt.allowed {
+				assert.True(t, token.allows(scope), scope)
+			}
+			for _, scope := range tt.denied {
+				assert.False(t, token.allows(scope), scope)
+			}
+		})
+	}
+
+	for _, secret := range []string{"", "wrong", esp32Token + "x", strings.ToUpper(esp32Token)} {
+		_, ok := ts.lookup(secret)
+		assert.False(t, ok, secret)
+	}
+
+	assert.False(t, newTokenStore("", "").required(), "no tokens means no auth")
+}
+
+func TestTokenStore_FailsClosed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_tokens.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"tokens":[{"name":"a"}]}`), 0o600))
+	ts := newTokenStore("", path)
+	assert.Error(t, ts.load())
+	assert.True(t, ts.required(), "a broken tokens file still requires a token")
+	_, ok := ts.lookup("")
+	assert.False(t, ok)
+}
+
+func TestTokenStore_Watch(t *testing.T) {
+	path := testTokensFile(t)
+	ts := newTokenStore("", path)
+	require.NoError(t, ts.load())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ts.watch(ctx, 10*time.Millisecond)
+
+	known := func(secret string) func() bool {
+		return func() bool { _, ok := ts.lookup(secret); return ok }
+	}
+
+	// Revoke esp32 and add a new token
+	writeTokensFile(t, path,
+		TokenEntry{Name: "grafana", Token: grafanaToken, Scopes: []string{ScopeHistory}},
+		TokenEntry{Name: "ha", Token: "home-assistant-0123", Scopes: []string{ScopeStatus}},
+	)
+	assert.Eventually(t, known("home-assistant-0123"), time.Second, 10*time.Millisecond)
+	assert.False(t, known(esp32Token)(), "removed tokens are revoked")
+
+	// An invalid edit keeps the last good tokens
+	require.NoError(t, os.WriteFile(path, []byte(`{"tokens":[{"name":"broken"`), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, known(grafanaToken)())
+
+	// Deleting the file revokes every file token
+	require.NoError(t, os.Remove(path))
+	assert.Eventually(t, func() bool { return !known(grafanaToken)() }, time.Second, 10*time.Millisecond)
+}
+
+// TestServer_TokenScopes checks every route enforces the scope it documents
+func TestServer_TokenScopes(t *testing.T) {
+	s := newTestServer(models.APIConfig{TokensFile: testTokensFile(t)})
+	s.UpdateSnapshot([]byte(`{"schema_version":1}`))
+	s.UpdateHistory(BuildHistory(nil, nil, time.UTC))
+	s.UpdateDisplay(DisplayResponse{SchemaVersion: SchemaVersion})
+	mux := s.handler()
+
+	get := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	// The scopes each token in testTokensFile has
+	scoped := []struct {
+		secret string
+		scopes []string
+	}{
+		{esp32Token, []string{ScopeStatus}},
+		{grafanaToken, []string{ScopeHistory, ScopeMetrics}},
+	}
+
+	for _, rt := range routes {
+		paths := []string{strings.ReplaceAll(versionedPath(rt.name), "{metric}", "session")}
+		if rt.legacy {
+			paths = append(paths, "/api/"+rt.name)
+		}
+		t.Run(rt.name, func(t *testing.T) {
+			require.Contains(t, knownScopes, rt.scope)
+			for _, path := range paths {
+				assert.Equal(t, http.StatusUnauthorized, get(path, ""), path)
+				assert.Equal(t, http.StatusUnauthorized, get(path, "not-a-token"), path)
+				assert.Equal(t, http.StatusOK, get(path, adminToken), path)
+				for _, tok := range scoped {
+					want := http.StatusForbidden
+					if slices.Contains(tok.scopes, rt.scope) {
+						want = http.StatusOK
+					}
+					assert.Equal(t, want, get(path, tok.secret), "%s with a %v token", path, tok.scopes)
+				}
+			}
+		})
+	}
+
+	assert.Equal(t, http.StatusOK, get("/api/openapi.json", esp32Token), "any valid token reads the document")
+	assert.Equal(t, http.StatusUnauthorized, get("/api/openapi.json", ""))
+}
+
+func TestServer_TokensList(t *testing.T) {
+	s := newTestServer(models.APIConfig{Token: "legacy-secret", TokensFile: testTokensFile(t)})
+	s.UpdateSnapshot([]byte(`{"schema_version":1}`))
+	mux := s.handler()
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusOK, get("/api/v1/status", esp32Token).Code)
+	require.Equal(t, http.StatusOK, get("/api/v1/status", esp32Token).Code)
+
+	rr := get("/api/v1/tokens", adminToken)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), esp32Token, "secrets are never listed")
+	assert.NotContains(t, rr.Body.String(), "legacy-secret")
+
+	var resp TokensResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	byName := make(map[string]TokenInfo)
+	for _, info := range resp.Tokens {
+		byName[info.Name] = info
+	}
+	require.Len(t, byName, 4)
+	assert.Equal(t, "flag", byName["default"].Source)
+	assert.Equal(t, []string{ScopeAdmin}, byName["default"].Scopes)
+	assert.Equal(t, int64(2), byName["esp32"].Requests)
+	require.NotNil(t, byName["esp32"].LastUsed)
+	assert.WithinDuration(t, time.Now(), *byName["esp32"].LastUsed, time.Minute)
+	assert.Nil(t, byName["grafana"].LastUsed, "unused tokens have no last_used")
+	assert.Equal(t, int64(1), byName["admin"].Requests, "this request")
+
+	rr = get("/api/v1/tokens", esp32Token)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error":"Forbidden"}`, rr.Body.String())
+
+	rr = get("/api/v1/tokens", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `{"error":"Unauthorized"}`, rr.Body.String())
+}
@@ -2,8 +2,15 @@ package api
 
 import "time"
 
-// StatusResponse is the top-level response for GET /api/status
+// SchemaVersion is the version of the response shapes in this file, reported
+// as schema_version in every response and served under /api/v<SchemaVersion>/.
+// Within a version fields are only ever added; removing, renaming or retyping
+// one means a new version, with the old routes kept for a deprecation period.
+const SchemaVersion = 1
+
+// StatusResponse is the top-level response for GET /api/v1/status
 type StatusResponse struct {
+	SchemaVersion  int                `json:"schema_version"`
 	Plan           string             `json:"plan"`
 	ServerTime     string             `json:"server_time"`
 	DataAgeSeconds int                `json:"data_age_seconds"`
@@ -11,6 +18,7 @@ type StatusResponse struct {
 	Session        *SessionSection    `json:"session,omitempty"`
 	BurnRate       *BurnRateSection   `json:"burn_rate,omitempty"`
 	Prediction     *PredictionSection `json:"prediction,omitempty"`
+	UnpricedModels []UnpricedModel    `json:"unpriced_models,omitempty"`
 }
 
 // WeeklySection holds weekly usage data broken down by model tier.
@@ -82,3 +90,143 @@ type PredictionSection struct {
 	WeeklyLimitInSeconds  *int64     `json:"weekly_limit_in_seconds,omitempty"`
 	WeeklyWillHitLimit    bool       `json:"weekly_will_hit_limit"`
 }
+
+// UnpricedModel reports a model seen in the loaded usage with no price. Claude
+// models are costed at Sonnet rates and included in every cost figure, and
+// other providers' models at zero, so a non-empty list means costs may be
+// wrong until the model is priced.
+type UnpricedModel struct {
+	Model           string  `json:"model"`
+	Provider        string  `json:"provider"`
+	Requests        int     `json:"requests"`
+	Tokens          int     `json:"tokens"`
+	FallbackCostUSD float64 `json:"fallback_cost_usd"`
+	FirstSeen       string  `json:"first_seen"`
+	LastSeen        string  `json:"last_seen"`
+}
+
+// ErrorResponse is the body of a JSON error response
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// TokensResponse is the response for GET /api/v1/tokens
+type TokensResponse struct {
+	SchemaVersion int         `json:"schema_version"`
+	Tokens        []TokenInfo `json:"tokens"`
+}
+
+// TokenInfo describes one configured token, without its secret. LastUsed is
+// omitted until the token is first used; usage is counted since startup.
+type TokenInfo struct {
+	Name     string     `json:"name"`
+	Source   string     `json:"source"` // "flag" for -api-token, "file" for the tokens file
+	Scopes   []string   `json:"scopes"`
+	Requests int64      `json:"requests"`
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+// SessionsResponse is the response for GET /api/v1/sessions
+type SessionsResponse struct {
+	SchemaVersion int              `json:"schema_version"`
+	Sessions      []SessionSummary `json:"sessions"`
+}
+
+// SessionSummary describes one 5-hour session block. ActualEndTime is set
+// once the session has ended early, i.e. its last message was before EndTime.
+type SessionSummary struct {
+	ID            string              `json:"id"`
+	StartTime     string              `json:"start_time"`
+	EndTime       string              `json:"end_time"`
+	ActualEndTime string              `json:"actual_end_time,omitempty"`
+	IsActive      bool                `json:"is_active"`
+	CostUSD       float64             `json:"cost_usd"`
+	TotalTokens   int                 `json:"total_tokens"`
+	MessageCount  int                 `json:"message_count"`
+	Models        []SessionModelStats `json:"models"`
+
+	start time.Time
+}
+
+// SessionModelStats holds one model's usage within a session
+type SessionModelStats struct {
+	Model               string  `json:"model"`
+	InputTokens         int     `json:"input_tokens"`
+	OutputTokens        int     `json:"output_tokens"`
+	CacheCreationTokens int     `json:"cache_creation_tokens"`
+	CacheReadTokens     int     `json:"cache_read_tokens"`
+	CostUSD             float64 `json:"cost_usd"`
+	MessageCount        int     `json:"message_count"`
+}
+
+// UsageResponse is the response for GET /api/v1/usage/daily and
+// GET /api/v1/usage/monthly. Periods are oldest first and start at local
+// midnight (or the 1st of the month) in Timezone.
+type UsageResponse struct {
+	SchemaVersion int           `json:"schema_version"`
+	Period        string        `json:"period"` // "daily" or "monthly"
+	Timezone      string        `json:"timezone"`
+	Periods       []UsagePeriod `json:"periods"`
+}
+
+// UsagePeriod holds one day's or month's usage. Start is YYYY-MM-DD for days
+// and YYYY-MM for months.
+type UsagePeriod struct {
+	Start       string            `json:"start"`
+	CostUSD     float64           `json:"cost_usd"`
+	TotalTokens int               `json:"total_tokens"`
+	Requests    int               `json:"requests"`
+	Models      []UsageModelStats `json:"models"`
+
+	start time.Time
+}
+
+// UsageModelStats holds one model's usage over a period. Cache write tokens
+// are the 5-minute plus 1-hour totals.
+type UsageModelStats struct {
+	Model                 string  `json:"model"`
+	Provider              string  `json:"provider"`
+	InputTokens           int     `json:"input_tokens"`
+	OutputTokens          int     `json:"output_tokens"`
+	CacheCreationTokens   int     `json:"cache_creation_tokens"`
+	CacheCreation5mTokens int     `json:"cache_creation_5m_tokens"`
+	CacheCreation1hTokens int     `json:"cache_creation_1h_tokens"`
+	CacheReadTokens       int     `json:"cache_read_tokens"`
+	TotalTokens           int     `json:"total_tokens"`
+	CostUSD               float64 `json:"cost_usd"`
+	Requests              int     `json:"requests"`
+	LongContextRequests   int     `json:"long_context_requests"`
+}
+
+// ModelsResponse is the response for GET /api/v1/models: per-model totals
+// over the requested range, highest cost first
+type ModelsResponse struct {
+	SchemaVersion int               `json:"schema_version"`
+	Models        []UsageModelStats `json:"models"`
+}
+
+// DisplayResponse is the response for GET /api/v1/display: a flat summary
+// with fixed keys for microcontrollers and e-ink displays. Every key is always
+// present; values that are unknown, such as weekly usage without OAuth, are -1
+// (or empty for strings). Countdowns are as of UpdatedEpoch, the Unix time of
+// the refresh that produced them.
+type DisplayResponse struct {
+	SchemaVersion    int     `json:"schema_version"`
+	Plan             string  `json:"plan"`
+	SessionPct       float64 `json:"session_pct"`
+	SessionResetSecs int64   `json:"session_reset_secs"`
+	SessionCostUSD   float64 `json:"session_cost_usd"`
+	TodayCostUSD     float64 `json:"today_cost_usd"` // all providers, since local midnight
+	WeeklyPct        float64 `json:"weekly_pct"`
+	WeeklyResetSecs  int64   `json:"weekly_reset_secs"`
+	ScopedPct        float64 `json:"scoped_pct"`   // highest per-model weekly limit
+	ScopedModel      string  `json:"scoped_model"` // the model it applies to
+	ScopedResetSecs  int64   `json:"scoped_reset_secs"`
+	BurnUSDPerHour   float64 `json:"burn_usd_per_hour"`
+	LimitPredicted   bool    `json:"limit_predicted"` // session or weekly limit expected before reset
+	Severity         int     `json:"severity"`        // 0 ok, 1 warn, 2 high, 3 critical
+	Level            string  `json:"level"`           // Severity as a word
+	Colour           string  `json:"colour"`          // "#RRGGBB" for the highest percentage, as the dashboard shows it
+	ColourRGB565     int     `json:"colour_rgb565"`   // Colour packed for 16-bit TFT displays
+	UpdatedEpoch     int64   `json:"updated_epoch"`
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/sammcj/ccu/internal/data"
 	"github.com/sammcj/ccu/internal/models"
 	"github.com/sammcj/ccu/internal/oauth"
+	"github.com/sammcj/ccu/internal/pricing"
 	"github.com/sammcj/ccu/internal/ui"
 )
 
@@ -56,12 +57,24 @@ const (
 	oauthForceInterval  = 60 * time.Second // Minimum interval even for urgent refreshes (wake/focus/stale)
 )
 
+// OAuth access, replaced in tests
+var (
+	oauthAvailable = oauth.IsAvailable
+	newOAuthClient = func() (usageFetcher, error) { return oauth.NewClient() }
+)
+
+// usageFetcher fetches OAuth usage, as *oauth.Client does
+type usageFetcher interface {
+	FetchUsage() (*oauth.UsageData, error)
+}
+
 // Messages
 type tickMsg struct {
 	time       time.Time
 	generation uint64 // Tick chain generation - stale generations are ignored
 }
-type resumeMsg struct{} // Sent when process resumes from suspension (SIGCONT)
+type resumeMsg struct{}       // Sent when process resumes from suspension (SIGCONT)
+type usageChangedMsg struct{} // Sent when the watcher sees a JSONL file written
 type dataLoadedMsg struct {
 	entries            []models.UsageEntry
 	oauthData          *oauth.UsageData
@@ -71,6 +84,7 @@ type dataLoadedMsg struct {
 	oauthUserAction    bool          // Whether the OAuth error needs re-authentication (never auto-retry)
 	oauthFreshData     bool          // Whether OAuth data was freshly fetched (not from cache)
 	oauthRateLimitWait time.Duration // How long to wait before retrying after 429
+	oauthFetched       bool          // Whether this load fetched OAuth - only then are the oauth fields set
 	generation         uint64        // Load generation at dispatch - stale results are dropped
 }
 type clearScreenMsg struct{}
@@ -81,6 +95,7 @@ func (m AppModel) Init() tea.Cmd {
 		m.spinner.Tick,
 		loadDataCmd(m.config),
 		tickCmd(m.config.RefreshRate, m.tickGeneration),
+		waitForUsageCmd(m.watcher),
 	)
 }
 
@@ -170,46 +185,59 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			tickCmd(m.config.RefreshRate, m.tickGeneration),
 		)
 
-	case dataLoadedMsg:
-		// Drop results from superseded loads so a slow load finishing late
-		// can't overwrite fresher state
-		if msg.generation != m.loadGeneration {
-			return m, nil
-		}
+	case usageChangedMsg:
+		// New usage was written: reload JSONL now rather than on the next
+		// tick. OAuth is left to the tick's loads.
+		return m, tea.Batch(
+			loadUsageCmd(m.config, &m),
+			waitForUsageCmd(m.watcher),
+		)
 
-		// OAuth results are processed before the JSONL error check so a
-		// transient JSONL failure doesn't discard OAuth data fetched in the
-		// same load.
+	case dataLoadedMsg:
+		// OAuth results are processed before the generation check: while
+		// Claude Code is writing, watcher reloads supersede the tick's load
+		// about once a second, and dropping its fetch (or its 429 backoff)
+		// would just fetch again. They also come before the JSONL error check
+		// so a transient JSONL failure doesn't discard them.
+		if msg.oauthFetched {
+			m.oauthFetching = false
+
+			// Handle rate limit backoff - don't disable OAuth, just delay next fetch
+			if msg.oauthRateLimitWait > 0 {
+				m.oauthRateLimitUntil = time.Now().Add(msg.oauthRateLimitWait)
+			}
 
-		// Handle rate limit backoff - don't disable OAuth, just delay next fetch
-		if msg.oauthRateLimitWait > 0 {
-			m.oauthRateLimitUntil = time.Now().Add(msg.oauthRateLimitWait)
-		}
+			// Handle OAuth errors - disable OAuth if permanent failure
+			if msg.oauthErr != nil && msg.oauthDisabled {
+				if !m.HasLoggedOAuthError() {
+					log.Printf("OAuth disabled: %v (falling back to JSONL)", msg.oauthErr)
+					m.MarkOAuthErrorLogged()
+				}
+				m.DisableOAuth(msg.oauthErr.Error(), msg.oauthUserAction)
+			}
 
-		// Handle OAuth errors - disable OAuth if permanent failure
-		if msg.oauthErr != nil && msg.oauthDisabled {
-			if !m.HasLoggedOAuthError() {
-				log.Printf("OAuth disabled: %v (falling back to JSONL)", msg.oauthErr)
-				m.MarkOAuthErrorLogged()
+			// Store OAuth data if available
+			if msg.oauthData != nil {
+				// If OAuth was previously disabled but we got data, it recovered
+				if m.IsOAuthDisabled() {
+					log.Printf("OAuth recovered successfully")
+					m.ReenableOAuth()
+				}
+				m.SetOAuthData(msg.oauthData)
+				// Only update timestamps when we fetched fresh data (not from cache)
+				// This ensures the 15-minute weekly refresh check works correctly
+				if msg.oauthFreshData {
+					now := time.Now()
+					m.lastOAuthFetch = now
+					m.SetLastWeeklyFetch(now)
+				}
 			}
-			m.DisableOAuth(msg.oauthErr.Error(), msg.oauthUserAction)
 		}
 
-		// Store OAuth data if available
-		if msg.oauthData != nil {
-			// If OAuth was previously disabled but we got data, it recovered
-			if m.IsOAuthDisabled() {
-				log.Printf("OAuth recovered successfully")
-				m.ReenableOAuth()
-			}
-			m.SetOAuthData(msg.oauthData)
-			// Only update timestamps when we fetched fresh data (not from cache)
-			// This ensures the 15-minute weekly refresh check works correctly
-			if msg.oauthFreshData {
-				now := time.Now()
-				m.lastOAuthFetch = now
-				m.SetLastWeeklyFetch(now)
-			}
+		// Drop entries from superseded loads so a slow load finishing late
+		// can't overwrite fresher state
+		if msg.generation != m.loadGeneration {
+			return m, nil
 		}
 
 		if msg.err != nil {
@@ -229,7 +257,10 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if sameEntries {
 			sessions = m.sessions
 		} else {
-			sessions = analysis.CreateSessionBlocks(msg.entries)
+			// Plan limits only count usage served by Anthropic; usage proxied
+			// to other providers still shows in the daily and monthly views
+			sessions = analysis.CreateSessionBlocks(models.AnthropicEntries(msg.entries))
+			m.SetUnpricedModels(pricing.UnpricedModels(msg.entries))
 		}
 		sessions = analysis.MarkActiveSessions(sessions, now)
 		sessions = analysis.UpdateSessionCosts(sessions)
@@ -237,13 +268,25 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Update model
 		m.SetData(msg.entries, sessions)
 
-		// Push a fresh snapshot to the API server (if running)
+		// Push a fresh snapshot to the API server and MQTT (if running)
+		var status api.StatusResponse
+		if m.apiServer != nil || m.mqtt != nil {
+			status = api.BuildStatus(&m, now)
+		}
+		if m.mqtt != nil {
+			m.mqtt.Update(status)
+		}
 		if m.apiServer != nil {
-			if snapshot, err := api.BuildStatusResponse(&m, now); err == nil {
-				m.apiServer.UpdateSnapshot(snapshot)
+			m.apiServer.UpdateStatus(status)
+			// Daily and monthly totals only change with the entries
+			history := m.apiServer.History()
+			if sameEntries && history != nil {
+				history = history.WithSessions(sessions)
 			} else {
-				log.Printf("api: failed to build snapshot: %v", err)
+				history = api.BuildHistory(msg.entries, sessions, m.GetConfig().Timezone)
 			}
+			m.apiServer.UpdateHistory(history)
+			m.apiServer.UpdateDisplay(api.BuildDisplayResponse(&m, history, now))
 		}
 
 		return m, nil
@@ -294,6 +337,7 @@ func (m AppModel) View() string {
 			AllSessions:            m.sessions,
 			OAuthData:              m.oauthData,
 			OAuthUnavailableReason: m.getOAuthUnavailableReason(),
+			UnpricedModels:         m.unpriced,
 		}
 		content = ui.RenderDashboard(data)
 	}
@@ -364,17 +408,21 @@ func loadDataCmdWithModel(config *models.Config, model *AppModel) tea.Cmd {
 
 	// Gate all OAuth fetches behind minimum intervals to avoid API rate limits (429s).
 	// Normal polling: every 4 minutes. Urgent (wake/focus/stale): still respect 60s minimum.
-	shouldFetchOAuth := oauth.IsAvailable() && oauthNotDisabled && !oauthRateLimited &&
+	// Only one fetch is in flight at a time, so a slow one isn't repeated
+	// before its result (or its 429 backoff) arrives.
+	oauthFetching := model != nil && model.oauthFetching
+	shouldFetchOAuth := oauthAvailable() && oauthNotDisabled && !oauthRateLimited && !oauthFetching &&
 		(model == nil ||
 			pastNormalInterval ||
 			(pastForceInterval && (forceRefresh || sessionStale || weeklyRefreshNeeded)))
+	if shouldFetchOAuth && model != nil {
+		model.oauthFetching = true
+	}
 
-	// Snapshot the cached OAuth data and disabled state for the async closure.
+	// Snapshot the cached OAuth data for the async closure's 429 fallback.
 	var cachedOAuthData *oauth.UsageData
-	var oauthIsDisabled bool
 	if model != nil {
 		cachedOAuthData = model.oauthData
-		oauthIsDisabled = model.IsOAuthDisabled()
 	}
 
 	return func() tea.Msg {
@@ -385,7 +433,7 @@ func loadDataCmdWithModel(config *models.Config, model *AppModel) tea.Cmd {
 		var oauthRateLimitWait time.Duration
 
 		if shouldFetchOAuth {
-			client, err := oauth.NewClient()
+			client, err := newOAuthClient()
 			if err == nil {
 				oauthData, err = client.FetchUsage()
 				if err != nil {
@@ -429,18 +477,10 @@ func loadDataCmdWithModel(config *models.Config, model *AppModel) tea.Cmd {
 				// Client creation failure is usually permanent (keychain issue)
 				oauthShouldDisable = true
 			}
-		} else if cachedOAuthData != nil && !oauthIsDisabled {
-			// Reuse cached OAuth data (oauthFreshData remains false)
-			// Don't reuse when OAuth is disabled - the data is stale
-			oauthData = cachedOAuthData
 		}
+		// Without a fetch the model keeps the OAuth data it has
 
-		// Load at least 7 days (168 hours) of data for weekly usage calculations
-		hoursToLoad := config.HoursBack
-		if config.ShowWeekly && hoursToLoad < 168 {
-			hoursToLoad = 168
-		}
-		entries, err := data.LoadUsageData(config.DataPath, hoursToLoad)
+		entries, err := loadEntries(config)
 
 		return dataLoadedMsg{
 			entries:            entries,
@@ -451,11 +491,35 @@ func loadDataCmdWithModel(config *models.Config, model *AppModel) tea.Cmd {
 			oauthUserAction:    oauth.RequiresUserAction(oauthErr),
 			oauthFreshData:     oauthFreshData,
 			oauthRateLimitWait: oauthRateLimitWait,
+			oauthFetched:       shouldFetchOAuth,
 			generation:         loadGeneration,
 		}
 	}
 }
 
+// loadUsageCmd reloads JSONL only, for the watcher's signals. It never
+// fetches OAuth or consumes a pending force refresh, so a burst of writes
+// can't bypass the OAuth intervals. Model access is synchronous, as for
+// loadDataCmdWithModel.
+func loadUsageCmd(config *models.Config, model *AppModel) tea.Cmd {
+	model.loadGeneration++
+	loadGeneration := model.loadGeneration
+	return func() tea.Msg {
+		entries, err := loadEntries(config)
+		return dataLoadedMsg{entries: entries, err: err, generation: loadGeneration}
+	}
+}
+
+// loadEntries loads usage entries, at least 7 days (168 hours) of them for
+// weekly usage calculations
+func loadEntries(config *models.Config) ([]models.UsageEntry, error) {
+	hoursToLoad := config.HoursBack
+	if config.ShowWeekly && hoursToLoad < 168 {
+		hoursToLoad = 168
+	}
+	return data.LoadUsageData(config.DataPaths, hoursToLoad)
+}
+
 // ResumeMsg creates a resumeMsg for external callers (e.g. SIGCONT handler)
 func ResumeMsg() tea.Msg {
 	return resumeMsg{}
@@ -468,6 +532,21 @@ func tickCmd(interval time.Duration, generation uint64) tea.Cmd {
 	})
 }
 
+// waitForUsageCmd returns a command that blocks until the watcher signals a
+// change. It returns nil without a watcher, and yields no message once the
+// watcher is closed.
+func waitForUsageCmd(w *data.Watcher) tea.Cmd {
+	if w == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		if _, ok := <-w.Changes(); !ok {
+			return nil
+		}
+		return usageChangedMsg{}
+	}
+}
+
 // isOAuthSessionStale checks if the cached OAuth data has a stale session
 // This triggers a refresh when:
 // 1. The session window has completely ended (reset time + 5 hours is in the past)
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/sammcj/ccu/internal/models"
 	"github.com/sammcj/ccu/internal/oauth"
 	"github.com/stretchr/testify/assert"
@@ -57,6 +58,7 @@ func TestDataLoadedMsg_OAuthDataRetainedOnLoadError(t *testing.T) {
 		err:            errors.New("jsonl read failed"),
 		oauthData:      oauthData,
 		oauthFreshData: true,
+		oauthFetched:   true,
 	})
 
 	assert.True(t, m.HasError(), "JSONL error should still display")
@@ -122,3 +124,93 @@ func TestDataLoadedMsg_IdenticalEntriesSkipSessionRebuild(t *testing.T) {
 	assert.NotSame(t, &firstSessions[0], &rebuilt[0],
 		"different entries slice should rebuild session blocks")
 }
+
+func TestUsageChangedMsg_TriggersLoad(t *testing.T) {
+	m := NewModel(models.DefaultConfig())
+	assert.Nil(t, waitForUsageCmd(nil), "no watcher means nothing to wait on")
+
+	updated, cmd := m.Update(usageChangedMsg{})
+	result, ok := updated.(AppModel)
+	assert.True(t, ok)
+	assert.NotNil(t, cmd)
+	assert.Equal(t, uint64(1), result.loadGeneration, "a change should dispatch a load")
+}
+
+// fakeUsageFetcher counts OAuth fetches
+type fakeUsageFetcher struct {
+	data  *oauth.UsageData
+	calls *int
+}
+
+func (f fakeUsageFetcher) FetchUsage() (*oauth.UsageData, error) {
+	*f.calls++
+	return f.data, nil
+}
+
+// stubOAuth makes OAuth available with a fetch returning data, and returns
+// the fetch count
+func stubOAuth(t *testing.T, data *oauth.UsageData) *int {
+	t.Helper()
+	calls := new(int)
+	origAvailable, origClient := oauthAvailable, newOAuthClient
+	oauthAvailable = func() bool { return true }
+	newOAuthClient = func() (usageFetcher, error) { return fakeUsageFetcher{data: data, calls: calls}, nil }
+	t.Cleanup(func() { oauthAvailable, newOAuthClient = origAvailable, origClient })
+	return calls
+}
+
+// runCmds runs cmd, expanding batches, and returns the messages it produced
+func runCmds(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		var msgs []tea.Msg
+		for _, c := range batch {
+			msgs = append(msgs, runCmds(c)...)
+		}
+		return msgs
+	}
+	return []tea.Msg{msg}
+}
+
+// While Claude Code writes, watcher reloads supersede the tick's load. Its
+// OAuth fetch must still be applied, and neither the reloads nor the next
+// tick may fetch again while it is in flight.
+func TestUsageChangedMsg_KeepsTickOAuth(t *testing.T) {
+	oauthData := &oauth.UsageData{}
+	oauthData.FiveHour.Utilisation = 42
+	calls := stubOAuth(t, oauthData)
+	cfg := models.DefaultConfig()
+	cfg.DataPaths = []string{t.TempDir()}
+	m := *NewModel(cfg)
+
+	tickLoad := loadDataCmdWithModel(m.config, &m)
+	m.SetForceRefresh(true)
+	var reloads []tea.Cmd
+	for range 3 {
+		updated, cmd := m.Update(usageChangedMsg{})
+		m = updated.(AppModel)
+		reloads = append(reloads, cmd)
+	}
+	assert.True(t, m.ShouldForceRefresh(), "watcher reloads don't consume a force refresh")
+	nextTick := loadDataCmdWithModel(m.config, &m)
+
+	// The reloads finish first, then the superseded tick load
+	for _, cmd := range append(reloads, nextTick) {
+		for _, msg := range runCmds(cmd) {
+			m = applyMsg(t, m, msg.(dataLoadedMsg))
+		}
+	}
+	m = applyMsg(t, m, tickLoad().(dataLoadedMsg))
+
+	assert.Equal(t, 1, *calls, "one OAuth fetch")
+	assert.Same(t, oauthData, m.GetOAuthData(), "the superseded load's OAuth data is applied")
+	assert.False(t, m.lastOAuthFetch.IsZero())
+	assert.False(t, m.oauthFetching)
+
+	// With the result in, the 4-minute gate holds off the next tick
+	_ = runCmds(loadDataCmdWithModel(m.config, &m))
+	assert.Equal(t, 1, *calls)
+}
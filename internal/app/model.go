@@ -6,8 +6,11 @@ import (
 
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/sammcj/ccu/internal/api"
+	"github.com/sammcj/ccu/internal/data"
 	"github.com/sammcj/ccu/internal/models"
+	"github.com/sammcj/ccu/internal/mqtt"
 	"github.com/sammcj/ccu/internal/oauth"
+	"github.com/sammcj/ccu/internal/pricing"
 )
 
 // AppModel is the Bubbletea application model
@@ -20,7 +23,8 @@ type AppModel struct {
 	sessions       []models.SessionBlock
 	currentSession *models.SessionBlock
 	limits         models.Limits
-	oauthData      *oauth.UsageData // OAuth-fetched usage data
+	oauthData      *oauth.UsageData        // OAuth-fetched usage data
+	unpriced       []pricing.UnpricedModel // Models costed at fallback rates in the loaded entries
 
 	// State
 	loading                 bool
@@ -40,6 +44,7 @@ type AppModel struct {
 	forceRefresh            bool      // Force next refresh to bypass cache (after wake/focus)
 	tickGeneration          uint64    // Incremented on resume to kill stale tick chains
 	loadGeneration          uint64    // Incremented per data load dispatch so stale results are dropped
+	oauthFetching           bool      // An OAuth fetch is in flight, so no other load starts one
 	width                   int
 	height                  int
 
@@ -54,6 +59,12 @@ type AppModel struct {
 
 	// Optional API server
 	apiServer *api.Server
+
+	// Optional MQTT publisher
+	mqtt *mqtt.Publisher
+
+	// Optional JSONL watcher; its signals trigger a reload between ticks
+	watcher *data.Watcher
 }
 
 // NewModel creates a new application model
@@ -160,6 +171,16 @@ func (m *AppModel) GetLimits() models.Limits {
 	return m.limits
 }
 
+// SetUnpricedModels records the models costed at fallback rates
+func (m *AppModel) SetUnpricedModels(unpriced []pricing.UnpricedModel) {
+	m.unpriced = unpriced
+}
+
+// GetUnpricedModels returns the models costed at fallback rates in the loaded entries
+func (m *AppModel) GetUnpricedModels() []pricing.UnpricedModel {
+	return m.unpriced
+}
+
 // GetEntries returns all usage entries
 func (m *AppModel) GetEntries() []models.UsageEntry {
 	return m.entries
@@ -343,6 +364,17 @@ func (m *AppModel) SetAPIServer(s *api.Server) {
 	m.apiServer = s
 }
 
+// SetMQTTPublisher attaches an MQTT publisher so it receives each status.
+func (m *AppModel) SetMQTTPublisher(p *mqtt.Publisher) {
+	m.mqtt = p
+}
+
+// SetWatcher attaches a JSONL watcher so new usage reloads as soon as it is
+// written. Must be called before the program starts.
+func (m *AppModel) SetWatcher(w *data.Watcher) {
+	m.watcher = w
+}
+
 // GetLastRefresh returns the time of the last successful data refresh.
 func (m *AppModel) GetLastRefresh() time.Time {
 	return m.lastRefresh
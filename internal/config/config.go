@@ -3,12 +3,15 @@ package config
 import (
 	"flag"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/sammcj/ccu/internal/modelcheck"
 	"github.com/sammcj/ccu/internal/models"
 	"github.com/sammcj/ccu/internal/oauth"
 )
@@ -20,33 +23,65 @@ var (
 	BuildDate = "unknown"
 )
 
-// ParseFlags parses command-line flags and returns a config
+// ParseFlags parses command-line flags and returns a config. A leading
+// "doctor" argument selects the doctor command; flags may follow it.
 func ParseFlags() (*models.Config, error) {
 	config := models.DefaultConfig()
 
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "doctor" {
+		config.Doctor = true
+		args = args[1:]
+	}
+
 	// Define flags
 	plan := flag.String("plan", "max5", "Plan type: pro, max5, max20, custom")
 	viewMode := flag.String("view", "realtime", "View mode: realtime, daily, monthly")
 	reportMode := flag.String("report", "", "Generate static report to stdout: daily, weekly, monthly (bypasses TUI)")
 	refreshRate := flag.Int("refresh", 30, "UI refresh rate in seconds (1-60, default 30 for JSONL, 60 for OAuth). OAuth API calls are independently gated to every 4 minutes")
 	hoursBack := flag.Int("hours", 24, "Hours of history to load")
-	dataPath := flag.String("data", "", "Path to Claude data directory (default: ~/.claude/projects)")
+	dataPath := flag.String("data", "", "Comma-separated Claude data directories (default: ~/.ccu/data_paths if present, else discover CLAUDE_CONFIG_DIR, ~/.config/claude/projects and ~/.claude/projects)")
 	customTokens := flag.Int("custom-tokens", 0, "Custom token limit (requires -plan=custom)")
 	customCost := flag.Float64("custom-cost", 0, "Custom cost limit USD (requires -plan=custom)")
 	customMessages := flag.Int("custom-messages", 0, "Custom message limit (requires -plan=custom)")
 	showWeekly := flag.Bool("weekly", true, "Show weekly usage panel")
+	watch := flag.Bool("watch", true, "Reload as soon as JSONL files change (inotify on Linux, polling elsewhere)")
+	parseCache := flag.Bool("parse-cache", true, "Keep parsed JSONL in the user cache directory so restarts and reports only parse changed files")
+	modelsFile := flag.String("models-file", "", "Model registry JSON file merged over the built-in models (default: ~/.ccu/models.json if present)")
+	pricingFile := flag.String("pricing-file", "", "Pricing overrides JSON file (default: ~/.ccu/pricing.json if present)")
 	checkModels := flag.Bool("check-models", false, "Check ccu's model pricing/name tables against upstream rates and exit (exit code 1 on drift, 2 on fetch error)")
+	checkModelsSource := flag.String("check-models-source", modelcheck.UpstreamURL, "Upstream pricing dataset for -check-models: URL or local file path")
+	checkModelsFormat := flag.String("check-models-format", "text", "Output format for -check-models: text, json")
+	checkModelsWrite := flag.String("check-models-write", "", "Write upstream rates for missing/drifted models to this new pricing file")
 	showHelp := flag.Bool("help", false, "Show help message")
 	showVersion := flag.Bool("version", false, "Show version information")
 
 	// API server flags
 	apiEnabled := flag.Bool("api", false, "Enable embedded HTTP API server")
-	apiPort := flag.Int("api-port", 19840, "API server listen port")
+	apiPort := flag.Int("api-port", 19840, "API server listen port (0 = serve only on the Unix socket)")
+	apiRateLimit := flag.Float64("api-rate-limit", 0, "API requests per second allowed per client, by token or address, e.g. 5 (0 = unlimited)")
+	apiRateBurst := flag.Int("api-rate-burst", 20, "API requests a client may make at once before -api-rate-limit applies")
+	apiSocket := flag.Bool("api-socket", false, "Also serve the API on a Unix socket only you can connect to, with no token needed")
+	apiSocketPath := flag.String("api-socket-path", "", "API Unix socket path (default: $XDG_RUNTIME_DIR/ccu.sock, else ~/.ccu/ccu.sock)")
 	apiBind := flag.String("api-bind", "0.0.0.0", "API server bind address")
 	apiToken := flag.String("api-token", "", "API bearer token (empty = no auth)")
+	apiTokensFile := flag.String("api-tokens-file", "", "JSON file of named API tokens with scopes, reloaded when it changes (default: ~/.ccu/api_tokens.json if present)")
 	apiAllow := flag.String("api-allow", "", "Comma-separated CIDR ranges to allowlist (empty = allow all)")
-
-	flag.Parse()
+	apiTLSCert := flag.String("api-tls-cert", "", "API TLS certificate PEM file, with -api-tls-key (default: ~/.ccu/api_cert.pem if present)")
+	apiTLSKey := flag.String("api-tls-key", "", "API TLS private key PEM file (default: ~/.ccu/api_key.pem if present)")
+	apiTLSSelfSigned := flag.Bool("api-tls-self-signed", false, "Serve the API over TLS with a self-signed certificate kept in ~/.ccu when no certificate is set")
+	apiTLSClientCA := flag.String("api-tls-client-ca", "", "Require API clients to present a certificate signed by this CA PEM file (default: ~/.ccu/api_client_ca.pem if present)")
+
+	// MQTT flags
+	mqttBroker := flag.String("mqtt-broker", "", "Publish status to this MQTT broker, e.g. tcp://homeassistant.local:1883 (empty = off)")
+	mqttUsername := flag.String("mqtt-username", "", "MQTT username")
+	mqttPassword := flag.String("mqtt-password", "", "MQTT password (prefer the CCU_MQTT_PASSWORD env var)")
+	mqttTopic := flag.String("mqtt-topic", "ccu", "MQTT topic prefix status is published under")
+	mqttDiscoveryPrefix := flag.String("mqtt-discovery-prefix", "homeassistant", "Home Assistant MQTT discovery prefix (empty = don't publish discovery configs)")
+
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return nil, err
+	}
 
 	// Handle help
 	if *showHelp {
@@ -60,15 +95,13 @@ func ParseFlags() (*models.Config, error) {
 		os.Exit(0)
 	}
 
-	// Set data path
+	// Data paths (precedence: CLI flag > env var > paths file > discovery at load)
 	if *dataPath != "" {
-		config.DataPath = *dataPath
+		config.DataPaths = parsePathList(strings.Split(*dataPath, ","))
+	} else if v := os.Getenv("CCU_DATA_PATHS"); v != "" {
+		config.DataPaths = parsePathList(strings.Split(v, ","))
 	} else {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("getting home directory: %w", err)
-		}
-		config.DataPath = filepath.Join(homeDir, ".claude", "projects")
+		config.DataPaths = readDataPathsFile()
 	}
 
 	// flag.Visit only walks flags that were explicitly provided on the command line.
@@ -155,8 +188,24 @@ func ParseFlags() (*models.Config, error) {
 
 	// Set weekly flag
 	config.ShowWeekly = *showWeekly
+	config.Watch = *watch
+	config.ParseCache = *parseCache
 
 	config.CheckModels = *checkModels
+	switch *checkModelsFormat {
+	case "text", "json":
+	default:
+		return nil, fmt.Errorf("invalid check-models format: %s (must be text or json)", *checkModelsFormat)
+	}
+	config.ModelCheck = models.ModelCheckConfig{
+		Source:    *checkModelsSource,
+		Format:    *checkModelsFormat,
+		WritePath: *checkModelsWrite,
+	}
+
+	// Models and pricing files (precedence: CLI flag > env var > default file if present)
+	config.ModelsFile = resolveUserFile(*modelsFile, os.Getenv("CCU_MODELS_FILE"), "models.json")
+	config.PricingFile = resolveUserFile(*pricingFile, os.Getenv("CCU_PRICING_FILE"), "pricing.json")
 
 	// API server configuration (precedence: CLI flag > env var > token file)
 	config.API.Enabled = *apiEnabled
@@ -169,7 +218,29 @@ func ParseFlags() (*models.Config, error) {
 	}
 
 	// Port: explicit CLI flag wins, otherwise env var, otherwise the flag default.
-	config.API.Port = resolveAPIPort(explicit["api-port"], *apiPort, os.Getenv("CCU_API_PORT"))
+	config.API.Port = resolveAPIInt(explicit["api-port"], *apiPort, os.Getenv("CCU_API_PORT"))
+
+	// Rate limit: explicit CLI flag wins, otherwise env var, otherwise the flag default.
+	config.API.RateLimit = resolveAPIFloat(explicit["api-rate-limit"], *apiRateLimit, os.Getenv("CCU_API_RATE_LIMIT"))
+	config.API.RateBurst = resolveAPIInt(explicit["api-rate-burst"], *apiRateBurst, os.Getenv("CCU_API_RATE_BURST"))
+	if config.API.RateLimit < 0 || config.API.RateBurst < 1 {
+		return nil, fmt.Errorf("invalid API rate limit: -api-rate-limit must be at least 0 and -api-rate-burst at least 1")
+	}
+
+	// Socket: explicit CLI flag wins, otherwise env var; setting a path enables it
+	socket := *apiSocket
+	if !explicit["api-socket"] {
+		if v := os.Getenv("CCU_API_SOCKET"); v == "true" || v == "1" {
+			socket = true
+		}
+	}
+	if *apiSocketPath != "" {
+		config.API.SocketPath = *apiSocketPath
+	} else if v := os.Getenv("CCU_API_SOCKET_PATH"); v != "" {
+		config.API.SocketPath = v
+	} else if socket {
+		config.API.SocketPath = defaultSocketPath()
+	}
 
 	// Bind: explicit CLI flag wins, otherwise env var, otherwise the flag default.
 	if explicit["api-bind"] {
@@ -188,6 +259,7 @@ func ParseFlags() (*models.Config, error) {
 		// Token file fallback
 		config.API.Token = readTokenFile()
 	}
+	config.API.TokensFile = resolveUserFile(*apiTokensFile, os.Getenv("CCU_API_TOKENS_FILE"), "api_tokens.json")
 
 	if *apiAllow != "" {
 		config.API.AllowedCIDRs = parseCIDRList(*apiAllow)
@@ -195,12 +267,83 @@ func ParseFlags() (*models.Config, error) {
 		config.API.AllowedCIDRs = parseCIDRList(v)
 	}
 
+	// TLS files (precedence: CLI flag > env var > default file if present)
+	config.API.TLSCert = resolveUserFile(*apiTLSCert, os.Getenv("CCU_API_TLS_CERT"), "api_cert.pem")
+	config.API.TLSKey = resolveUserFile(*apiTLSKey, os.Getenv("CCU_API_TLS_KEY"), "api_key.pem")
+	config.API.TLSClientCA = resolveUserFile(*apiTLSClientCA, os.Getenv("CCU_API_TLS_CLIENT_CA"), "api_client_ca.pem")
+	config.API.TLSSelfSigned = *apiTLSSelfSigned
+	if !explicit["api-tls-self-signed"] {
+		if v := os.Getenv("CCU_API_TLS_SELF_SIGNED"); v == "true" || v == "1" {
+			config.API.TLSSelfSigned = true
+		}
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		config.API.ConfigDir = filepath.Join(homeDir, ".ccu")
+	}
+
+	// MQTT configuration (precedence: CLI flag > env var > default)
+	broker := resolveString(explicit["mqtt-broker"], *mqttBroker, os.Getenv("CCU_MQTT_BROKER"))
+	if broker != "" {
+		var err error
+		if broker, err = normaliseBroker(broker); err != nil {
+			return nil, err
+		}
+	}
+	config.MQTT = models.MQTTConfig{
+		Broker:          broker,
+		Username:        resolveString(explicit["mqtt-username"], *mqttUsername, os.Getenv("CCU_MQTT_USERNAME")),
+		Password:        resolveString(explicit["mqtt-password"], *mqttPassword, os.Getenv("CCU_MQTT_PASSWORD")),
+		TopicPrefix:     strings.Trim(resolveString(explicit["mqtt-topic"], *mqttTopic, os.Getenv("CCU_MQTT_TOPIC")), "/"),
+		DiscoveryPrefix: strings.Trim(resolveString(explicit["mqtt-discovery-prefix"], *mqttDiscoveryPrefix, os.Getenv("CCU_MQTT_DISCOVERY_PREFIX")), "/"),
+	}
+	if config.MQTT.TopicPrefix == "" || strings.ContainsAny(config.MQTT.TopicPrefix+config.MQTT.DiscoveryPrefix, "#+") {
+		return nil, fmt.Errorf("invalid MQTT topic: -mqtt-topic must be set, and neither it nor -mqtt-discovery-prefix may contain + or #")
+	}
+
 	return config, nil
 }
 
-// resolveAPIPort picks the API port using precedence: explicit CLI flag > env var > flag default.
-// An unparseable env value falls back to the flag default rather than erroring.
-func resolveAPIPort(explicit bool, flagVal int, env string) int {
+// resolveString picks a string setting using precedence: explicit CLI flag >
+// env var > flag default. An explicit empty flag overrides the env var, so a
+// default such as the discovery prefix can be turned off.
+func resolveString(explicit bool, flagVal, env string) string {
+	if explicit || env == "" {
+		return flagVal
+	}
+	return env
+}
+
+// normaliseBroker turns a broker address into the URL the MQTT client wants:
+// a bare host or host:port gets tcp://, and a missing port the scheme's
+// default (1883, or 8883 for TLS)
+func normaliseBroker(broker string) (string, error) {
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+	u, err := url.Parse(broker)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid MQTT broker %q: want e.g. tcp://host:1883", broker)
+	}
+	port := "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts", "tcps":
+		port = "8883"
+	case "ws", "wss":
+		return broker, nil // the port comes from the URL scheme
+	default:
+		return "", fmt.Errorf("invalid MQTT broker %q: scheme must be tcp, mqtt, ssl, mqtts, ws or wss", broker)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return u.String(), nil
+}
+
+// resolveAPIInt picks an integer API setting such as the port using precedence:
+// explicit CLI flag > env var > flag default. An unparseable env value falls
+// back to the flag default rather than erroring.
+func resolveAPIInt(explicit bool, flagVal int, env string) int {
 	if explicit || env == "" {
 		return flagVal
 	}
@@ -210,6 +353,52 @@ func resolveAPIPort(explicit bool, flagVal int, env string) int {
 	return flagVal
 }
 
+// resolveAPIFloat is resolveAPIInt for decimal settings such as the rate limit
+func resolveAPIFloat(explicit bool, flagVal float64, env string) float64 {
+	if explicit || env == "" {
+		return flagVal
+	}
+	if v, err := strconv.ParseFloat(env, 64); err == nil {
+		return v
+	}
+	return flagVal
+}
+
+// defaultSocketPath returns $XDG_RUNTIME_DIR/ccu.sock, or ~/.ccu/ccu.sock
+// where there is no runtime directory (e.g. macOS). Both directories are
+// private to the user.
+func defaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "ccu.sock")
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), fmt.Sprintf("ccu-%d.sock", os.Getuid()))
+	}
+	return filepath.Join(homeDir, ".ccu", "ccu.sock")
+}
+
+// resolveUserFile picks an optional config file using precedence: CLI flag >
+// env var > $HOME/.ccu/<name>. An explicit path is returned even if missing so
+// loading reports the error; the default is used only if it exists.
+func resolveUserFile(flagVal, env, name string) string {
+	if flagVal != "" {
+		return flagVal
+	}
+	if env != "" {
+		return env
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(homeDir, ".ccu", name)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
 // readTokenFile reads a bearer token from $HOME/.ccu/.api_token if present.
 func readTokenFile() string {
 	homeDir, err := os.UserHomeDir()
@@ -223,6 +412,45 @@ func readTokenFile() string {
 	return strings.TrimSpace(string(data))
 }
 
+// readDataPathsFile reads data roots from $HOME/.ccu/data_paths if present:
+// one path per line, with blank lines and # comments ignored.
+func readDataPathsFile() []string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(homeDir, ".ccu", "data_paths"))
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	for line := range strings.Lines(string(data)) {
+		if line = strings.TrimSpace(line); !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return parsePathList(lines)
+}
+
+// parsePathList trims each path, drops empty ones and expands a leading ~ to
+// the home directory. Returns nil when no paths remain.
+func parsePathList(paths []string) []string {
+	var out []string
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if p == "~" || strings.HasPrefix(p, "~/") {
+			if homeDir, err := os.UserHomeDir(); err == nil {
+				p = filepath.Join(homeDir, p[1:])
+			}
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
 // parseCIDRList splits a comma-separated string of CIDR ranges into a slice.
 func parseCIDRList(s string) []string {
 	parts := strings.Split(s, ",")
@@ -242,6 +470,7 @@ func printHelp() {
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  ccu [flags]")
+	fmt.Println("  ccu doctor [-data=...]    Report transcript lines ccu can't parse or doesn't recognise")
 	fmt.Println()
 	fmt.Println("Flags:")
 	flag.PrintDefaults()
@@ -259,11 +488,24 @@ func printHelp() {
 	fmt.Println("  ccu -report=daily -hours=90           # Print last 90 days of daily usage")
 	fmt.Println("  ccu -refresh=10                        # Refresh every 10 seconds")
 	fmt.Println("  ccu -hours=48                          # Load last 48 hours of data")
+	fmt.Println("  ccu -data=~/.claude/projects,~/work-claude/projects")
+	fmt.Println("                                         # Read several Claude data directories")
 	fmt.Println("  ccu -plan=custom -custom-tokens=50000  # Use custom token limit")
 	fmt.Println("  ccu -check-models                      # Check model pricing tables against upstream")
+	fmt.Println("  ccu -check-models -check-models-source=litellm.json -check-models-format=json")
+	fmt.Println("                                         # Offline check with JSON findings")
+	fmt.Println("  ccu doctor                             # Check transcripts for parse errors and format changes")
+	fmt.Println("  ccu -pricing-file=prices.json          # Merge pricing overrides over built-in rates")
+	fmt.Println("  ccu -models-file=models.json           # Map new model IDs before a release supports them")
 	fmt.Println("  ccu -api -api-port=19840               # Enable HTTP API server")
 	fmt.Println("  ccu -api -api-token=secret             # API server with bearer token auth")
 	fmt.Println("  ccu -api -api-allow=192.168.1.0/24     # API server with IP allowlist")
+	fmt.Println("  ccu -api -api-tokens-file=tokens.json  # API server with named, scoped tokens")
+	fmt.Println("  ccu -api -api-socket -api-port=0       # API server on a Unix socket only")
+	fmt.Println("  ccu -api -api-tls-self-signed          # API server over HTTPS with a self-signed certificate")
+	fmt.Println("  ccu -api -api-tls-cert=cert.pem -api-tls-key=key.pem -api-tls-client-ca=ca.pem")
+	fmt.Println("                                         # HTTPS requiring client certificates")
+	fmt.Println("  ccu -mqtt-broker=homeassistant.local   # Publish status to MQTT with Home Assistant discovery")
 	fmt.Println()
 }
 
@@ -13,10 +13,10 @@ import (
 // flag.CommandLine and calls flag.Parse against os.Args (which under `go test`
 // carries -test.* flags), and it may reach the macOS keychain via
 // oauth.DetectPlan. Testing the extractable pure helpers instead keeps these
-// tests hermetic and repeatable. resolveAPIPort covers the CLI-flag/env-var
+// tests hermetic and repeatable. resolveAPIInt covers the CLI-flag/env-var
 // precedence and invalid-port fallback logic used by ParseFlags.
 
-func TestResolveAPIPort(t *testing.T) {
+func TestResolveAPIInt(t *testing.T) {
 	const flagDefault = 19840
 
 	tests := []struct {
@@ -36,12 +36,19 @@ func TestResolveAPIPort(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := resolveAPIPort(tt.explicit, tt.flagVal, tt.env)
+			got := resolveAPIInt(tt.explicit, tt.flagVal, tt.env)
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
 
+func TestResolveAPIFloat(t *testing.T) {
+	assert.Equal(t, 2.5, resolveAPIFloat(true, 2.5, "10"), "explicit flag beats env")
+	assert.Equal(t, 0.5, resolveAPIFloat(false, 5, "0.5"))
+	assert.Equal(t, 5.0, resolveAPIFloat(false, 5, "fast"), "invalid env falls back to default")
+	assert.Equal(t, 5.0, resolveAPIFloat(false, 5, ""))
+}
+
 func TestParseCIDRList(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -95,3 +102,102 @@ func TestReadTokenFile(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveUserFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	defaultPath := filepath.Join(home, ".ccu", "pricing.json")
+
+	// Explicit paths are returned whether or not they exist
+	assert.Equal(t, "/flag.json", resolveUserFile("/flag.json", "/env.json", "pricing.json"))
+	assert.Equal(t, "/env.json", resolveUserFile("", "/env.json", "pricing.json"))
+
+	// The default is only used once it exists
+	assert.Empty(t, resolveUserFile("", "", "pricing.json"))
+	require.NoError(t, os.MkdirAll(filepath.Dir(defaultPath), 0o700))
+	require.NoError(t, os.WriteFile(defaultPath, []byte(`{"models":{}}`), 0o600))
+	assert.Equal(t, defaultPath, resolveUserFile("", "", "pricing.json"))
+	assert.Empty(t, resolveUserFile("", "", "models.json"))
+}
+
+func TestParsePathList(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		input []string
+		want  []string
+	}{
+		{"trims and drops empty", []string{" /a ", "", "/b"}, []string{"/a", "/b"}},
+		{"expands home", []string{"~/.claude/projects", "~"}, []string{filepath.Join(home, ".claude/projects"), home}},
+		{"tilde user is left alone", []string{"~other/x"}, []string{"~other/x"}},
+		{"nothing left", []string{" "}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parsePathList(tt.input))
+		})
+	}
+}
+
+func TestReadDataPathsFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	assert.Nil(t, readDataPathsFile())
+
+	dir := filepath.Join(home, ".ccu")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	content := "# one root per line\n/srv/claude/projects\n\n~/work-claude/projects\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data_paths"), []byte(content), 0o600))
+
+	assert.Equal(t, []string{"/srv/claude/projects", filepath.Join(home, "work-claude/projects")}, readDataPathsFile())
+}
+
+func TestDefaultSocketPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	assert.Equal(t, "/run/user/1000/ccu.sock", defaultSocketPath())
+
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	assert.Equal(t, filepath.Join(home, ".ccu", "ccu.sock"), defaultSocketPath(), "no runtime directory, e.g. macOS")
+}
+
+func TestResolveString(t *testing.T) {
+	assert.Equal(t, "flag", resolveString(true, "flag", "env"), "explicit flag beats env")
+	assert.Equal(t, "", resolveString(true, "", "env"), "an explicit empty flag turns a setting off")
+	assert.Equal(t, "env", resolveString(false, "default", "env"))
+	assert.Equal(t, "default", resolveString(false, "default", ""))
+}
+
+func TestNormaliseBroker(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"homeassistant.local", "tcp://homeassistant.local:1883", false},
+		{"10.0.0.2:1884", "tcp://10.0.0.2:1884", false},
+		{"tcp://broker:1883", "tcp://broker:1883", false},
+		{"mqtt://broker", "mqtt://broker:1883", false},
+		{"ssl://broker", "ssl://broker:8883", false},
+		{"mqtts://broker:9883", "mqtts://broker:9883", false},
+		{"wss://broker/mqtt", "wss://broker/mqtt", false},
+		{"http://broker", "", true},
+		{"tcp://", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := normaliseBroker(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package data
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression schemes a transcript or archive may use
+const (
+	compressionNone = ""
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
+// packing describes how a transcript file is stored: optionally compressed,
+// and either a single JSONL stream or a tar archive of them
+type packing struct {
+	compression string
+	tar         bool
+}
+
+// packedSuffixes maps file name suffixes to their packing. Longer suffixes
+// come first so ".tar.gz" isn't taken for a plain ".gz".
+var packedSuffixes = []struct {
+	suffix string
+	packing
+}{
+	{".jsonl.gz", packing{compression: compressionGzip}},
+	{".jsonl.zst", packing{compression: compressionZstd}},
+	{".tar.gz", packing{compression: compressionGzip, tar: true}},
+	{".tgz", packing{compression: compressionGzip, tar: true}},
+	{".tar.zst", packing{compression: compressionZstd, tar: true}},
+	{".tzst", packing{compression: compressionZstd, tar: true}},
+	{".tar", packing{tar: true}},
+	{".jsonl", packing{}},
+}
+
+// packingOf returns how a file is stored, judging by its name, and whether it
+// is a transcript or transcript archive at all
+func packingOf(name string) (packing, bool) {
+	lower := strings.ToLower(name)
+	for _, s := range packedSuffixes {
+		if strings.HasSuffix(lower, s.suffix) {
+			return s.packing, true
+		}
+	}
+	return packing{}, false
+}
+
+// isJSONL reports whether a file name is a JSONL transcript, plain,
+// compressed or archived
+func isJSONL(name string) bool {
+	_, ok := packingOf(name)
+	return ok
+}
+
+// isPacked reports whether a transcript file is compressed or archived, and
+// so must be read whole rather than resumed from an offset
+func isPacked(name string) bool {
+	p, _ := packingOf(name)
+	return p != packing{}
+}
+
+// readPacked reads every entry from a compressed transcript or a tar archive
+// of transcripts (see eachTranscript). The result is complete with no resume
+// mark: archives are rewritten whole, not appended to. A member that fails to
+// scan (e.g. an oversize line) is counted as a skipped file and the rest of
+// the archive is still read; only a broken archive or compression stream is
+// returned as an error. Parameters are as for readJSONLFileWithFilter.
+func readPacked(filePath string, cutoff time.Time, seen map[string]bool, scanBuf []byte, stats *readStats) (tailRead, error) {
+	var result tailRead
+	p, _ := packingOf(filePath)
+	err := eachTranscript(filePath, func(name string, r io.Reader) error {
+		t, err := scanJSONL(r, name, 0, cutoff, seen, scanBuf, stats)
+		result.entries = append(result.entries, t.entries...)
+		if err != nil && p.tar && stats != nil {
+			stats.skippedFiles++
+			stats.lastErr = err
+			return nil
+		}
+		return err
+	})
+	result.complete = len(result.entries)
+	return result, err
+}
+
+// eachTranscript calls fn with each JSONL stream in a transcript file: the
+// file itself, decompressed if need be, or each transcript member of a tar
+// archive in archive order, named "archive:member". Members that aren't
+// transcripts are skipped and compressed members are decompressed. An error
+// from fn stops the walk and is returned.
+func eachTranscript(filePath string, fn func(name string, r io.Reader) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+	defer file.Close()
+
+	p, _ := packingOf(filePath)
+	r, closeReader, err := decompress(file, p.compression)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", filePath, err)
+	}
+	defer closeReader()
+	if !p.tar {
+		return fn(filePath, r)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive %s: %w", filePath, err)
+		}
+
+		mp, ok := packingOf(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !ok || mp.tar {
+			continue
+		}
+		member, closeMember, err := decompress(tr, mp.compression)
+		if err != nil {
+			return fmt.Errorf("reading %s in %s: %w", hdr.Name, filePath, err)
+		}
+		err = fn(filePath+":"+hdr.Name, member)
+		closeMember()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// decompress wraps r to undo the given compression. The returned func
+// releases the decompressor.
+func decompress(r io.Reader, compression string) (io.Reader, func(), error) {
+	switch compression {
+	case compressionGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("gzip: %w", err)
+		}
+		return gz, func() { gz.Close() }, nil
+	case compressionZstd:
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("zstd: %w", err)
+		}
+		return zr, zr.Close, nil
+	default:
+		return r, func() {}, nil
+	}
+}
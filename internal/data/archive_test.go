@@ -0,0 +1,179 @@
+package data
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	defer enc.Close()
+	return enc.EncodeAll(data, nil)
+}
+
+// tarBytes builds a tar archive of the named members, in order
+func tarBytes(t *testing.T, members ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, m := range members {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: m[0], Mode: 0o644, Size: int64(len(m[1])), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(m[1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func TestPackingOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   packing
+		wantOK bool
+	}{
+		{"session.jsonl", packing{}, true},
+		{"SESSION.JSONL", packing{}, true},
+		{"session.jsonl.gz", packing{compression: compressionGzip}, true},
+		{"session.jsonl.zst", packing{compression: compressionZstd}, true},
+		{"2025-06.tar", packing{tar: true}, true},
+		{"2025-06.tar.gz", packing{compression: compressionGzip, tar: true}, true},
+		{"2025-06.tgz", packing{compression: compressionGzip, tar: true}, true},
+		{"2025-06.tar.zst", packing{compression: compressionZstd, tar: true}, true},
+		{"notes.txt.gz", packing{}, false},
+		{"session.json", packing{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := packingOf(tt.name)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadPacked(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	line1 := entryLine(now.Add(-20*time.Minute), "msg_1", "req_1", 10, 5)
+	line2 := entryLine(now.Add(-10*time.Minute), "msg_2", "req_2", 20, 10)
+	transcript := []byte(line1 + "\n" + line2 + "\n")
+
+	archive := tarBytes(t,
+		[2]string{"proj/a.jsonl", line1 + "\n"},
+		[2]string{"proj/README.md", "not a transcript"},
+		[2]string{"proj/b.jsonl.gz", string(gzipBytes(t, []byte(line2+"\n")))},
+	)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"a.jsonl.gz", gzipBytes(t, transcript)},
+		{"a.jsonl.zst", zstdBytes(t, transcript)},
+		{"month.tar", archive},
+		{"month.tar.gz", gzipBytes(t, archive)},
+		{"month.tar.zst", zstdBytes(t, archive)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			require.NoError(t, os.WriteFile(path, tt.data, 0o644))
+
+			stats := &readStats{}
+			r, err := readPacked(path, time.Time{}, nil, make([]byte, 64*1024), stats)
+			require.NoError(t, err)
+			require.Len(t, r.entries, 2)
+			assert.Equal(t, "msg_1", r.entries[0].MessageID)
+			assert.Equal(t, "msg_2", r.entries[1].MessageID)
+			assert.Equal(t, 2, r.complete)
+			assert.Zero(t, r.mark.offset, "packed files are never resumed")
+			assert.Zero(t, stats.parseErrors)
+		})
+	}
+
+	t.Run("corrupt archive", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "broken.jsonl.gz")
+		require.NoError(t, os.WriteFile(path, []byte("not gzip"), 0o644))
+		_, err := readPacked(path, time.Time{}, nil, make([]byte, 64*1024), &readStats{})
+		assert.ErrorContains(t, err, "gzip")
+	})
+
+	t.Run("bad member doesn't hide later members", func(t *testing.T) {
+		lowerMaxLineBytes(t, 1024)
+		path := filepath.Join(t.TempDir(), "month.tar.gz")
+		archive := tarBytes(t,
+			[2]string{"proj/huge.jsonl", strings.Repeat("x", 2048) + "\n"},
+			[2]string{"proj/a.jsonl", line1 + "\n"},
+			[2]string{"proj/b.jsonl.gz", string(gzipBytes(t, []byte(line2+"\n")))},
+		)
+		require.NoError(t, os.WriteFile(path, gzipBytes(t, archive), 0o644))
+
+		// The scanner's limit is the larger of the cap and its buffer
+		stats := &readStats{}
+		r, err := readPacked(path, time.Time{}, nil, make([]byte, 256), stats)
+		require.NoError(t, err)
+		require.Len(t, r.entries, 2)
+		assert.Equal(t, "msg_1", r.entries[0].MessageID)
+		assert.Equal(t, "msg_2", r.entries[1].MessageID)
+		assert.Equal(t, 1, stats.skippedFiles)
+		assert.ErrorContains(t, stats.lastErr, "month.tar.gz:proj/huge.jsonl")
+	})
+
+	t.Run("parse errors name the member", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "month.tar")
+		require.NoError(t, os.WriteFile(path, tarBytes(t, [2]string{"bad.jsonl", `{"type":"assistant",` + "\n"}), 0o644))
+		stats := &readStats{}
+		_, err := readPacked(path, time.Time{}, nil, make([]byte, 64*1024), stats)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.parseErrors)
+		assert.ErrorContains(t, stats.lastErr, "month.tar:bad.jsonl:1")
+	})
+}
+
+func TestLoadUsageData_Archives(t *testing.T) {
+	resetLoadCache()
+	now := time.Now().UTC().Truncate(time.Second)
+	dir := t.TempDir()
+	shared := entryLine(now.Add(-30*time.Minute), "msg_shared", "req_shared", 10, 5)
+
+	// An archived copy of a project still on disk, plus an archived month
+	writeJSONL(t, dir, "live.jsonl", shared, entryLine(now.Add(-5*time.Minute), "msg_live", "req_live", 10, 5))
+	archived := strings.Join([]string{shared, entryLine(now.Add(-20*time.Minute), "msg_old", "req_old", 10, 5)}, "\n") + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "archive.tar.gz"),
+		gzipBytes(t, tarBytes(t, [2]string{"p/s.jsonl", archived})), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.jsonl.zst"),
+		zstdBytes(t, []byte(entryLine(now.Add(-10*time.Minute), "msg_zst", "req_zst", 10, 5)+"\n")), 0o644))
+
+	first, err := LoadUsageData([]string{dir}, 24)
+	require.NoError(t, err)
+	ids := make([]string, len(first))
+	for i, e := range first {
+		ids[i] = e.MessageID
+	}
+	assert.Equal(t, []string{"msg_shared", "msg_old", "msg_zst", "msg_live"}, ids, "the shared message is counted once")
+
+	second, err := LoadUsageData([]string{dir}, 24)
+	require.NoError(t, err)
+	assert.True(t, &first[0] == &second[0], "unchanged archives are served from the cache")
+}
@@ -0,0 +1,326 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/sammcj/ccu/internal/models"
+)
+
+// diagnosisExamples caps how many locations are listed per problem
+const diagnosisExamples = 5
+
+// knownEntryTypes are the transcript line types Claude Code is known to
+// write. Only assistant lines carry usage; a type outside this list suggests
+// the transcript format has changed.
+var knownEntryTypes = map[string]bool{
+	"assistant":             true,
+	"user":                  true,
+	"system":                true,
+	"summary":               true,
+	"file-history-snapshot": true,
+	"queue-operation":       true,
+}
+
+// knownUsageFields are the message.usage fields, nested ones dotted, that the
+// parser reads or knowingly ignores. A new field may be a new kind of billable
+// usage that costs are missing.
+var knownUsageFields = map[string]bool{
+	"input_tokens":                             true,
+	"output_tokens":                            true,
+	"cache_creation_input_tokens":              true,
+	"cache_read_input_tokens":                  true,
+	"cache_creation":                           true,
+	"cache_creation.ephemeral_5m_input_tokens": true,
+	"cache_creation.ephemeral_1h_input_tokens": true,
+	"server_tool_use":                          true,
+	"server_tool_use.web_search_requests":      true,
+	"server_tool_use.web_fetch_requests":       true,
+	"service_tier":                             true,
+}
+
+// Issue counts occurrences of one problem, in total and per file (archive
+// members as "archive:member"), and keeps the first few locations
+// (file:line) with detail
+type Issue struct {
+	Count    int
+	Files    map[string]int
+	Examples []string
+}
+
+func (i *Issue) add(file, example string) {
+	i.Count++
+	if i.Files == nil {
+		i.Files = make(map[string]int)
+	}
+	i.Files[file]++
+	if len(i.Examples) < diagnosisExamples {
+		i.Examples = append(i.Examples, example)
+	}
+}
+
+// Tally counts occurrences of one value, e.g. an unknown model, and where it
+// was first seen
+type Tally struct {
+	Count     int
+	FirstSeen string // file:line
+}
+
+// Diagnosis is the result of Diagnose: what in the transcripts ccu can't read
+// or doesn't recognise
+type Diagnosis struct {
+	Roots    []string
+	Files    int // transcript streams, counting each archive member
+	Lines    int
+	Usage    int
+	Problems struct {
+		UnreadableFiles Issue
+		ParseErrors     Issue
+		OversizeLines   Issue
+		MissingFields   Issue
+	}
+	UnknownTypes       map[string]*Tally
+	UnknownModels      map[string]*Tally
+	UnknownUsageFields map[string]*Tally
+}
+
+// OK reports whether nothing was found: every line parsed and every type,
+// model and usage field is known
+func (d *Diagnosis) OK() bool {
+	p := d.Problems
+	return p.UnreadableFiles.Count+p.ParseErrors.Count+p.OversizeLines.Count+p.MissingFields.Count == 0 &&
+		len(d.UnknownTypes)+len(d.UnknownModels)+len(d.UnknownUsageFields) == 0
+}
+
+// diagnosticEntry is the subset of a transcript line Diagnose inspects. Usage
+// is kept raw so fields the parser doesn't know can be listed.
+type diagnosticEntry struct {
+	Type      *string `json:"type"`
+	Timestamp string  `json:"timestamp"`
+	RequestID string  `json:"requestId"`
+	Message   *struct {
+		ID    string                     `json:"id"`
+		Model string                     `json:"model"`
+		Usage map[string]json.RawMessage `json:"usage"`
+	} `json:"message"`
+}
+
+// Diagnose reads every transcript under the roots, including compressed ones
+// and archives, and reports lines ccu skips or can't fully understand: parse
+// errors, lines over maxJSONLLineBytes, usage without IDs or a valid
+// timestamp, and unknown entry types, models and usage fields. Unlike
+// LoadUsageData it keeps going past an oversize line, and reads the whole
+// history. With no roots, the standard locations are discovered.
+func Diagnose(roots []string) (*Diagnosis, error) {
+	if len(roots) == 0 {
+		var err error
+		if roots, err = GetDefaultDataPaths(); err != nil {
+			return nil, err
+		}
+	}
+	roots = uniquePaths(roots)
+	files, err := listJSONLFiles(roots)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no JSONL files found in %s", strings.Join(roots, ", "))
+	}
+
+	d := &Diagnosis{
+		Roots:              roots,
+		UnknownTypes:       make(map[string]*Tally),
+		UnknownModels:      make(map[string]*Tally),
+		UnknownUsageFields: make(map[string]*Tally),
+	}
+	for _, f := range files {
+		err := eachTranscript(f.path, func(name string, r io.Reader) error {
+			d.Files++
+			return d.scan(name, r)
+		})
+		if err != nil {
+			d.Problems.UnreadableFiles.add(f.path, err.Error())
+		}
+	}
+	return d, nil
+}
+
+// scan checks each line of one transcript stream
+func (d *Diagnosis) scan(name string, r io.Reader) error {
+	return eachLine(r, maxJSONLLineBytes, func(lineNum int, line []byte, size int) {
+		d.Lines++
+		at := fmt.Sprintf("%s:%d", name, lineNum)
+		switch {
+		case size > maxJSONLLineBytes:
+			d.Problems.OversizeLines.add(name, fmt.Sprintf("%s (%.1f MiB)", at, float64(size)/(1<<20)))
+		case len(bytes.TrimSpace(line)) > 0:
+			d.check(name, at, line)
+		}
+	})
+}
+
+// check inspects one line of the named stream
+func (d *Diagnosis) check(name, at string, line []byte) {
+	var e diagnosticEntry
+	if err := json.Unmarshal(line, &e); err != nil {
+		d.Problems.ParseErrors.add(name, fmt.Sprintf("%s: %v", at, err))
+		return
+	}
+
+	typ := "(missing)"
+	if e.Type != nil {
+		typ = *e.Type
+	}
+	if !knownEntryTypes[typ] {
+		tally(d.UnknownTypes, typ, at)
+	}
+	if e.Message == nil || len(e.Message.Usage) == 0 {
+		return
+	}
+	if typ == "assistant" {
+		d.Usage++
+	}
+
+	for field, value := range e.Message.Usage {
+		if !knownUsageFields[field] {
+			tally(d.UnknownUsageFields, field, at)
+			continue
+		}
+		// Nested objects can grow new counters too
+		var nested map[string]json.RawMessage
+		if json.Unmarshal(value, &nested) == nil {
+			for sub := range nested {
+				if key := field + "." + sub; !knownUsageFields[key] {
+					tally(d.UnknownUsageFields, key, at)
+				}
+			}
+		}
+	}
+
+	// The remaining checks mirror what ParseJSONLLine needs from an entry it keeps
+	if typ != "assistant" || e.Message.Model == models.SyntheticModel {
+		return
+	}
+	var missing []string
+	if e.Message.ID == "" {
+		missing = append(missing, "message id")
+	}
+	if e.RequestID == "" {
+		missing = append(missing, "request id")
+	}
+	if _, err := parseTimestamp(e.Timestamp); err != nil {
+		missing = append(missing, "valid timestamp")
+	}
+	if len(missing) > 0 {
+		d.Problems.MissingFields.add(name, fmt.Sprintf("%s: no %s", at, strings.Join(missing, ", ")))
+	}
+	if e.Message.Model != "" {
+		if _, ok := models.LookupModel(e.Message.Model); !ok {
+			tally(d.UnknownModels, e.Message.Model, at)
+		}
+	}
+}
+
+func tally(m map[string]*Tally, key, at string) {
+	if t, ok := m[key]; ok {
+		t.Count++
+		return
+	}
+	m[key] = &Tally{Count: 1, FirstSeen: at}
+}
+
+// eachLine calls fn with each line of r, without its line ending, and the
+// line's full size. Lines over max bytes are passed empty, with their size,
+// rather than ending the read as bufio.Scanner would.
+func eachLine(r io.Reader, max int, fn func(lineNum int, line []byte, size int)) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var line []byte
+	size, lineNum := 0, 0
+	for {
+		chunk, err := br.ReadSlice('\n')
+		size += len(chunk)
+		if size <= max+1 { // +1 for the newline
+			line = append(line, chunk...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if size > 0 {
+			lineNum++
+			n := size
+			if len(chunk) > 0 && chunk[len(chunk)-1] == '\n' {
+				n--
+			}
+			if size > len(line) {
+				fn(lineNum, nil, n) // oversize; the rest was dropped
+			} else {
+				fn(lineNum, bytes.TrimRight(line, "\r\n"), n)
+			}
+		}
+		if err != nil {
+			return nil
+		}
+		line, size = line[:0], 0
+	}
+}
+
+// Format renders the diagnosis for terminal output
+func (d *Diagnosis) Format() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Scanned %d transcript(s), %d line(s), %d usage entries in %s\n\n",
+		d.Files, d.Lines, d.Usage, strings.Join(d.Roots, ", "))
+	if d.OK() {
+		b.WriteString("OK: every line parsed and every entry type, model and usage field is recognised\n")
+		return b.String()
+	}
+
+	writeIssue(&b, "Unreadable files", d.Problems.UnreadableFiles)
+	writeIssue(&b, "Parse errors (lines skipped)", d.Problems.ParseErrors)
+	writeIssue(&b, fmt.Sprintf("Lines over %d MiB (rest of file skipped)", maxJSONLLineBytes>>20), d.Problems.OversizeLines)
+	writeIssue(&b, "Usage entries missing IDs or timestamps", d.Problems.MissingFields)
+	writeTallies(&b, "Unknown entry types (transcript format may have changed)", d.UnknownTypes)
+	writeTallies(&b, "Unknown models (add them with -models-file and -pricing-file)", d.UnknownModels)
+	writeTallies(&b, "Unrecognised usage fields (may be usage ccu doesn't cost)", d.UnknownUsageFields)
+	return b.String()
+}
+
+func writeIssue(b *strings.Builder, title string, issue Issue) {
+	if issue.Count == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s: %d\n", title, issue.Count)
+	for _, ex := range issue.Examples {
+		fmt.Fprintf(b, "  %s\n", ex)
+	}
+	// Past the examples, list every affected file so none goes unnamed
+	if more := issue.Count - len(issue.Examples); more > 0 {
+		fmt.Fprintf(b, "  ... and %d more. By file:\n", more)
+		for _, file := range slices.Sorted(maps.Keys(issue.Files)) {
+			fmt.Fprintf(b, "  %6d  %s\n", issue.Files[file], file)
+		}
+	}
+	b.WriteString("\n")
+}
+
+func writeTallies(b *strings.Builder, title string, tallies map[string]*Tally) {
+	if len(tallies) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s: %d\n", title, len(tallies))
+	for _, key := range slices.Sorted(maps.Keys(tallies)) {
+		t := tallies[key]
+		fmt.Fprintf(b, "  %-32s %6d line(s), first at %s\n", key, t.Count, t.FirstSeen)
+	}
+	b.WriteString("\n")
+}
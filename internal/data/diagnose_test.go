@@ -0,0 +1,139 @@
+package data
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnose(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	ts := now.Format(time.RFC3339)
+
+	t.Run("clean transcripts", func(t *testing.T) {
+		dir := t.TempDir()
+		writeJSONL(t, dir, "a.jsonl",
+			`{"type":"user","message":{"role":"user","content":"hi"}}`,
+			entryLine(now, "msg_1", "req_1", 10, 5),
+			`{"type":"summary","summary":"chat"}`,
+		)
+
+		d, err := Diagnose([]string{dir})
+		require.NoError(t, err)
+		assert.True(t, d.OK())
+		assert.Equal(t, 1, d.Files)
+		assert.Equal(t, 3, d.Lines)
+		assert.Equal(t, 1, d.Usage)
+		assert.Contains(t, d.Format(), "OK: every line parsed")
+	})
+
+	t.Run("problems and drift", func(t *testing.T) {
+		lowerMaxLineBytes(t, 1024)
+		dir := t.TempDir()
+		path := writeJSONL(t, dir, "a.jsonl",
+			entryLine(now, "msg_1", "req_1", 10, 5),
+			`{"type":"assistant","message":{"id":`,
+			`{"type":"user","content":"`+strings.Repeat("x", 2048)+`"}`,
+			`{"type":"assistant","timestamp":"yesterday","message":{"model":"claude-sonnet-4-20250514","usage":{"input_tokens":1,"output_tokens":1}}}`,
+			`{"type":"assistant","timestamp":"`+ts+`","requestId":"req_2","message":{"id":"msg_2","model":"claude-zenith-9","usage":{"input_tokens":1,"output_tokens":1,"audio_tokens":3,"cache_creation":{"ephemeral_5m_input_tokens":0,"ephemeral_24h_input_tokens":7}}}}`,
+			`{"type":"telemetry","data":{}}`,
+			`{"type":"telemetry","data":{}}`,
+			entryLine(now, "msg_3", "req_3", 10, 5),
+		)
+
+		d, err := Diagnose([]string{dir})
+		require.NoError(t, err)
+		assert.False(t, d.OK())
+		assert.Equal(t, 8, d.Lines, "reading continues past the oversize line")
+		assert.Equal(t, 4, d.Usage)
+
+		assert.Equal(t, 1, d.Problems.ParseErrors.Count)
+		assert.Equal(t, map[string]int{path: 1}, d.Problems.ParseErrors.Files)
+		assert.Contains(t, d.Problems.ParseErrors.Examples[0], path+":2: ")
+		require.Equal(t, 1, d.Problems.OversizeLines.Count)
+		assert.Contains(t, d.Problems.OversizeLines.Examples[0], path+":3 ")
+		require.Equal(t, 1, d.Problems.MissingFields.Count)
+		assert.Equal(t, path+":4: no message id, request id, valid timestamp", d.Problems.MissingFields.Examples[0])
+
+		require.Contains(t, d.UnknownTypes, "telemetry")
+		assert.Equal(t, Tally{Count: 2, FirstSeen: path + ":6"}, *d.UnknownTypes["telemetry"])
+		assert.Equal(t, []string{"claude-zenith-9"}, slices.Sorted(maps.Keys(d.UnknownModels)))
+		assert.Equal(t, []string{"audio_tokens", "cache_creation.ephemeral_24h_input_tokens"}, slices.Sorted(maps.Keys(d.UnknownUsageFields)))
+
+		out := d.Format()
+		for _, want := range []string{"Parse errors", "Lines over 0 MiB", "missing IDs", "Unknown entry types", "claude-zenith-9", "audio_tokens"} {
+			assert.Contains(t, out, want)
+		}
+	})
+
+	t.Run("every affected file is listed past the examples", func(t *testing.T) {
+		dir := t.TempDir()
+		var paths []string
+		for i := range diagnosisExamples + 2 {
+			paths = append(paths, writeJSONL(t, dir, fmt.Sprintf("%d.jsonl", i), `{"type":`, `{"type":`))
+		}
+
+		d, err := Diagnose([]string{dir})
+		require.NoError(t, err)
+		issue := d.Problems.ParseErrors
+		assert.Equal(t, 2*len(paths), issue.Count)
+		assert.Len(t, issue.Examples, diagnosisExamples)
+		require.Len(t, issue.Files, len(paths))
+		out := d.Format()
+		assert.Contains(t, out, "... and 9 more. By file:")
+		for _, path := range paths {
+			assert.Equal(t, 2, issue.Files[path])
+			assert.Contains(t, out, "     2  "+path+"\n")
+		}
+	})
+
+	t.Run("archives and unreadable files", func(t *testing.T) {
+		dir := t.TempDir()
+		archive := tarBytes(t, [2]string{"p/s.jsonl", `{"type":"mystery"}` + "\n"})
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "old.tar.gz"), gzipBytes(t, archive), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.jsonl.gz"), []byte("not gzip"), 0o644))
+
+		d, err := Diagnose([]string{dir})
+		require.NoError(t, err)
+		assert.Equal(t, 1, d.Files)
+		assert.Equal(t, 1, d.Problems.UnreadableFiles.Count)
+		require.Contains(t, d.UnknownTypes, "mystery")
+		assert.Equal(t, filepath.Join(dir, "old.tar.gz")+":p/s.jsonl:1", d.UnknownTypes["mystery"].FirstSeen)
+	})
+}
+
+func TestEachLine(t *testing.T) {
+	type line struct {
+		text string
+		size int
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  []line
+	}{
+		{"terminated", "a\nbb\n", []line{{"a", 1}, {"bb", 2}}},
+		{"unterminated last line", "a\nbb", []line{{"a", 1}, {"bb", 2}}},
+		{"crlf and blank lines", "a\r\n\nb\n", []line{{"a", 2}, {"", 0}, {"b", 1}}},
+		{"oversize line dropped", "a\n" + strings.Repeat("x", 20) + "\nb\n", []line{{"a", 1}, {"", 20}, {"b", 1}}},
+		{"line at the limit kept", strings.Repeat("y", 8) + "\n", []line{{strings.Repeat("y", 8), 8}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []line
+			err := eachLine(strings.NewReader(tt.input), 8, func(_ int, text []byte, size int) {
+				got = append(got, line{string(text), size})
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package data
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/sammcj/ccu/internal/pricing"
+)
+
+// parseCacheFormat is bumped whenever the parser or UsageEntry changes in a
+// way that makes previously stored entries wrong
+const parseCacheFormat = 1
+
+// parseCacheSaveEvery limits how often a long-running session rewrites the
+// cache file; SaveParseCache writes any remaining changes on exit
+const parseCacheSaveEvery = 5 * time.Minute
+
+// parseCache persists per-file parse results across runs, so a restart or a
+// -report invocation only parses files that changed since the last run.
+// Records are stored exactly as the in-memory cache holds them and validated
+// the same way, by mtime and size. Guarded by loadCache.mu.
+type parseCache struct {
+	path     string
+	loaded   bool
+	records  map[string]parseCacheRecord
+	dirty    bool
+	lastSave time.Time
+}
+
+// parseCacheFile is the gob-encoded file format
+type parseCacheFile struct {
+	Version uint64 // parseCacheVersion at write time
+	Files   map[string]parseCacheRecord
+}
+
+// parseCacheRecord is one file's fileCacheEntry plus the root it was read
+// under, which is stamped on its entries
+type parseCacheRecord struct {
+	Root     string
+	MTime    time.Time
+	Size     int64
+	Cutoff   time.Time // entries before this were dropped while parsing; zero = none
+	Entries  []models.UsageEntry
+	Complete int
+	Offset   int64
+	Tail     []byte
+}
+
+// UseParseCache makes LoadUsageData keep its parse results in the file at
+// path between runs. An empty path turns the cache off.
+func UseParseCache(path string) {
+	c := &globalLoadCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.disk = parseCache{path: path}
+}
+
+// ParseCachePath returns the default parse cache file under the user's cache
+// directory
+func ParseCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("finding cache directory: %w", err)
+	}
+	return filepath.Join(dir, "ccu", "parse-cache.gob"), nil
+}
+
+// SaveParseCache writes parse results not yet saved to the cache file
+func SaveParseCache() error {
+	c := &globalLoadCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.disk.dirty {
+		return nil
+	}
+	return c.disk.save()
+}
+
+// parseCacheVersion combines the format with the active pricing and model
+// registry, since parsing stores costs and providers on each entry
+func parseCacheVersion() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, v := range []uint64{parseCacheFormat, pricing.Fingerprint(), models.Fingerprint()} {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// seed returns the stored results usable for a load with this cutoff, for
+// files still under the same root. The file is read on first use; a missing,
+// unreadable or outdated file leaves the cache empty.
+func (d *parseCache) seed(files []jsonlFile, cutoff time.Time) map[string]fileCacheEntry {
+	seeded := make(map[string]fileCacheEntry)
+	if d.path == "" {
+		return seeded
+	}
+	if !d.loaded {
+		d.loaded = true
+		d.records = d.read()
+	}
+
+	for _, f := range files {
+		r, ok := d.records[f.path]
+		if !ok || r.Root != f.root || !covers(r.Cutoff, cutoff) {
+			continue
+		}
+		seeded[f.path] = fileCacheEntry{
+			mtime:    r.MTime,
+			size:     r.Size,
+			cutoff:   r.Cutoff,
+			entries:  r.Entries,
+			complete: r.Complete,
+			mark:     fileMark{offset: r.Offset, tail: r.Tail},
+		}
+	}
+	return seeded
+}
+
+// read loads the cache file, discarding it if it was written by another
+// format or under different pricing or models
+func (d *parseCache) read() map[string]parseCacheRecord {
+	f, err := os.Open(d.path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("data: parse cache unreadable, reparsing: %v", err)
+		}
+		return make(map[string]parseCacheRecord)
+	}
+	defer f.Close()
+
+	var file parseCacheFile
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&file); err != nil {
+		log.Printf("data: parse cache %s is corrupt, reparsing: %v", d.path, err)
+		return make(map[string]parseCacheRecord)
+	}
+	if file.Version != parseCacheVersion() || file.Files == nil {
+		return make(map[string]parseCacheRecord)
+	}
+	return file.Files
+}
+
+// update records a load's results. files is every file listed, in or out of
+// the window; next holds the results for those in it. A stored record parsed
+// with an earlier cutoff is kept over a newer one for the same file contents,
+// so a short dashboard window doesn't undo a long report's parse. Records for
+// files that no longer exist or have changed since are dropped.
+func (d *parseCache) update(files []jsonlFile, next map[string]fileCacheEntry) {
+	if d.path == "" {
+		return
+	}
+	records := make(map[string]parseCacheRecord, len(files))
+	for _, f := range files {
+		old, hasOld := d.records[f.path]
+		hasOld = hasOld && old.Root == f.root && old.MTime.Equal(f.mtime) && old.Size == f.size
+
+		ce, ok := next[f.path]
+		switch {
+		case ok && !(hasOld && covers(old.Cutoff, ce.cutoff)):
+			records[f.path] = parseCacheRecord{
+				Root:     f.root,
+				MTime:    ce.mtime,
+				Size:     ce.size,
+				Cutoff:   ce.cutoff,
+				Entries:  ce.entries,
+				Complete: ce.complete,
+				Offset:   ce.mark.offset,
+				Tail:     ce.mark.tail,
+			}
+			d.dirty = true
+		case hasOld:
+			records[f.path] = old
+		}
+	}
+	if len(records) != len(d.records) {
+		d.dirty = true
+	}
+	d.records = records
+	d.loaded = true
+
+	if d.dirty && time.Since(d.lastSave) >= parseCacheSaveEvery {
+		if err := d.save(); err != nil {
+			log.Printf("data: %v", err)
+		}
+	}
+}
+
+// save writes the records to a temporary file and renames it over the cache,
+// so a crash mid-write leaves the previous cache intact
+func (d *parseCache) save() error {
+	d.lastSave = time.Now()
+	if err := os.MkdirAll(filepath.Dir(d.path), 0o755); err != nil {
+		return fmt.Errorf("saving parse cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(d.path), ".parse-cache-*")
+	if err != nil {
+		return fmt.Errorf("saving parse cache: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	w := bufio.NewWriter(tmp)
+	err = gob.NewEncoder(w).Encode(parseCacheFile{Version: parseCacheVersion(), Files: d.records})
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), d.path)
+	}
+	if err != nil {
+		return fmt.Errorf("saving parse cache: %w", err)
+	}
+	d.dirty = false
+	return nil
+}
+
+// covers reports whether entries parsed with cutoff have everything a load
+// with want needs: nothing was dropped that want would keep
+func covers(cutoff, want time.Time) bool {
+	return cutoff.IsZero() || (!want.IsZero() && !want.Before(cutoff))
+}
@@ -0,0 +1,193 @@
+package data
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useTestParseCache points the parse cache at a temp file, turning it off
+// again when the test ends
+func useTestParseCache(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ccu", "parse-cache.gob")
+	resetLoadCache()
+	UseParseCache(path)
+	t.Cleanup(func() {
+		resetLoadCache()
+		UseParseCache("")
+	})
+	return path
+}
+
+// restart drops everything held in memory, as a new ccu process would start
+func restart(path string) {
+	resetLoadCache()
+	UseParseCache(path)
+}
+
+// rewriteInPlace replaces old with new (same length) in a file and restores
+// its mtime, so only its contents say it changed
+func rewriteInPlace(t *testing.T, path, old, new string) {
+	t.Helper()
+	require.Len(t, new, len(old))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte(strings.ReplaceAll(string(content), old, new)), 0o644))
+	require.NoError(t, os.Chtimes(path, info.ModTime(), info.ModTime()))
+}
+
+func TestParseCache(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	t.Run("restart reuses stored results", func(t *testing.T) {
+		cachePath := useTestParseCache(t)
+		dir := t.TempDir()
+		path := writeJSONL(t, dir, "a.jsonl", entryLine(now.Add(-10*time.Minute), "msg_1", "req_1", 10, 5))
+
+		entries, err := LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.FileExists(t, cachePath, "the first load saves straight away")
+
+		// Same mtime and size, so only the cache can still say msg_1
+		rewriteInPlace(t, path, "msg_1", "msg_2")
+		restart(cachePath)
+		entries, err = LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "msg_1", entries[0].MessageID)
+		assert.Equal(t, dir, entries[0].Source)
+
+		restart("")
+		entries, err = LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+		assert.Equal(t, "msg_2", entries[0].MessageID)
+	})
+
+	t.Run("file grown since last run resumes", func(t *testing.T) {
+		cachePath := useTestParseCache(t)
+		dir := t.TempDir()
+		path := writeJSONL(t, dir, "a.jsonl", entryLine(now.Add(-10*time.Minute), "msg_1", "req_1", 10, 5))
+		_, err := LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+
+		appendLine(t, path, entryLine(now.Add(-5*time.Minute), "msg_2", "req_2", 20, 10))
+		restart(cachePath)
+		entries, err := LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "msg_2", entries[1].MessageID)
+
+		// The resumed result is what the next run starts from
+		require.NoError(t, SaveParseCache())
+		restart(cachePath)
+		entries, err = LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+
+	t.Run("wider window reparses, narrower reuses", func(t *testing.T) {
+		cachePath := useTestParseCache(t)
+		dir := t.TempDir()
+		path := writeJSONL(t, dir, "a.jsonl",
+			entryLine(now.Add(-30*time.Hour), "msg_old", "req_old", 10, 5),
+			entryLine(now.Add(-10*time.Minute), "msg_new", "req_new", 20, 10),
+		)
+		entries, err := LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		restart(cachePath)
+		entries, err = LoadUsageData([]string{dir}, 48)
+		require.NoError(t, err)
+		require.Len(t, entries, 2, "entries dropped by the 24h parse must be read again")
+
+		// The 48h parse replaced the stored 24h one and covers a 24h load
+		rewriteInPlace(t, path, "msg_new", "msg_NEW")
+		restart(cachePath)
+		entries, err = LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "msg_new", entries[0].MessageID)
+	})
+
+	t.Run("outdated or corrupt cache is ignored", func(t *testing.T) {
+		for name, write := range map[string]func(*os.File) error{
+			"other version": func(f *os.File) error {
+				return gob.NewEncoder(f).Encode(parseCacheFile{Version: parseCacheVersion() + 1, Files: map[string]parseCacheRecord{}})
+			},
+			"corrupt": func(f *os.File) error {
+				_, err := f.WriteString("not a gob")
+				return err
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				cachePath := useTestParseCache(t)
+				dir := t.TempDir()
+				path := writeJSONL(t, dir, "a.jsonl", entryLine(now.Add(-10*time.Minute), "msg_1", "req_1", 10, 5))
+				_, err := LoadUsageData([]string{dir}, 24)
+				require.NoError(t, err)
+				rewriteInPlace(t, path, "msg_1", "msg_2")
+
+				f, err := os.Create(cachePath)
+				require.NoError(t, err)
+				require.NoError(t, write(f))
+				require.NoError(t, f.Close())
+
+				restart(cachePath)
+				entries, err := LoadUsageData([]string{dir}, 24)
+				require.NoError(t, err)
+				require.Len(t, entries, 1)
+				assert.Equal(t, "msg_2", entries[0].MessageID)
+			})
+		}
+	})
+
+	t.Run("deleted files are dropped", func(t *testing.T) {
+		useTestParseCache(t)
+		dir := t.TempDir()
+		writeJSONL(t, dir, "a.jsonl", entryLine(now.Add(-10*time.Minute), "msg_a", "req_a", 10, 5))
+		pathB := writeJSONL(t, dir, "b.jsonl", entryLine(now.Add(-5*time.Minute), "msg_b", "req_b", 10, 5))
+		_, err := LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+
+		require.NoError(t, os.Remove(pathB))
+		_, err = LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+
+		globalLoadCache.mu.Lock()
+		defer globalLoadCache.mu.Unlock()
+		assert.Len(t, globalLoadCache.disk.records, 1)
+		assert.True(t, globalLoadCache.disk.dirty, "the removal is saved later or on exit")
+	})
+}
+
+func TestCovers(t *testing.T) {
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		cutoff, want time.Time
+		expected     bool
+	}{
+		{"unfiltered covers all", time.Time{}, time.Time{}, true},
+		{"unfiltered covers any window", time.Time{}, day, true},
+		{"filtered can't cover everything", day, time.Time{}, false},
+		{"same cutoff", day, day, true},
+		{"later window", day, day.Add(time.Hour), true},
+		{"earlier window", day, day.Add(-time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, covers(tt.cutoff, tt.want))
+		})
+	}
+}
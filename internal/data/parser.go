@@ -29,6 +29,14 @@ type RawEntry struct {
 			OutputTokens             int `json:"output_tokens"`
 			CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
 			CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+			CacheCreation            struct {
+				Ephemeral5mInputTokens int `json:"ephemeral_5m_input_tokens"`
+				Ephemeral1hInputTokens int `json:"ephemeral_1h_input_tokens"`
+			} `json:"cache_creation"`
+			ServerToolUse struct {
+				WebSearchRequests int `json:"web_search_requests"`
+				WebFetchRequests  int `json:"web_fetch_requests"`
+			} `json:"server_tool_use"`
 		} `json:"usage"`
 	} `json:"message"`
 }
@@ -58,6 +66,11 @@ func ParseJSONLLine(line []byte) (*models.UsageEntry, error) {
 		return nil, nil // Expected skip, not an error
 	}
 
+	// Messages Claude Code generated locally never reached an API
+	if raw.Message.Model == models.SyntheticModel {
+		return nil, nil
+	}
+
 	// Parse timestamp
 	timestamp, err := parseTimestamp(raw.Timestamp)
 	if err != nil {
@@ -65,17 +78,24 @@ func ParseJSONLLine(line []byte) (*models.UsageEntry, error) {
 	}
 
 	entry := &models.UsageEntry{
-		Timestamp:           timestamp,
-		InputTokens:         raw.Message.Usage.InputTokens,
-		OutputTokens:        raw.Message.Usage.OutputTokens,
-		CacheCreationTokens: raw.Message.Usage.CacheCreationInputTokens,
-		CacheReadTokens:     raw.Message.Usage.CacheReadInputTokens,
-		Model:               raw.Message.Model,
-		MessageID:           raw.Message.ID,
-		RequestID:           raw.RequestID,
+		Timestamp:             timestamp,
+		InputTokens:           raw.Message.Usage.InputTokens,
+		OutputTokens:          raw.Message.Usage.OutputTokens,
+		CacheCreationTokens:   raw.Message.Usage.CacheCreationInputTokens,
+		CacheCreation5mTokens: raw.Message.Usage.CacheCreation.Ephemeral5mInputTokens,
+		CacheCreation1hTokens: raw.Message.Usage.CacheCreation.Ephemeral1hInputTokens,
+		CacheReadTokens:       raw.Message.Usage.CacheReadInputTokens,
+		WebSearchRequests:     raw.Message.Usage.ServerToolUse.WebSearchRequests,
+		WebFetchRequests:      raw.Message.Usage.ServerToolUse.WebFetchRequests,
+		Model:                 raw.Message.Model,
+		Provider:              models.ProviderOf(raw.Message.Model).ID,
+		MessageID:             raw.Message.ID,
+		RequestID:             raw.RequestID,
 	}
 
 	// Calculate cost
+	entry.LongContext = pricing.IsLongContext(*entry)
+	entry.Unpriced = !pricing.IsPriced(*entry)
 	entry.CostUSD = pricing.CalculateCost(*entry)
 
 	return entry, nil
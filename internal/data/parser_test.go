@@ -1,9 +1,11 @@
 package data
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/sammcj/ccu/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -100,6 +102,86 @@ func TestParseJSONLLineValidEntry(t *testing.T) {
 	assert.Equal(t, 180, entry.TotalTokens())
 }
 
+func TestParseJSONLLineCacheCreationBreakdown(t *testing.T) {
+	line := `{"type":"assistant","timestamp":"2026-07-03T10:00:00Z","requestId":"req_1","message":{"id":"msg_1","model":"claude-sonnet-4-6","usage":{"input_tokens":10,"output_tokens":5,"cache_creation_input_tokens":1000,"cache_creation":{"ephemeral_5m_input_tokens":250,"ephemeral_1h_input_tokens":750}}}}`
+
+	entry, err := ParseJSONLLine([]byte(line))
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	assert.Equal(t, 1000, entry.CacheCreationTokens)
+	assert.Equal(t, 250, entry.CacheCreation5mTokens)
+	assert.Equal(t, 750, entry.CacheCreation1hTokens)
+	// (10 * 3 + 5 * 15 + 250 * 3.75 + 750 * 6) / 1M
+	assert.InDelta(t, 0.0055425, entry.CostUSD, 1e-9)
+}
+
+func TestParseJSONLLineServerToolUse(t *testing.T) {
+	line := `{"type":"assistant","timestamp":"2026-07-03T10:00:00Z","requestId":"req_1","message":{"id":"msg_1","model":"claude-sonnet-4-6","usage":{"input_tokens":1000,"output_tokens":500,"server_tool_use":{"web_search_requests":2,"web_fetch_requests":1}}}}`
+
+	entry, err := ParseJSONLLine([]byte(line))
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	assert.Equal(t, 2, entry.WebSearchRequests)
+	assert.Equal(t, 1, entry.WebFetchRequests)
+	// $0.0105 of tokens plus two $0.01 web searches
+	assert.InDelta(t, 0.0305, entry.CostUSD, 1e-9)
+}
+
+func TestParseJSONLLineLongContext(t *testing.T) {
+	line := `{"type":"assistant","timestamp":"2026-07-03T10:00:00Z","requestId":"req_1","message":{"id":"msg_1","model":"claude-sonnet-4-6","usage":{"input_tokens":10,"output_tokens":100,"cache_read_input_tokens":210000}}}`
+
+	entry, err := ParseJSONLLine([]byte(line))
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	assert.True(t, entry.LongContext)
+	// (10 * 6 + 100 * 22.50 + 210000 * 0.60) / 1M
+	assert.InDelta(t, 0.12831, entry.CostUSD, 1e-9)
+}
+
+func TestParseJSONLLineUnpricedModel(t *testing.T) {
+	line := `{"type":"assistant","timestamp":"2026-07-03T10:00:00Z","requestId":"req_1","message":{"id":"msg_1","model":"claude-zenith-1","usage":{"input_tokens":100000,"output_tokens":0}}}`
+
+	entry, err := ParseJSONLLine([]byte(line))
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	// Unknown models are flagged and still costed at Sonnet rates
+	assert.True(t, entry.Unpriced)
+	assert.InDelta(t, 0.30, entry.CostUSD, 1e-9)
+
+	line = strings.Replace(line, "claude-zenith-1", "claude-opus-4-8", 1)
+	entry, err = ParseJSONLLine([]byte(line))
+	require.NoError(t, err)
+	assert.False(t, entry.Unpriced)
+}
+
+func TestParseJSONLLineProviders(t *testing.T) {
+	const line = `{"type":"assistant","timestamp":"2026-07-03T10:00:00Z","requestId":"req_1","message":{"id":"msg_1","model":"MODEL","usage":{"input_tokens":100000,"output_tokens":10}}}`
+	parse := func(model string) *models.UsageEntry {
+		t.Helper()
+		entry, err := ParseJSONLLine([]byte(strings.Replace(line, "MODEL", model, 1)))
+		require.NoError(t, err)
+		return entry
+	}
+
+	// Locally generated messages are skipped
+	assert.Nil(t, parse("<synthetic>"))
+
+	entry := parse("claude-sonnet-4-6")
+	require.NotNil(t, entry)
+	assert.Equal(t, models.ProviderAnthropic, entry.Provider)
+
+	// Other providers' models without a price cost nothing rather than Sonnet rates
+	entry = parse("gpt-4o")
+	require.NotNil(t, entry)
+	assert.Equal(t, "openai", entry.Provider)
+	assert.True(t, entry.Unpriced)
+	assert.Zero(t, entry.CostUSD)
+}
+
 func TestParseJSONLLineTimestampFormats(t *testing.T) {
 	tests := []struct {
 		name string
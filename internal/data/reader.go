@@ -2,13 +2,16 @@ package data
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"strings"
 	"sync"
@@ -21,24 +24,43 @@ import (
 // fingerprint and cache-validation stages don't re-stat every file per tick.
 type jsonlFile struct {
 	path  string
+	root  string // data root the file was found under
 	mtime time.Time
 	size  int64
 }
 
 // fileCacheEntry is one file's parsed output. Entries are stored pre-dedupe so
 // the merge step can apply first-seen-wins deduplication across files in a
-// stable order regardless of which files were re-parsed this tick.
+// stable order regardless of which files were re-parsed this tick. complete
+// and mark let a file that has only grown be resumed rather than reparsed.
 type fileCacheEntry struct {
-	mtime   time.Time
-	size    int64
-	entries []models.UsageEntry
+	mtime    time.Time
+	size     int64
+	cutoff   time.Time // entries before this were dropped while parsing; zero = none
+	entries  []models.UsageEntry
+	complete int // leading entries from newline-terminated lines; the rest came from an unterminated last line
+	mark     fileMark
 }
 
+// fileMark records how far a file has been parsed: the end of its last
+// newline-terminated line, and the bytes just before that offset. A file is
+// resumed from offset only if those bytes are unchanged, so a truncated,
+// rotated or rewritten file is reparsed from the start.
+type fileMark struct {
+	offset int64
+	tail   []byte
+}
+
+// markTailBytes is how many bytes before a mark's offset are compared when
+// resuming
+const markTailBytes = 64
+
 // loadCache memoises LoadUsageData across refresh ticks, per file. Unchanged
 // files (same mtime and size) reuse their previously parsed entries, so a
 // growing live JSONL file only costs its own reparse rather than the whole
 // window. When nothing changed at all, the previous merged slice is returned
 // unchanged - internal/app relies on slice identity to skip recomputation.
+// With a parse cache file in use, per-file results also survive restarts.
 type loadCache struct {
 	mu          sync.Mutex
 	hoursBack   int
@@ -46,12 +68,19 @@ type loadCache struct {
 	valid       bool
 	files       map[string]fileCacheEntry
 	merged      []models.UsageEntry
+	disk        parseCache
 }
 
 var globalLoadCache loadCache
 
-// findJSONLFiles recursively finds all .jsonl files under rootPath, capturing
+// parseWorkers bounds how many files LoadUsageData parses at once. A var
+// rather than a const so tests and benchmarks can compare against one worker.
+var parseWorkers = runtime.GOMAXPROCS(0)
+
+// findJSONLFiles recursively finds all JSONL transcripts under rootPath,
+// including compressed ones and tar archives of them (see packingOf), capturing
 // mtime and size from the directory entry so each file is only stat'd once.
+// A missing root yields no files rather than an error.
 func findJSONLFiles(rootPath string) ([]jsonlFile, error) {
 	var files []jsonlFile
 
@@ -61,8 +90,8 @@ func findJSONLFiles(rootPath string) ([]jsonlFile, error) {
 			return nil
 		}
 
-		// Include all .jsonl files (matches Python implementation)
-		if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".jsonl") {
+		// Include all transcripts, not just session files (matches Python implementation)
+		if d.IsDir() || !isJSONL(d.Name()) {
 			return nil
 		}
 
@@ -72,7 +101,7 @@ func findJSONLFiles(rootPath string) ([]jsonlFile, error) {
 			return nil
 		}
 
-		files = append(files, jsonlFile{path: path, mtime: info.ModTime(), size: info.Size()})
+		files = append(files, jsonlFile{path: path, root: rootPath, mtime: info.ModTime(), size: info.Size()})
 		return nil
 	})
 
@@ -103,27 +132,88 @@ type readStats struct {
 	lastErr      error
 }
 
+// add folds another set of counters into s; o's error wins if it has one
+func (s *readStats) add(o readStats) {
+	s.skippedFiles += o.skippedFiles
+	s.parseErrors += o.parseErrors
+	if o.lastErr != nil {
+		s.lastErr = o.lastErr
+	}
+}
+
 // readJSONLFileWithFilter reads a JSONL file with time filtering and deduplication.
 // cutoff: entries before this time are skipped (zero value = no filter).
 // seen: map for deduplication (nil = no deduplication).
 // scanBuf: scanner working buffer shared across files; can grow up to maxJSONLLineBytes.
 // stats: aggregates non-fatal counters (parse errors, scan errors).
 func readJSONLFileWithFilter(filePath string, cutoff time.Time, seen map[string]bool, scanBuf []byte, stats *readStats) ([]models.UsageEntry, error) {
+	r, err := readJSONLFrom(filePath, fileMark{}, cutoff, seen, scanBuf, stats)
+	return r.entries, err
+}
+
+// tailRead is the result of readJSONLFrom
+type tailRead struct {
+	entries  []models.UsageEntry
+	complete int      // leading entries from newline-terminated lines
+	mark     fileMark // where the next read can resume
+	resumed  bool     // read started at the given mark; entries are only the appended ones
+}
+
+// readJSONLFrom reads a JSONL file from the given mark, or from the start if
+// the mark is zero or no longer matches the file (see fileMark). Only
+// newline-terminated lines advance the returned mark: an unterminated last
+// line, which Claude Code may still be writing, is parsed but re-read next
+// time. Other parameters are as for readJSONLFileWithFilter. On a scan error
+// the entries and mark up to the failure are returned with the error.
+func readJSONLFrom(filePath string, from fileMark, cutoff time.Time, seen map[string]bool, scanBuf []byte, stats *readStats) (tailRead, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("opening file: %w", err)
+		return tailRead{}, fmt.Errorf("opening file: %w", err)
 	}
 	defer file.Close()
 
-	var entries []models.UsageEntry
-	scanner := bufio.NewScanner(file)
+	start := int64(0)
+	if from.offset > 0 && markIntact(file, from) {
+		if _, err := file.Seek(from.offset, io.SeekStart); err != nil {
+			return tailRead{}, fmt.Errorf("seeking file: %w", err)
+		}
+		start = from.offset
+	}
+
+	result, err := scanJSONL(file, filePath, start, cutoff, seen, scanBuf, stats)
+	result.mark.tail = readMarkTail(file, result.mark.offset)
+	return result, err
+}
+
+// scanJSONL parses the lines of r, which starts at byte offset start of the
+// named file, returning the entries and the offset just past the last
+// newline-terminated line. See readJSONLFrom.
+func scanJSONL(r io.Reader, name string, start int64, cutoff time.Time, seen map[string]bool, scanBuf []byte, stats *readStats) (tailRead, error) {
+	result := tailRead{mark: fileMark{offset: start}, resumed: start > 0}
+
+	// Track whether each token ended in a newline and where it ended, so the
+	// mark only ever advances past complete lines
+	pos := start
+	terminated := false
+	scanner := bufio.NewScanner(r)
 	scanner.Buffer(scanBuf, maxJSONLLineBytes)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if advance > 0 {
+			pos += int64(advance)
+			terminated = data[advance-1] == '\n'
+		}
+		return advance, token, err
+	})
 
 	hasCutoff := !cutoff.IsZero()
 	lineNum := 0
 	for scanner.Scan() {
 		lineNum++
 		line := scanner.Bytes()
+		if terminated {
+			result.mark.offset = pos
+		}
 
 		// Skip empty lines
 		if len(line) == 0 {
@@ -132,9 +222,15 @@ func readJSONLFileWithFilter(filePath string, cutoff time.Time, seen map[string]
 
 		entry, err := ParseJSONLLine(line)
 		if err != nil {
-			if stats != nil {
+			// An unterminated last line is usually still being written; it
+			// is re-read once complete, so it isn't counted as an error yet
+			if stats != nil && terminated {
 				stats.parseErrors++
-				stats.lastErr = fmt.Errorf("%s:%d: %w", filePath, lineNum, err)
+				if start > 0 {
+					stats.lastErr = fmt.Errorf("%s: line %d after byte %d: %w", name, lineNum, start, err)
+				} else {
+					stats.lastErr = fmt.Errorf("%s:%d: %w", name, lineNum, err)
+				}
 			}
 			continue
 		}
@@ -158,60 +254,126 @@ func readJSONLFileWithFilter(filePath string, cutoff time.Time, seen map[string]
 			seen[hash] = true
 		}
 
-		entries = append(entries, *entry)
+		result.entries = append(result.entries, *entry)
+		if terminated {
+			result.complete = len(result.entries)
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
 		// Scan failure (e.g. a single line beyond maxJSONLLineBytes) bubbles up
 		// as a file-level error, but the entries parsed before the failure are
 		// returned so callers keep the file's valid data.
-		return entries, fmt.Errorf("scanning file %s: %w", filePath, err)
+		return result, fmt.Errorf("scanning file %s: %w", name, err)
 	}
 
-	return entries, nil
+	return result, nil
 }
 
-// GetDefaultDataPath returns the default Claude data path
-func GetDefaultDataPath() (string, error) {
+// markIntact reports whether the file still holds the bytes recorded before
+// the mark's offset
+func markIntact(file *os.File, mark fileMark) bool {
+	return bytes.Equal(readMarkTail(file, mark.offset), mark.tail)
+}
+
+// readMarkTail returns up to markTailBytes ending at offset, or nil if they
+// can't be read in full (e.g. the file was truncated)
+func readMarkTail(file *os.File, offset int64) []byte {
+	n := min(offset, markTailBytes)
+	if n == 0 {
+		return nil
+	}
+	tail := make([]byte, n)
+	if _, err := file.ReadAt(tail, offset-n); err != nil {
+		return nil
+	}
+	return tail
+}
+
+// CandidateDataPaths returns the standard locations Claude Code writes
+// transcripts to, in priority order: the projects directory of each
+// CLAUDE_CONFIG_DIR entry (comma-separated), then the XDG config directory
+// newer installs use, then the legacy ~/.claude. Paths may not exist.
+func CandidateDataPaths() ([]string, error) {
+	var paths []string
+	for dir := range strings.SplitSeq(os.Getenv("CLAUDE_CONFIG_DIR"), ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			paths = append(paths, filepath.Join(dir, "projects"))
+		}
+	}
+
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return "", fmt.Errorf("getting home directory: %w", err)
+		return nil, fmt.Errorf("getting home directory: %w", err)
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(homeDir, ".config")
 	}
+	paths = append(paths,
+		filepath.Join(configHome, "claude", "projects"),
+		filepath.Join(homeDir, ".claude", "projects"),
+	)
+	return uniquePaths(paths), nil
+}
 
-	claudePath := filepath.Join(homeDir, ".claude", "projects")
+// GetDefaultDataPaths returns the standard Claude data paths that exist
+func GetDefaultDataPaths() ([]string, error) {
+	candidates, err := CandidateDataPaths()
+	if err != nil {
+		return nil, err
+	}
 
-	// Check if path exists
-	if _, err := os.Stat(claudePath); os.IsNotExist(err) {
-		return "", fmt.Errorf("claude data directory not found: %s", claudePath)
+	var found []string
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			found = append(found, path)
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("claude data directory not found (looked in %s)", strings.Join(candidates, ", "))
 	}
+	return found, nil
+}
 
-	return claudePath, nil
+// uniquePaths drops repeated paths, keeping the first occurrence so the
+// caller's priority order is preserved
+func uniquePaths(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	var out []string
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out
 }
 
-// LoadUsageData loads all usage data within the hoursBack window, reusing
-// per-file parse results from the previous call where files are unchanged.
-func LoadUsageData(dataPath string, hoursBack int) ([]models.UsageEntry, error) {
-	// Use default path if not specified
-	if dataPath == "" {
+// LoadUsageData loads all usage data within the hoursBack window from every
+// data root, reusing per-file parse results from the previous call where files
+// are unchanged. Each entry records the root it was read from. The same
+// message logged under several roots is counted once, from the first root
+// listed. With no roots, the standard locations are discovered.
+func LoadUsageData(dataPaths []string, hoursBack int) ([]models.UsageEntry, error) {
+	if len(dataPaths) == 0 {
 		var err error
-		dataPath, err = GetDefaultDataPath()
+		dataPaths, err = GetDefaultDataPaths()
 		if err != nil {
 			return nil, err
 		}
 	}
+	dataPaths = uniquePaths(dataPaths)
 
-	files, err := findJSONLFiles(dataPath)
+	files, err := listJSONLFiles(dataPaths)
 	if err != nil {
-		return nil, fmt.Errorf("finding JSONL files: %w", err)
+		return nil, err
 	}
-
 	if len(files) == 0 {
-		return nil, fmt.Errorf("no JSONL files found in %s", dataPath)
+		return nil, fmt.Errorf("no JSONL files found in %s", strings.Join(dataPaths, ", "))
 	}
 
-	// Deterministic order so first-seen-wins dedupe is stable across ticks.
-	slices.SortFunc(files, func(a, b jsonlFile) int { return strings.Compare(a.path, b.path) })
-
 	var cutoff time.Time
 	if hoursBack > 0 {
 		cutoff = time.Now().Add(-time.Duration(hoursBack) * time.Hour)
@@ -231,43 +393,53 @@ func LoadUsageData(dataPath string, hoursBack int) ([]models.UsageEntry, error)
 
 	// An hoursBack change can move the cutoff backwards, and per-file entries
 	// were parse-time filtered against the old cutoff, so none can be reused.
+	// Start again from the parse cache file, whose records carry their cutoff.
 	if c.files == nil || c.hoursBack != hoursBack {
-		c.files = make(map[string]fileCacheEntry)
+		c.files = c.disk.seed(files, cutoff)
 	}
 
-	// Reuse one scanner buffer across every file rather than allocating a fresh
-	// 10 MiB per file; bufio.Scanner grows it up to maxJSONLLineBytes on demand.
-	scanBuf := make([]byte, 64*1024)
-	stats := &readStats{}
-	next := make(map[string]fileCacheEntry, len(files))
-	seen := make(map[string]bool)
-	var merged []models.UsageEntry
-	retryNeeded := false
-
+	// Files older than the window contribute nothing; leaving them out of next
+	// also evicts deleted and aged-out files from the cache. New and changed
+	// files are parsed up front, concurrently; the merge below then walks the
+	// files in list order so dedupe doesn't depend on which parse finished
+	// first.
+	var live []jsonlFile
+	var jobs []*parseJob
 	for _, f := range files {
-		// Files older than the window contribute nothing; leaving them out of
-		// next also evicts deleted and aged-out files from the cache.
 		if !cutoff.IsZero() && f.mtime.Before(cutoff) {
 			continue
 		}
-
+		live = append(live, f)
 		ce, ok := c.files[f.path]
 		if !ok || !ce.mtime.Equal(f.mtime) || ce.size != f.size {
-			// New or changed file: reparse without dedupe (dedupe happens at
-			// merge). A scan failure keeps the entries parsed before it.
-			entries, err := readJSONLFileWithFilter(f.path, cutoff, nil, scanBuf, stats)
-			if err != nil {
-				stats.skippedFiles++
-				stats.lastErr = err
-				if len(entries) == 0 {
-					// Nothing usable (e.g. a transient open failure): leave the
-					// file uncached and invalidate the fast path so the next
-					// load retries it rather than treating it as empty forever.
-					retryNeeded = true
-					continue
-				}
+			jobs = append(jobs, &parseJob{file: f, prev: ce})
+		}
+	}
+	parseFiles(jobs, cutoff)
+
+	stats := &readStats{}
+	parsed := make(map[string]*parseJob, len(jobs))
+	for _, j := range jobs {
+		stats.add(j.stats)
+		parsed[j.file.path] = j
+	}
+
+	next := make(map[string]fileCacheEntry, len(live))
+	seen := make(map[string]bool)
+	var merged []models.UsageEntry
+	retryNeeded := false
+
+	for _, f := range live {
+		ce := c.files[f.path]
+		if j, ok := parsed[f.path]; ok {
+			if !j.ok {
+				// Nothing usable (e.g. a transient open failure): leave the
+				// file uncached and invalidate the fast path so the next load
+				// retries it rather than treating it as empty forever.
+				retryNeeded = true
+				continue
 			}
-			ce = fileCacheEntry{mtime: f.mtime, size: f.size, entries: entries}
+			ce = j.result
 		}
 		next[f.path] = ce
 
@@ -298,6 +470,7 @@ func LoadUsageData(dataPath string, hoursBack int) ([]models.UsageEntry, error)
 		return a.Timestamp.Compare(b.Timestamp)
 	})
 
+	c.disk.update(files, next)
 	c.files = next
 	c.hoursBack = hoursBack
 	c.fingerprint = fingerprint
@@ -307,6 +480,114 @@ func LoadUsageData(dataPath string, hoursBack int) ([]models.UsageEntry, error)
 	return merged, nil
 }
 
+// parseJob is one new or changed file for parseFiles, and its result
+type parseJob struct {
+	file   jsonlFile
+	prev   fileCacheEntry // cached result from the last load, if any
+	result fileCacheEntry
+	ok     bool // result is usable; false if the file yielded nothing and errored
+	stats  readStats
+}
+
+// parseFiles parses the jobs on up to parseWorkers goroutines. Each worker has
+// its own scanner buffer, grown up to maxJSONLLineBytes on demand, and each
+// job its own stats, so workers share nothing but the job queue.
+func parseFiles(jobs []*parseJob, cutoff time.Time) {
+	workers := min(parseWorkers, len(jobs))
+	if workers <= 1 {
+		scanBuf := make([]byte, 64*1024)
+		for _, j := range jobs {
+			j.parse(cutoff, scanBuf)
+		}
+		return
+	}
+
+	queue := make(chan *parseJob)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			scanBuf := make([]byte, 64*1024)
+			for j := range queue {
+				j.parse(cutoff, scanBuf)
+			}
+		})
+	}
+	for _, j := range jobs {
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// parse reads the job's file without dedupe (dedupe happens at merge). A file
+// that has only grown resumes from its mark, so a live transcript costs just
+// its appended lines; compressed files and archives are read whole. A scan
+// failure keeps the entries parsed before it.
+func (j *parseJob) parse(cutoff time.Time, scanBuf []byte) {
+	f := j.file
+	var r tailRead
+	var err error
+	if isPacked(f.path) {
+		r, err = readPacked(f.path, cutoff, nil, scanBuf, &j.stats)
+	} else {
+		r, err = readJSONLFrom(f.path, j.prev.mark, cutoff, nil, scanBuf, &j.stats)
+	}
+	for i := range r.entries {
+		r.entries[i].Source = f.root
+	}
+	entries, complete := r.entries, r.complete
+	if r.resumed {
+		kept := keepSince(j.prev.entries[:j.prev.complete], cutoff)
+		entries = append(kept, r.entries...)
+		complete = len(kept) + r.complete
+	}
+	if err != nil {
+		j.stats.skippedFiles++
+		j.stats.lastErr = err
+		if len(entries) == 0 {
+			return
+		}
+	}
+	j.result = fileCacheEntry{mtime: f.mtime, size: f.size, cutoff: cutoff, entries: entries, complete: complete, mark: r.mark}
+	j.ok = true
+}
+
+// keepSince returns a new slice of the entries at or after cutoff, so a
+// resumed file drops entries that have aged out of the window
+func keepSince(entries []models.UsageEntry, cutoff time.Time) []models.UsageEntry {
+	return slices.DeleteFunc(slices.Clone(entries), func(e models.UsageEntry) bool {
+		return !cutoff.IsZero() && e.Timestamp.Before(cutoff)
+	})
+}
+
+// listJSONLFiles returns the JSONL files under the roots in deterministic
+// order, so first-seen-wins dedupe is stable across ticks: roots in priority
+// order, then paths within each root. A running Watcher for the same roots
+// supplies the list without walking the tree.
+func listJSONLFiles(roots []string) ([]jsonlFile, error) {
+	if w := watcherFor(roots); w != nil {
+		return w.snapshot(roots...), nil
+	}
+
+	var files []jsonlFile
+	walked := make(map[string]bool)
+	for _, root := range roots {
+		rootFiles, err := findJSONLFiles(root)
+		if err != nil {
+			return nil, fmt.Errorf("finding JSONL files: %w", err)
+		}
+		slices.SortFunc(rootFiles, func(a, b jsonlFile) int { return strings.Compare(a.path, b.path) })
+		for _, f := range rootFiles {
+			// Overlapping roots (one nested in another) list a file twice
+			if !walked[f.path] {
+				walked[f.path] = true
+				files = append(files, f)
+			}
+		}
+	}
+	return files, nil
+}
+
 // loadFingerprint produces a signature of the JSONL files that would be merged
 // for this window: path, mtime and size of every file inside the cutoff. Files
 // whose mtime is already before the cutoff are excluded because the load skips
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLargeTranscript writes a transcript of n assistant lines, each padded
+// with a tool payload like the ones that make live transcripts large
+func writeLargeTranscript(b *testing.B, dir string, n int) string {
+	b.Helper()
+	now := time.Now()
+	payload := strings.Repeat("x", 4096)
+	var sb strings.Builder
+	for i := range n {
+		line := entryLine(now.Add(-time.Duration(n-i)*time.Second), fmt.Sprintf("msg_%d", i), fmt.Sprintf("req_%d", i), 100, 50)
+		// Splice the payload into the message object so the line stays valid JSON
+		sb.WriteString(strings.Replace(line, `"message":{`, `"message":{"content":"`+payload+`",`, 1))
+		sb.WriteByte('\n')
+	}
+	path := filepath.Join(dir, "live.jsonl")
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// appendEntry appends one new assistant line, as Claude Code does per response
+func appendEntry(b *testing.B, path string, i int) {
+	b.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(entryLine(time.Now(), fmt.Sprintf("new_%d", i), fmt.Sprintf("new_req_%d", i), 10, 5) + "\n"); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkLoadGrowingTranscript measures a refresh after one line is appended
+// to a ~20 MiB live transcript. "incremental" is the normal path, which parses
+// only the appended bytes; "full" drops the cache first, as every changed file
+// was handled before incremental parsing.
+func BenchmarkLoadGrowingTranscript(b *testing.B) {
+	for _, mode := range []string{"incremental", "full"} {
+		b.Run(mode, func(b *testing.B) {
+			dir := b.TempDir()
+			path := writeLargeTranscript(b, dir, 5000)
+			resetLoadCache()
+			if _, err := LoadUsageData([]string{dir}, 24); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := range b.N {
+				b.StopTimer()
+				appendEntry(b, path, i)
+				if mode == "full" {
+					resetLoadCache()
+				}
+				b.StartTimer()
+
+				if _, err := LoadUsageData([]string{dir}, 24); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLoadColdStart measures a first load of many transcripts, as for
+// -report=monthly over a long history, parsed by one worker and by the
+// default pool of GOMAXPROCS workers
+func BenchmarkLoadColdStart(b *testing.B) {
+	dir := b.TempDir()
+	now := time.Now()
+	for i := range 200 {
+		var sb strings.Builder
+		for j := range 200 {
+			ts := now.Add(-time.Duration(i*200+j) * time.Second)
+			sb.WriteString(entryLine(ts, fmt.Sprintf("msg_%d_%d", i, j), fmt.Sprintf("req_%d_%d", i, j), 100, 50))
+			sb.WriteByte('\n')
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d.jsonl", i)), []byte(sb.String()), 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, mode := range []string{"sequential", "parallel"} {
+		b.Run(mode, func(b *testing.B) {
+			if mode == "sequential" {
+				setParseWorkers(b, 1)
+			}
+			for b.Loop() {
+				resetLoadCache()
+				if _, err := LoadUsageData([]string{dir}, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/sammcj/ccu/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -121,6 +123,81 @@ func TestReadJSONLFileWithFilter(t *testing.T) {
 	})
 }
 
+// A second read resumes from the first's mark only while the bytes before it
+// are unchanged; otherwise the file is read again from the start
+func TestReadJSONLFrom(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	line1 := entryLine(now.Add(-3*time.Hour), "msg_1", "req_1", 10, 5)
+	line2 := entryLine(now.Add(-2*time.Hour), "msg_2", "req_2", 20, 10)
+	line3 := entryLine(now.Add(-1*time.Hour), "msg_3", "req_3", 30, 15)
+
+	tests := []struct {
+		name        string
+		initial     string
+		change      string // the file's content for the second read
+		wantFirst   []string
+		wantResumed bool
+		wantSecond  []string
+	}{
+		{
+			name:        "grown file resumes",
+			initial:     line1 + "\n" + line2 + "\n",
+			change:      line1 + "\n" + line2 + "\n" + line3 + "\n",
+			wantFirst:   []string{"msg_1", "msg_2"},
+			wantResumed: true,
+			wantSecond:  []string{"msg_3"},
+		},
+		{
+			name:       "truncated below the mark is read again",
+			initial:    line1 + "\n" + line2 + "\n" + line3 + "\n",
+			change:     line1 + "\n",
+			wantFirst:  []string{"msg_1", "msg_2", "msg_3"},
+			wantSecond: []string{"msg_1"},
+		},
+		{
+			name:       "same size with a different tail is read again",
+			initial:    line1 + "\n" + line2 + "\n",
+			change:     line1 + "\n" + entryLine(now.Add(-2*time.Hour), "msg_9", "req_9", 90, 10) + "\n",
+			wantFirst:  []string{"msg_1", "msg_2"},
+			wantSecond: []string{"msg_1", "msg_9"},
+		},
+		{
+			name:        "partial last line is re-read once completed",
+			initial:     line1 + "\n" + line2[:len(line2)/2],
+			change:      line1 + "\n" + line2 + "\n",
+			wantFirst:   []string{"msg_1"},
+			wantResumed: true,
+			wantSecond:  []string{"msg_2"},
+		},
+	}
+
+	ids := func(r tailRead) []string {
+		var out []string
+		for _, e := range r.entries {
+			out = append(out, e.MessageID)
+		}
+		return out
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "a.jsonl")
+			require.NoError(t, os.WriteFile(path, []byte(tt.initial), 0o644))
+
+			first, err := readJSONLFrom(path, fileMark{}, time.Time{}, nil, make([]byte, 1024), &readStats{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFirst, ids(first))
+			assert.False(t, first.resumed)
+
+			require.NoError(t, os.WriteFile(path, []byte(tt.change), 0o644))
+			second, err := readJSONLFrom(path, first.mark, time.Time{}, nil, make([]byte, 1024), &readStats{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantResumed, second.resumed)
+			assert.Equal(t, tt.wantSecond, ids(second))
+			assert.Equal(t, int64(len(tt.change)), second.mark.offset, "the mark ends at the last complete line")
+		})
+	}
+}
+
 func TestLoadUsageData_Failures(t *testing.T) {
 	now := time.Now().UTC().Truncate(time.Second)
 
@@ -136,7 +213,7 @@ func TestLoadUsageData_Failures(t *testing.T) {
 			entryLine(now.Add(-4*time.Minute), "msg_good", "req_good", 20, 10),
 		)
 
-		entries, err := LoadUsageData(dir, 0)
+		entries, err := LoadUsageData([]string{dir}, 0)
 		require.NoError(t, err)
 		// bad.jsonl's valid entry before the oversized line survives alongside
 		// good.jsonl's entry, sorted oldest first.
@@ -157,7 +234,7 @@ func TestLoadUsageData_Failures(t *testing.T) {
 		require.NoError(t, os.Chmod(locked, 0o000))
 		t.Cleanup(func() { _ = os.Chmod(locked, 0o644) })
 
-		entries, err := LoadUsageData(dir, 0)
+		entries, err := LoadUsageData([]string{dir}, 0)
 		require.NoError(t, err)
 		require.Len(t, entries, 1)
 		assert.Equal(t, "msg_open", entries[0].MessageID)
@@ -166,7 +243,7 @@ func TestLoadUsageData_Failures(t *testing.T) {
 		// unchanged; the failed file must still not be served from cache as
 		// permanently empty once it becomes readable again.
 		require.NoError(t, os.Chmod(locked, 0o644))
-		entries, err = LoadUsageData(dir, 0)
+		entries, err = LoadUsageData([]string{dir}, 0)
 		require.NoError(t, err)
 		require.Len(t, entries, 2)
 		assert.Equal(t, "msg_locked", entries[0].MessageID)
@@ -193,11 +270,11 @@ func TestLoadUsageData(t *testing.T) {
 			entryLine(now.Add(-10*time.Minute), "msg_1", "req_1", 10, 5),
 		)
 
-		first, err := LoadUsageData(dir, 24)
+		first, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.NotEmpty(t, first)
 
-		second, err := LoadUsageData(dir, 24)
+		second, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.Len(t, second, len(first))
 		assert.True(t, &first[0] == &second[0], "expected pointer-identical backing array on cache hit")
@@ -210,7 +287,7 @@ func TestLoadUsageData(t *testing.T) {
 			entryLine(now.Add(-10*time.Minute), "msg_1", "req_1", 10, 5),
 		)
 
-		first, err := LoadUsageData(dir, 24)
+		first, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.Len(t, first, 1)
 
@@ -220,7 +297,7 @@ func TestLoadUsageData(t *testing.T) {
 		require.NoError(t, err)
 		require.NoError(t, f.Close())
 
-		second, err := LoadUsageData(dir, 24)
+		second, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.Len(t, second, 2)
 		assert.Equal(t, "msg_2", second[1].MessageID)
@@ -234,14 +311,14 @@ func TestLoadUsageData(t *testing.T) {
 			entryLine(now.Add(-1*time.Hour), "msg_new", "req_new", 20, 10),
 		)
 
-		first, err := LoadUsageData(dir, 24)
+		first, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.Len(t, first, 1)
 		assert.Equal(t, "msg_new", first[0].MessageID)
 
 		// Widening the window must reparse: the cached per-file entries were
 		// parse-time filtered against the 24h cutoff.
-		second, err := LoadUsageData(dir, 48)
+		second, err := LoadUsageData([]string{dir}, 48)
 		require.NoError(t, err)
 		require.Len(t, second, 2)
 		assert.Equal(t, "msg_old", second[0].MessageID)
@@ -260,7 +337,7 @@ func TestLoadUsageData(t *testing.T) {
 		require.NoError(t, os.Chtimes(pathA, fixedTime, fixedTime))
 		require.NoError(t, os.Chtimes(pathB, fixedTime, fixedTime))
 
-		first, err := LoadUsageData(dir, 24)
+		first, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.Len(t, first, 2)
 
@@ -278,7 +355,7 @@ func TestLoadUsageData(t *testing.T) {
 		require.NoError(t, err)
 		require.NoError(t, f.Close())
 
-		second, err := LoadUsageData(dir, 24)
+		second, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		ids := make([]string, len(second))
 		for i, e := range second {
@@ -298,13 +375,13 @@ func TestLoadUsageData(t *testing.T) {
 			entryLine(now.Add(-5*time.Minute), "msg_b", "req_b", 20, 10),
 		)
 
-		first, err := LoadUsageData(dir, 24)
+		first, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.Len(t, first, 2)
 
 		require.NoError(t, os.Remove(pathB))
 
-		second, err := LoadUsageData(dir, 24)
+		second, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.Len(t, second, 1)
 		assert.Equal(t, "msg_a", second[0].MessageID)
@@ -322,7 +399,7 @@ func TestLoadUsageData(t *testing.T) {
 			entryLine(now.Add(-18*time.Minute), "msg_b1", "req_b1", 30, 15),
 		)
 
-		first, err := LoadUsageData(dir, 24)
+		first, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.Len(t, first, 3, "shared entry must appear once")
 
@@ -333,7 +410,7 @@ func TestLoadUsageData(t *testing.T) {
 		require.NoError(t, err)
 		require.NoError(t, f.Close())
 
-		second, err := LoadUsageData(dir, 24)
+		second, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.Len(t, second, 4)
 		sharedCount := 0
@@ -345,6 +422,34 @@ func TestLoadUsageData(t *testing.T) {
 		assert.Equal(t, 1, sharedCount, "dedupe must hold across cached and re-parsed files")
 	})
 
+	t.Run("file that grows after a cache hit is resumed", func(t *testing.T) {
+		resetLoadCache()
+		dir := t.TempDir()
+		path := writeJSONL(t, dir, "a.jsonl",
+			entryLine(now.Add(-10*time.Minute), "msg_1", "req_1", 10, 5),
+		)
+		first, err := LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+		again, err := LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+		require.Same(t, &first[0], &again[0], "second load is a cache hit")
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+		_, err = f.WriteString(entryLine(now.Add(-5*time.Minute), "msg_2", "req_2", 20, 10) + "\n")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		grown, err := LoadUsageData([]string{dir}, 24)
+		require.NoError(t, err)
+		require.Len(t, grown, 2)
+		assert.Equal(t, "msg_1", grown[0].MessageID)
+		assert.Equal(t, "msg_2", grown[1].MessageID)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, info.Size(), globalLoadCache.files[path].mark.offset, "the mark follows the appended line")
+	})
+
 	t.Run("file older than cutoff excluded", func(t *testing.T) {
 		resetLoadCache()
 		dir := t.TempDir()
@@ -357,7 +462,7 @@ func TestLoadUsageData(t *testing.T) {
 		tenDaysAgo := now.Add(-10 * 24 * time.Hour)
 		require.NoError(t, os.Chtimes(old, tenDaysAgo, tenDaysAgo))
 
-		entries, err := LoadUsageData(dir, 24)
+		entries, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.Len(t, entries, 1)
 		assert.Equal(t, "msg_recent", entries[0].MessageID)
@@ -373,10 +478,151 @@ func TestLoadUsageData(t *testing.T) {
 			entryLine(now.Add(-10*time.Minute), "msg_1", "req_1", 20, 10),
 		)
 
-		entries, err := LoadUsageData(dir, 24)
+		entries, err := LoadUsageData([]string{dir}, 24)
 		require.NoError(t, err)
 		require.Len(t, entries, 2)
 		assert.Equal(t, "msg_1", entries[0].MessageID)
 		assert.Equal(t, "msg_2", entries[1].MessageID)
 	})
+
+	t.Run("multiple roots tag source and dedupe first root wins", func(t *testing.T) {
+		resetLoadCache()
+		primary, secondary := t.TempDir(), t.TempDir()
+		writeJSONL(t, primary, "a.jsonl",
+			entryLine(now.Add(-10*time.Minute), "msg_shared", "req_shared", 10, 5),
+		)
+		writeJSONL(t, secondary, "a.jsonl",
+			entryLine(now.Add(-10*time.Minute), "msg_shared", "req_shared", 10, 5),
+			entryLine(now.Add(-5*time.Minute), "msg_other", "req_other", 20, 10),
+		)
+
+		entries, err := LoadUsageData([]string{primary, secondary}, 24)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, primary, entries[0].Source)
+		assert.Equal(t, secondary, entries[1].Source)
+
+		// Reversing the order changes which root owns the shared message
+		resetLoadCache()
+		entries, err = LoadUsageData([]string{secondary, primary}, 24)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, secondary, entries[0].Source)
+	})
+
+	t.Run("missing and nested roots", func(t *testing.T) {
+		resetLoadCache()
+		root := t.TempDir()
+		nested := filepath.Join(root, "project")
+		require.NoError(t, os.Mkdir(nested, 0o755))
+		writeJSONL(t, nested, "a.jsonl",
+			entryLine(now.Add(-10*time.Minute), "msg_1", "req_1", 10, 5),
+		)
+
+		entries, err := LoadUsageData([]string{filepath.Join(root, "absent"), nested, root}, 24)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, nested, entries[0].Source)
+
+		_, err = LoadUsageData([]string{filepath.Join(root, "absent")}, 24)
+		assert.ErrorContains(t, err, "no JSONL files found")
+	})
+}
+
+// setParseWorkers sets the parse worker count for the duration of a test
+func setParseWorkers(t testing.TB, n int) {
+	t.Helper()
+	old := parseWorkers
+	parseWorkers = n
+	t.Cleanup(func() { parseWorkers = old })
+}
+
+func TestLoadUsageData_Parallel(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// Many files across two roots, each repeating messages from its
+	// neighbours, so which copy survives dedupe depends on merge order
+	primary, secondary := t.TempDir(), t.TempDir()
+	for i := range 40 {
+		root := primary
+		if i%2 == 1 {
+			root = secondary
+		}
+		var lines []string
+		for j := max(0, i-2); j <= i; j++ {
+			lines = append(lines, entryLine(now.Add(-time.Duration(100-j)*time.Minute), fmt.Sprintf("msg_%d", j), fmt.Sprintf("req_%d", j), 10, 5))
+		}
+		writeJSONL(t, root, fmt.Sprintf("%02d.jsonl", i), lines...)
+	}
+	roots := []string{primary, secondary}
+
+	setParseWorkers(t, 1)
+	resetLoadCache()
+	sequential, err := LoadUsageData(roots, 24)
+	require.NoError(t, err)
+	require.Len(t, sequential, 40)
+
+	t.Run("matches sequential parse", func(t *testing.T) {
+		setParseWorkers(t, 8)
+		for range 5 {
+			resetLoadCache()
+			parallel, err := LoadUsageData(roots, 24)
+			require.NoError(t, err)
+			assert.Equal(t, sequential, parallel)
+		}
+	})
+
+	t.Run("unchanged reload keeps slice identity", func(t *testing.T) {
+		setParseWorkers(t, 8)
+		resetLoadCache()
+		first, err := LoadUsageData(roots, 24)
+		require.NoError(t, err)
+		second, err := LoadUsageData(roots, 24)
+		require.NoError(t, err)
+		assert.True(t, &first[0] == &second[0], "expected pointer-identical backing array on cache hit")
+	})
+
+	t.Run("concurrent loads", func(t *testing.T) {
+		setParseWorkers(t, 8)
+		resetLoadCache()
+		var wg sync.WaitGroup
+		results := make([][]models.UsageEntry, 4)
+		for i := range results {
+			wg.Go(func() {
+				entries, err := LoadUsageData(roots, 24)
+				assert.NoError(t, err)
+				results[i] = entries
+			})
+		}
+		wg.Wait()
+		for _, entries := range results {
+			assert.Equal(t, sequential, entries)
+		}
+	})
+}
+
+func TestDefaultDataPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	work := filepath.Join(t.TempDir(), "work")
+	t.Setenv("CLAUDE_CONFIG_DIR", work+", ")
+
+	candidates, err := CandidateDataPaths()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(work, "projects"),
+		filepath.Join(home, ".config", "claude", "projects"),
+		filepath.Join(home, ".claude", "projects"),
+	}, candidates)
+
+	_, err = GetDefaultDataPaths()
+	assert.ErrorContains(t, err, "claude data directory not found")
+
+	// Only existing locations are returned, in priority order
+	require.NoError(t, os.MkdirAll(candidates[2], 0o755))
+	require.NoError(t, os.MkdirAll(candidates[0], 0o755))
+	found, err := GetDefaultDataPaths()
+	require.NoError(t, err)
+	assert.Equal(t, []string{candidates[0], candidates[2]}, found)
 }
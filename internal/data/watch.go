@@ -0,0 +1,309 @@
+package data
+
+import (
+	"errors"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watch modes reported by Watcher.Mode
+const (
+	WatchModeInotify = "inotify"
+	WatchModePolling = "polling"
+)
+
+// watchSettle is how long a watcher waits after the last write before
+// signalling, so a burst of appended lines yields one reload. watchMaxDelay
+// caps the wait while writes keep arriving.
+const (
+	watchSettle   = 150 * time.Millisecond
+	watchMaxDelay = time.Second
+)
+
+// pollInterval is how often the polling fallback stats recently active files.
+// A var rather than a const so tests can shorten it.
+var pollInterval = time.Second
+
+// hotWindow is how recently a file must have changed for the polling fallback
+// to stat it every pollInterval. Older files are only picked up by the full
+// rescan, which is also when new files are found.
+const hotWindow = time.Hour
+
+// Watcher keeps the list of JSONL files under the data roots current and
+// signals on Changes when one is written, so usage reloads as soon as Claude
+// Code records it rather than on the next refresh tick. It uses inotify on
+// Linux and otherwise polls recently active files, walking the whole tree
+// only every rescan interval. While it runs, LoadUsageData for the same roots
+// takes its file list from the watcher instead of walking the tree.
+type Watcher struct {
+	roots       []string
+	rescanEvery time.Duration
+	changes     chan struct{}
+	done        chan struct{}
+	stopped     chan struct{}
+	closeOnce   sync.Once
+
+	mu    sync.Mutex
+	mode  string
+	files map[string]jsonlFile
+}
+
+var (
+	activeWatcherMu sync.Mutex
+	activeWatcher   *Watcher
+)
+
+// Watch starts watching roots and makes the watcher the source of
+// LoadUsageData's file list for them. rescan is how often the polling fallback
+// walks the tree for new files. Close the watcher to stop it.
+func Watch(roots []string, rescan time.Duration) (*Watcher, error) {
+	w, err := newWatcher(roots, rescan)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.startNative(); err != nil {
+		log.Printf("data: file watching unavailable (%v), polling instead", err)
+		w.startPolling()
+	}
+
+	activeWatcherMu.Lock()
+	activeWatcher = w
+	activeWatcherMu.Unlock()
+	return w, nil
+}
+
+func newWatcher(roots []string, rescan time.Duration) (*Watcher, error) {
+	roots = uniquePaths(roots)
+	if len(roots) == 0 {
+		return nil, errors.New("no data directories to watch")
+	}
+	return &Watcher{
+		roots:       roots,
+		rescanEvery: rescan,
+		changes:     make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+		files:       make(map[string]jsonlFile),
+	}, nil
+}
+
+// Changes delivers a value whenever a watched JSONL file was created, written
+// or removed. Signals coalesce: one pending value stands for any number of
+// changes. The channel is closed by Close.
+func (w *Watcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// Mode reports how the watcher detects changes: WatchModeInotify or
+// WatchModePolling
+func (w *Watcher) Mode() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.mode
+}
+
+// Close stops the watcher and hands file discovery back to directory walks
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		activeWatcherMu.Lock()
+		if activeWatcher == w {
+			activeWatcher = nil
+		}
+		activeWatcherMu.Unlock()
+
+		close(w.done)
+		<-w.stopped
+		close(w.changes)
+	})
+	return nil
+}
+
+// watcherFor returns the running watcher if it covers these roots in the
+// same priority order. It may watch more: the standard locations are watched
+// whether or not they exist, while a load only lists those that do.
+func watcherFor(roots []string) *Watcher {
+	activeWatcherMu.Lock()
+	defer activeWatcherMu.Unlock()
+	if activeWatcher == nil || len(roots) == 0 {
+		return nil
+	}
+	i := 0
+	for _, root := range activeWatcher.roots {
+		if i < len(roots) && root == roots[i] {
+			i++
+		}
+	}
+	if i < len(roots) {
+		return nil
+	}
+	return activeWatcher
+}
+
+// notify signals a change without blocking; a pending signal already covers it
+func (w *Watcher) notify() {
+	select {
+	case w.changes <- struct{}{}:
+	default:
+	}
+}
+
+// snapshot returns the known files under roots, or under every root when
+// none are given, ordered by root priority, then path, as LoadUsageData's
+// first-seen-wins dedupe expects
+func (w *Watcher) snapshot(roots ...string) []jsonlFile {
+	w.mu.Lock()
+	files := slices.Collect(maps.Values(w.files))
+	w.mu.Unlock()
+	if len(roots) > 0 {
+		files = slices.DeleteFunc(files, func(f jsonlFile) bool { return !slices.Contains(roots, f.root) })
+	}
+
+	rank := make(map[string]int, len(w.roots))
+	for i, root := range w.roots {
+		rank[root] = i
+	}
+	slices.SortFunc(files, func(a, b jsonlFile) int {
+		if c := rank[a.root] - rank[b.root]; c != 0 {
+			return c
+		}
+		return strings.Compare(a.path, b.path)
+	})
+	return files
+}
+
+// rootOf returns the first root containing path
+func (w *Watcher) rootOf(path string) (string, bool) {
+	for _, root := range w.roots {
+		if rel, err := filepath.Rel(root, path); err == nil && rel != ".." &&
+			!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return root, true
+		}
+	}
+	return "", false
+}
+
+// setFile records a JSONL file's current mtime and size
+func (w *Watcher) setFile(path string, info os.FileInfo) {
+	root, ok := w.rootOf(path)
+	if !ok {
+		return
+	}
+	w.mu.Lock()
+	w.files[path] = jsonlFile{path: path, root: root, mtime: info.ModTime(), size: info.Size()}
+	w.mu.Unlock()
+}
+
+// removeUnder forgets path and every file beneath it, reporting whether any
+// file was known
+func (w *Watcher) removeUnder(path string) bool {
+	prefix := path + string(filepath.Separator)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	removed := false
+	for p := range w.files {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(w.files, p)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// rescan walks every root and replaces the file list, reporting whether it
+// differs from what was known
+func (w *Watcher) rescan() bool {
+	next := make(map[string]jsonlFile)
+	for _, root := range w.roots {
+		files, err := findJSONLFiles(root)
+		if err != nil {
+			log.Printf("data: watcher rescan of %s: %v", root, err)
+			continue
+		}
+		for _, f := range files {
+			if _, dup := next[f.path]; !dup {
+				next[f.path] = f
+			}
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := !maps.EqualFunc(w.files, next, sameFile)
+	w.files = next
+	return changed
+}
+
+func sameFile(a, b jsonlFile) bool {
+	return a.mtime.Equal(b.mtime) && a.size == b.size
+}
+
+// startPolling switches the watcher to polling and starts its loop
+func (w *Watcher) startPolling() {
+	w.rescan()
+	w.mu.Lock()
+	w.mode = WatchModePolling
+	w.mu.Unlock()
+	go func() {
+		defer close(w.stopped)
+		w.pollLoop()
+	}()
+}
+
+// pollLoop stats recently active files every pollInterval and walks the tree
+// every rescanEvery until the watcher is closed
+func (w *Watcher) pollLoop() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	lastScan := time.Now()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case now := <-ticker.C:
+			var changed bool
+			if now.Sub(lastScan) >= w.rescanEvery {
+				changed = w.rescan()
+				lastScan = now
+			} else {
+				changed = w.pollHot(now)
+			}
+			if changed {
+				w.notify()
+			}
+		}
+	}
+}
+
+// pollHot stats the files modified within hotWindow, reporting whether any
+// changed or disappeared
+func (w *Watcher) pollHot(now time.Time) bool {
+	w.mu.Lock()
+	var hot []jsonlFile
+	for _, f := range w.files {
+		if now.Sub(f.mtime) < hotWindow {
+			hot = append(hot, f)
+		}
+	}
+	w.mu.Unlock()
+
+	changed := false
+	for _, f := range hot {
+		info, err := os.Stat(f.path)
+		if err != nil {
+			changed = w.removeUnder(f.path) || changed
+			continue
+		}
+		if !info.ModTime().Equal(f.mtime) || info.Size() != f.size {
+			w.setFile(f.path, info)
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,242 @@
+//go:build linux
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask selects the directory events that can change a JSONL file or
+// the set of files
+const inotifyMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE |
+	unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_DELETE | unix.IN_ONLYDIR
+
+// inotifyPollMillis bounds how long the event loop blocks, and so how long
+// Close waits for it
+const inotifyPollMillis = 500
+
+// inotify watches every directory under the roots. Each directory needs its
+// own watch, so new directories are watched as they appear. A root that
+// doesn't exist yet, such as ~/.config/claude/projects before Claude Code
+// first writes there, is checked for every pollInterval and watched once it
+// appears.
+type inotify struct {
+	w    *Watcher
+	fd   int
+	dirs map[int]string // watch descriptor -> directory
+}
+
+// startNative watches the roots with inotify. It fails, leaving the caller
+// to poll, if inotify is unavailable or the watch limit is too low for the
+// tree (fs.inotify.max_user_watches).
+func (w *Watcher) startNative() error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify: %w", err)
+	}
+
+	in := &inotify{w: w, fd: fd, dirs: make(map[int]string)}
+	for _, root := range w.roots {
+		if err := in.addTree(root); err != nil {
+			unix.Close(fd)
+			return err
+		}
+	}
+
+	w.mu.Lock()
+	w.mode = WatchModeInotify
+	w.mu.Unlock()
+	go in.run()
+	return nil
+}
+
+// addTree watches dir and every directory beneath it and records their JSONL
+// files. Watches are added before files are read, so a file created during
+// the walk is seen either by the walk or as an event. Unreadable directories
+// are skipped; running out of watches is an error.
+func (in *inotify) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			wd, err := unix.InotifyAddWatch(in.fd, path, inotifyMask)
+			if errors.Is(err, unix.ENOSPC) {
+				return fmt.Errorf("inotify watch limit reached at %s", path)
+			}
+			if err != nil {
+				return fs.SkipDir
+			}
+			in.dirs[wd] = path
+			return nil
+		}
+		if isJSONL(d.Name()) {
+			if info, err := d.Info(); err == nil {
+				in.w.setFile(path, info)
+			}
+		}
+		return nil
+	})
+}
+
+// addMissingRoots watches any root that has no watch, because it didn't exist
+// at startup or was deleted since, but is now a directory. It reports whether
+// one was added.
+func (in *inotify) addMissingRoots() bool {
+	watched := make(map[string]bool, len(in.dirs))
+	for _, dir := range in.dirs {
+		watched[dir] = true
+	}
+	added := false
+	for _, root := range in.w.roots {
+		if watched[root] {
+			continue
+		}
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := in.addTree(root); err != nil {
+			log.Printf("data: %v; files under %s won't update until restart", err, root)
+		}
+		added = true
+	}
+	return added
+}
+
+// removeTree drops the watches on dir and everything beneath it, for a
+// directory moved out of sight, and forgets its files
+func (in *inotify) removeTree(dir string) bool {
+	prefix := dir + string(filepath.Separator)
+	for wd, path := range in.dirs {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			_, _ = unix.InotifyRmWatch(in.fd, uint32(wd))
+			delete(in.dirs, wd)
+		}
+	}
+	return in.w.removeUnder(dir)
+}
+
+// run reads events until the watcher closes, signalling once writes settle.
+// If reading fails the watcher carries on by polling.
+func (in *inotify) run() {
+	defer close(in.w.stopped)
+
+	err := in.loop()
+	unix.Close(in.fd)
+	if err != nil {
+		log.Printf("data: inotify failed (%v), polling instead", err)
+		in.w.rescan()
+		in.w.mu.Lock()
+		in.w.mode = WatchModePolling
+		in.w.mu.Unlock()
+		in.w.notify()
+		in.w.pollLoop()
+	}
+}
+
+func (in *inotify) loop() error {
+	buf := make([]byte, 64*1024)
+	fds := []unix.PollFd{{Fd: int32(in.fd), Events: unix.POLLIN}}
+	var pendingSince, lastRootCheck time.Time
+
+	for {
+		select {
+		case <-in.w.done:
+			return nil
+		default:
+		}
+
+		timeout := inotifyPollMillis
+		if !pendingSince.IsZero() {
+			timeout = int(watchSettle / time.Millisecond)
+		}
+		n, err := unix.Poll(fds, timeout)
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			return fmt.Errorf("poll: %w", err)
+		}
+
+		if n > 0 {
+			nread, err := unix.Read(in.fd, buf)
+			if err != nil && !errors.Is(err, unix.EAGAIN) && !errors.Is(err, unix.EINTR) {
+				return fmt.Errorf("read: %w", err)
+			}
+			if nread > 0 && in.handle(buf[:nread]) && pendingSince.IsZero() {
+				pendingSince = time.Now()
+			}
+		}
+
+		if time.Since(lastRootCheck) >= pollInterval {
+			lastRootCheck = time.Now()
+			if in.addMissingRoots() && pendingSince.IsZero() {
+				pendingSince = time.Now()
+			}
+		}
+
+		// Signal once events go quiet, or after watchMaxDelay of constant writes
+		if !pendingSince.IsZero() && (n == 0 || time.Since(pendingSince) >= watchMaxDelay) {
+			pendingSince = time.Time{}
+			in.w.notify()
+		}
+	}
+}
+
+// handle applies a buffer of events, reporting whether any JSONL file changed
+func (in *inotify) handle(buf []byte) bool {
+	changed := false
+	for off := 0; off+unix.SizeofInotifyEvent <= len(buf); {
+		ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+		nameStart := off + unix.SizeofInotifyEvent
+		off = nameStart + int(ev.Len)
+		name := strings.TrimRight(string(buf[nameStart:off]), "\x00")
+
+		if ev.Mask&unix.IN_Q_OVERFLOW != 0 {
+			// Events were dropped; only a full rescan is trustworthy
+			changed = in.w.rescan() || changed
+			continue
+		}
+		dir, ok := in.dirs[int(ev.Wd)]
+		if !ok {
+			continue
+		}
+		if ev.Mask&unix.IN_IGNORED != 0 {
+			// The directory itself was deleted or unmounted
+			delete(in.dirs, int(ev.Wd))
+			changed = in.w.removeUnder(dir) || changed
+			continue
+		}
+		if name == "" {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		removed := ev.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0
+		switch {
+		case ev.Mask&unix.IN_ISDIR != 0 && removed:
+			changed = in.removeTree(path) || changed
+		case ev.Mask&unix.IN_ISDIR != 0:
+			if err := in.addTree(path); err != nil {
+				log.Printf("data: %v; files under %s won't update until restart", err, path)
+			}
+			changed = true
+		case !isJSONL(name):
+		case removed:
+			changed = in.w.removeUnder(path) || changed
+		default:
+			if info, err := os.Stat(path); err == nil {
+				in.w.setFile(path, info)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package data
+
+import "errors"
+
+// startNative is only implemented with inotify; other platforms poll
+func (w *Watcher) startNative() error {
+	return errors.New("native file watching is only supported on Linux")
+}
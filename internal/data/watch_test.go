@@ -0,0 +1,191 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForChange fails the test unless the watcher signals within timeout
+func waitForChange(t *testing.T, w *Watcher, timeout time.Duration) {
+	t.Helper()
+	select {
+	case _, ok := <-w.Changes():
+		require.True(t, ok, "changes channel closed")
+	case <-time.After(timeout):
+		t.Fatalf("no change signalled within %s (%s mode)", timeout, w.Mode())
+	}
+}
+
+// appendLine appends a JSONL line to path
+func appendLine(t *testing.T, path, line string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(line + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestWatcher(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	pollInterval = 50 * time.Millisecond
+	t.Cleanup(func() { pollInterval = time.Second })
+
+	starts := map[string]func(w *Watcher) error{
+		WatchModePolling: func(w *Watcher) error { w.startPolling(); return nil },
+	}
+	if probe, err := newWatcher([]string{t.TempDir()}, time.Hour); err == nil && probe.startNative() == nil {
+		close(probe.done)
+		<-probe.stopped
+		starts[WatchModeInotify] = (*Watcher).startNative
+	}
+
+	for mode, start := range starts {
+		t.Run(mode, func(t *testing.T) {
+			root := t.TempDir()
+			project := filepath.Join(root, "project")
+			require.NoError(t, os.Mkdir(project, 0o755))
+			path := writeJSONL(t, project, "a.jsonl",
+				entryLine(now.Add(-10*time.Minute), "msg_1", "req_1", 10, 5),
+			)
+
+			// The polling fallback finds new files on its rescan, here every tick
+			w, err := newWatcher([]string{root}, pollInterval)
+			require.NoError(t, err)
+			require.NoError(t, start(w))
+			t.Cleanup(func() { _ = w.Close() })
+			assert.Equal(t, mode, w.Mode())
+			require.Len(t, w.snapshot(), 1)
+
+			// An append is signalled and the file list carries the new size
+			appendLine(t, path, entryLine(now.Add(-5*time.Minute), "msg_2", "req_2", 20, 10))
+			waitForChange(t, w, 3*time.Second)
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, info.Size(), w.snapshot()[0].size)
+
+			// Files in a new directory are picked up
+			newProject := filepath.Join(root, "new-project")
+			require.NoError(t, os.Mkdir(newProject, 0o755))
+			writeJSONL(t, newProject, "b.jsonl", entryLine(now, "msg_3", "req_3", 1, 1))
+			require.Eventually(t, func() bool { return len(w.snapshot()) == 2 }, 3*time.Second, 10*time.Millisecond)
+
+			// Removing a directory forgets its files
+			require.NoError(t, os.RemoveAll(newProject))
+			require.Eventually(t, func() bool { return len(w.snapshot()) == 1 }, 3*time.Second, 10*time.Millisecond)
+
+			// Close closes the channel once any pending signal is drained
+			require.NoError(t, w.Close())
+			drained := make(chan struct{})
+			go func() {
+				for range w.Changes() {
+				}
+				close(drained)
+			}()
+			select {
+			case <-drained:
+			case <-time.After(time.Second):
+				t.Fatal("Close should close the changes channel")
+			}
+		})
+	}
+}
+
+// TestWatcherMissingRoot checks a root created after the watcher starts is
+// picked up, as when Claude Code first writes to a new data directory
+func TestWatcherMissingRoot(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	pollInterval = 50 * time.Millisecond
+	t.Cleanup(func() { pollInterval = time.Second })
+
+	existing := t.TempDir()
+	missing := filepath.Join(t.TempDir(), "claude", "projects")
+	w, err := Watch([]string{existing, missing}, pollInterval)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = w.Close() })
+	assert.Empty(t, w.snapshot())
+
+	project := filepath.Join(missing, "project")
+	require.NoError(t, os.MkdirAll(project, 0o755))
+	writeJSONL(t, project, "a.jsonl", entryLine(now, "msg_1", "req_1", 10, 5))
+	waitForChange(t, w, 3*time.Second)
+	require.Eventually(t, func() bool { return len(w.snapshot()) == 1 }, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, missing, w.snapshot()[0].root)
+}
+
+// With no -data, every standard location is watched, and loads over the ones
+// that exist keep using the watcher as new ones appear
+func TestWatcherDefaultRootCreatedLater(t *testing.T) {
+	resetLoadCache()
+	now := time.Now().UTC().Truncate(time.Second)
+	pollInterval = 50 * time.Millisecond
+	t.Cleanup(func() { pollInterval = time.Second })
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+	t.Setenv("CLAUDE_CONFIG_DIR", "")
+	legacy := filepath.Join(home, ".claude", "projects", "p")
+	require.NoError(t, os.MkdirAll(legacy, 0o755))
+	writeJSONL(t, legacy, "a.jsonl", entryLine(now.Add(-10*time.Minute), "msg_1", "req_1", 10, 5))
+
+	candidates, err := CandidateDataPaths()
+	require.NoError(t, err)
+	w, err := Watch(candidates, pollInterval)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = w.Close() })
+
+	found, err := GetDefaultDataPaths()
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Same(t, w, watcherFor(found), "the existing locations are served by the watcher")
+	entries, err := LoadUsageData(nil, 24)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// Newer installs create the XDG location
+	xdg := filepath.Join(home, "xdg", "claude", "projects", "p")
+	require.NoError(t, os.MkdirAll(xdg, 0o755))
+	writeJSONL(t, xdg, "b.jsonl", entryLine(now.Add(-5*time.Minute), "msg_2", "req_2", 20, 10))
+	waitForChange(t, w, 3*time.Second)
+	found, err = GetDefaultDataPaths()
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+	assert.Same(t, w, watcherFor(found))
+	entries, err = LoadUsageData(nil, 24)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestLoadUsageDataUsesWatcher(t *testing.T) {
+	resetLoadCache()
+	now := time.Now().UTC().Truncate(time.Second)
+	root := t.TempDir()
+	path := writeJSONL(t, root, "a.jsonl",
+		entryLine(now.Add(-10*time.Minute), "msg_1", "req_1", 10, 5),
+	)
+
+	w, err := Watch([]string{root}, time.Minute)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = w.Close() })
+	assert.Same(t, w, watcherFor([]string{root}))
+	assert.Nil(t, watcherFor([]string{root, t.TempDir()}), "a watcher only serves its own roots")
+
+	entries, err := LoadUsageData([]string{root}, 24)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, root, entries[0].Source)
+
+	appendLine(t, path, entryLine(now.Add(-5*time.Minute), "msg_2", "req_2", 20, 10))
+	waitForChange(t, w, 3*time.Second)
+	entries, err = LoadUsageData([]string{root}, 24)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	require.NoError(t, w.Close())
+	assert.Nil(t, watcherFor([]string{root}))
+}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"os"
 	"sort"
 	"strings"
 	"time"
@@ -28,31 +29,64 @@ const UpstreamURL = "https://raw.githubusercontent.com/BerriAI/litellm/main/mode
 // floating point noise, not pricing changes
 const tolerance = 0.001
 
+// requestTolerance is the per-request equivalent of tolerance. Server tool
+// rates are fractions of a cent, so the per-token threshold would hide drift.
+const requestTolerance = 0.00001
+
 // ancientPrefixes are pre-Claude-3 model families that predate ccu entirely
 // and are not worth flagging even if upstream still lists them.
 var ancientPrefixes = []string{"claude-2", "claude-instant"}
 
 type upstreamModel struct {
-	InputCostPerToken         float64 `json:"input_cost_per_token"`
-	OutputCostPerToken        float64 `json:"output_cost_per_token"`
-	CacheCreationCostPerToken float64 `json:"cache_creation_input_token_cost"`
-	CacheReadCostPerToken     float64 `json:"cache_read_input_token_cost"`
-	Provider                  string  `json:"litellm_provider"`
-	Mode                      string  `json:"mode"`
-	DeprecationDate           string  `json:"deprecation_date"`
+	InputCostPerToken           float64 `json:"input_cost_per_token"`
+	OutputCostPerToken          float64 `json:"output_cost_per_token"`
+	CacheCreationCostPerToken   float64 `json:"cache_creation_input_token_cost"`
+	CacheCreation1hCostPerToken float64 `json:"cache_creation_input_token_cost_above_1hr"`
+	CacheReadCostPerToken       float64 `json:"cache_read_input_token_cost"`
+	// Long-context premium rates, billed once a prompt exceeds 200k tokens
+	InputCostPerTokenAbove200k         float64 `json:"input_cost_per_token_above_200k_tokens"`
+	OutputCostPerTokenAbove200k        float64 `json:"output_cost_per_token_above_200k_tokens"`
+	CacheCreationCostPerTokenAbove200k float64 `json:"cache_creation_input_token_cost_above_200k_tokens"`
+	CacheReadCostPerTokenAbove200k     float64 `json:"cache_read_input_token_cost_above_200k_tokens"`
+	Provider                           string  `json:"litellm_provider"`
+	Mode                               string  `json:"mode"`
+	DeprecationDate                    string  `json:"deprecation_date"`
+	// SearchContextCost is the per-request web search price, broken down by
+	// search context size. Anthropic bills one flat rate, so every size is equal.
+	SearchContextCost *struct {
+		Medium float64 `json:"search_context_size_medium"`
+	} `json:"search_context_cost_per_query"`
 }
 
 // Finding describes one way ccu's tables disagree with upstream.
 // Model is the canonical (normalised) name an agent would add or fix.
+// Upstream is set for pricing findings: the model's upstream rates, with any
+// rate upstream lacks taken from ccu's current table (or the standard cache
+// multiples for a model ccu has no price for).
 type Finding struct {
-	Model string
-	Issue string
+	Model    string           `json:"model"`
+	Kind     string           `json:"kind"`
+	Issue    string           `json:"issue"`
+	Upstream *pricing.Pricing `json:"upstream,omitempty"`
 }
 
 // Report summarises a comparison run.
 type Report struct {
-	Checked  int
-	Findings []Finding
+	Checked  int       `json:"checked"`
+	Findings []Finding `json:"findings"`
+}
+
+// LoadUpstream reads the upstream dataset from source: an http(s) URL is
+// fetched, anything else is read as a local file (for air-gapped CI).
+func LoadUpstream(ctx context.Context, source string) ([]byte, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return FetchUpstream(ctx, source)
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("reading upstream dataset: %w", err)
+	}
+	return data, nil
 }
 
 // FetchUpstream downloads the upstream pricing dataset from url
@@ -91,7 +125,7 @@ func Compare(data []byte) (*Report, error) {
 		return nil, fmt.Errorf("parsing upstream dataset: %w", err)
 	}
 
-	report := &Report{}
+	report := &Report{Findings: []Finding{}}
 	seen := make(map[string]bool) // dedupe by normalised model + issue kind
 
 	ids := make([]string, 0, len(raw))
@@ -112,18 +146,39 @@ func Compare(data []byte) (*Report, error) {
 		}
 		report.Checked++
 
+		// A new version caught by its family's catch-all pattern would be
+		// compared against (and a pricing file would overwrite) the older
+		// model's prices, so it needs a registry entry before anything else
+		if m, ok := models.LookupModel(name); ok && m.DisplayName != "" && ui.FormatModelNameSimple(name) != m.DisplayName {
+			addFinding(report, seen, Finding{
+				Model: name,
+				Kind:  "registry",
+				Issue: fmt.Sprintf("not in the model registry; grouped and priced as %s (%s) by a family pattern", m.ID, m.DisplayName),
+			})
+			continue
+		}
+
 		normalised := models.NormaliseModelName(name)
-		local, ok := pricing.ModelPricing[normalised]
+		local, ok := pricing.Lookup(normalised, time.Now())
 		if !ok {
-			addFinding(report, seen, normalised, "missing-pricing",
-				fmt.Sprintf("no pricing entry (silently billed at the Sonnet fallback rate); upstream model %q", name))
+			upstream := um.pricing(pricing.Standard(um.InputCostPerToken*1_000_000, um.OutputCostPerToken*1_000_000))
+			addFinding(report, seen, Finding{
+				Model:    normalised,
+				Kind:     "missing-pricing",
+				Issue:    fmt.Sprintf("no pricing entry (silently billed at the Sonnet fallback rate); upstream model %q", name),
+				Upstream: &upstream,
+			})
 		} else {
 			comparePricing(report, seen, normalised, name, local, um)
+			compareServerToolPricing(report, seen, normalised, name, um)
 		}
 
 		if ui.FormatModelNameSimple(name) == name {
-			addFinding(report, seen, normalised, "display-name",
-				fmt.Sprintf("no friendly display name (UI shows raw %q)", name))
+			addFinding(report, seen, Finding{
+				Model: normalised,
+				Kind:  "display-name",
+				Issue: fmt.Sprintf("no friendly display name (UI shows raw %q)", name),
+			})
 		}
 	}
 
@@ -152,54 +207,200 @@ func isCurrentAnthropicChatModel(name string, um upstreamModel) bool {
 	return true
 }
 
+// rateComparison pairs a local per-1M rate with its upstream per-token value
+type rateComparison struct {
+	label    string
+	local    float64
+	upstream float64 // per token; converted to per-1M when compared
+}
+
 // comparePricing flags per-rate differences between local and upstream values.
 // Upstream zero values are skipped: they mean the dataset is missing the field
 // (common for cache rates on older models), not that the rate is free.
 func comparePricing(report *Report, seen map[string]bool, normalised, upstreamID string, local pricing.Pricing, um upstreamModel) {
-	rates := []struct {
-		label    string
-		local    float64
-		upstream float64 // per token; converted to per-1M below
-	}{
+	upstream := um.pricing(local)
+	rates := []rateComparison{
 		{"input", local.Input, um.InputCostPerToken},
 		{"output", local.Output, um.OutputCostPerToken},
 		{"cache write", local.CacheCreation, um.CacheCreationCostPerToken},
+		{"cache write 1h", local.CacheCreation1h, um.CacheCreation1hCostPerToken},
 		{"cache read", local.CacheRead, um.CacheReadCostPerToken},
 	}
 
+	if um.InputCostPerTokenAbove200k > 0 {
+		if local.LongContext == nil {
+			addFinding(report, seen, Finding{
+				Model: normalised,
+				Kind:  "missing-long-context",
+				Issue: fmt.Sprintf("no long-context tier (prompts above 200k tokens billed at standard rates); upstream input is $%.2f/M (model %q)",
+					um.InputCostPerTokenAbove200k*1_000_000, upstreamID),
+				Upstream: &upstream,
+			})
+		} else {
+			long := local.LongContext.Rates
+			rates = append(rates,
+				rateComparison{"long context input", long.Input, um.InputCostPerTokenAbove200k},
+				rateComparison{"long context output", long.Output, um.OutputCostPerTokenAbove200k},
+				rateComparison{"long context cache write", long.CacheCreation, um.CacheCreationCostPerTokenAbove200k},
+				rateComparison{"long context cache read", long.CacheRead, um.CacheReadCostPerTokenAbove200k},
+			)
+		}
+	}
+
 	for _, r := range rates {
 		upstreamPerM := r.upstream * 1_000_000
 		if upstreamPerM == 0 {
 			continue
 		}
 		if math.Abs(upstreamPerM-r.local) > tolerance {
-			addFinding(report, seen, normalised, "rate-"+r.label,
-				fmt.Sprintf("%s rate is $%.2f/M locally but $%.2f/M upstream (model %q)",
-					r.label, r.local, upstreamPerM, upstreamID))
+			addFinding(report, seen, Finding{
+				Model: normalised,
+				Kind:  "rate-" + r.label,
+				Issue: fmt.Sprintf("%s rate is $%.2f/M locally but $%.2f/M upstream (model %q)",
+					r.label, r.local, upstreamPerM, upstreamID),
+				Upstream: &upstream,
+			})
 		}
 	}
 }
 
-func addFinding(report *Report, seen map[string]bool, model, kind, issue string) {
-	key := model + "|" + kind
+// compareServerToolPricing flags a difference between ServerTools and the
+// upstream per-request web search price. Models without the field are skipped,
+// as are zero values, for the same missing-data reason as comparePricing.
+func compareServerToolPricing(report *Report, seen map[string]bool, normalised, upstreamID string, um upstreamModel) {
+	if um.SearchContextCost == nil || um.SearchContextCost.Medium == 0 {
+		return
+	}
+	upstream := um.SearchContextCost.Medium
+	local := pricing.ServerTools.WebSearch
+	if math.Abs(upstream-local) > requestTolerance {
+		addFinding(report, seen, Finding{
+			Model: normalised,
+			Kind:  "rate-web search",
+			Issue: fmt.Sprintf("web search rate is $%.4f/request locally but $%.4f/request upstream (model %q)",
+				local, upstream, upstreamID),
+		})
+	}
+}
+
+func addFinding(report *Report, seen map[string]bool, f Finding) {
+	key := f.Model + "|" + f.Kind
 	if seen[key] {
 		return
 	}
 	seen[key] = true
-	report.Findings = append(report.Findings, Finding{Model: model, Issue: issue})
+	report.Findings = append(report.Findings, f)
+}
+
+// pricing returns the upstream rates per 1M tokens, taking any rate the
+// dataset lacks from base
+func (um upstreamModel) pricing(base pricing.Pricing) pricing.Pricing {
+	pick := func(perToken, fallback float64) float64 {
+		if perToken > 0 {
+			return perToken * 1_000_000
+		}
+		return fallback
+	}
+
+	p := pricing.Pricing{
+		Input:           pick(um.InputCostPerToken, base.Input),
+		Output:          pick(um.OutputCostPerToken, base.Output),
+		CacheCreation:   pick(um.CacheCreationCostPerToken, base.CacheCreation),
+		CacheCreation1h: pick(um.CacheCreation1hCostPerToken, base.CacheCreation1h),
+		CacheRead:       pick(um.CacheReadCostPerToken, base.CacheRead),
+		LongContext:     base.LongContext,
+	}
+	if um.InputCostPerTokenAbove200k > 0 {
+		longBase := pricing.Standard(um.InputCostPerTokenAbove200k*1_000_000, um.OutputCostPerTokenAbove200k*1_000_000)
+		if base.LongContext != nil {
+			longBase = base.LongContext.Rates
+		}
+		p.LongContext = &pricing.LongContextTier{
+			Threshold: 200_000,
+			Rates: pricing.Pricing{
+				Input:           pick(um.InputCostPerTokenAbove200k, longBase.Input),
+				Output:          pick(um.OutputCostPerTokenAbove200k, longBase.Output),
+				CacheCreation:   pick(um.CacheCreationCostPerTokenAbove200k, longBase.CacheCreation),
+				CacheCreation1h: longBase.CacheCreation1h,
+				CacheRead:       pick(um.CacheReadCostPerTokenAbove200k, longBase.CacheRead),
+			},
+		}
+	}
+	return p
+}
+
+// Overrides returns a pricing file that brings every missing or drifted model
+// in line with upstream, or nil when no finding can be fixed that way. Prices
+// are undated, so they also apply to past usage; add an effective_from date by
+// hand when the drift is a price change rather than a correction.
+func (r *Report) Overrides() *pricing.File {
+	file := &pricing.File{Models: make(map[string][]pricing.FilePrice)}
+	for _, f := range r.Findings {
+		if f.Upstream == nil {
+			continue
+		}
+		key := models.PricingKey(f.Model)
+		if _, done := file.Models[key]; !done {
+			file.Models[key] = []pricing.FilePrice{pricing.NewFilePrice(*f.Upstream)}
+		}
+	}
+	if len(file.Models) == 0 {
+		return nil
+	}
+	return file
+}
+
+// WriteOverrides writes Overrides to a new file at path, refusing to replace
+// an existing file so a hand-maintained pricing file is never clobbered.
+// Returns the number of models written; zero writes nothing.
+func (r *Report) WriteOverrides(path string) (int, error) {
+	file := r.Overrides()
+	if file == nil {
+		return 0, nil
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("encoding pricing file: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("creating pricing file: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return 0, fmt.Errorf("writing pricing file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return 0, fmt.Errorf("writing pricing file: %w", err)
+	}
+	return len(file.Models), nil
+}
+
+// JSON renders the report for machine consumption
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(struct {
+		InSync bool `json:"in_sync"`
+		*Report
+	}{len(r.Findings) == 0, r}, "", "  ")
 }
 
 const agentHint = `
 Agent hint - to update ccu's model support, change these in order:
-  1. internal/models/entry.go      NormaliseModelName(): map the new model ID to a canonical key
-  2. internal/pricing/pricing.go   ModelPricing: add or correct the canonical key (USD per 1M
-                                   tokens; cache write is typically 1.25x input and cache read
-                                   0.1x input unless upstream says otherwise)
-  3. internal/ui/dashboard.go      FormatModelNameSimple(): add the model family to the families
-                                   list if it is not opus/sonnet/haiku/fable/mythos
-  4. Add matching test cases in internal/models/entry_test.go, internal/pricing/pricing_test.go
+  1. internal/models/registry.json Add the model (id, family, display_name, patterns) above any
+                                   family catch-all it would otherwise match; add a family first
+                                   if it is new. pricing_key shares another model's prices
+  2. internal/pricing/pricing.go   ModelPricing: add or correct the pricing key (USD per 1M
+                                   tokens; cache write is typically 1.25x input, 1-hour cache
+                                   write 2x input and cache read 0.1x input unless upstream says
+                                   otherwise). Per-request server tool rates live in ServerTools;
+                                   premium rates above 200k prompt tokens go in LongContext
+  3. Add matching test cases in internal/models/entry_test.go, internal/pricing/pricing_test.go
      and internal/ui/dashboard_test.go
 Verify with: make test && ./ccu -check-models
+To fix drift without a code change, map new IDs in ~/.ccu/models.json (same format as
+registry.json) and write upstream rates to a pricing file:
+  ./ccu -check-models -check-models-write=$HOME/.ccu/pricing.json
 `
 
 // Format renders the report for terminal output, including an agent hint when
@@ -2,11 +2,15 @@ package modelcheck
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/sammcj/ccu/internal/pricing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -88,6 +92,27 @@ func TestCompareUnknownModel(t *testing.T) {
 	assert.Contains(t, out, "internal/pricing/pricing.go")
 }
 
+func TestCompareUnregisteredVersion(t *testing.T) {
+	// Opus 4.9 matches the Opus catch-all and would be priced as legacy Opus 4
+	fixture := `{
+		"claude-opus-4-9": {
+			"input_cost_per_token": 0.000005,
+			"output_cost_per_token": 0.000025,
+			"litellm_provider": "anthropic",
+			"mode": "chat"
+		}
+	}`
+	report, err := Compare([]byte(fixture))
+	require.NoError(t, err)
+
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, "claude-opus-4-9", report.Findings[0].Model)
+	assert.Equal(t, "registry", report.Findings[0].Kind)
+	assert.Contains(t, report.Findings[0].Issue, "priced as claude-opus-4 (Opus 4)")
+	// No rate findings, so a generated pricing file can't clobber Opus 4
+	assert.Nil(t, report.Overrides())
+}
+
 func TestCompareRateMismatch(t *testing.T) {
 	fixture := `{
 		"claude-opus-4-8": {
@@ -141,6 +166,65 @@ func TestCompareSkipsMissingUpstreamCacheRates(t *testing.T) {
 	assert.Empty(t, report.Findings)
 }
 
+func TestCompareWebSearchRate(t *testing.T) {
+	fixture := `{
+		"claude-sonnet-4-6": {
+			"input_cost_per_token": 0.000003,
+			"output_cost_per_token": 0.000015,
+			"search_context_cost_per_query": {
+				"search_context_size_low": 0.02,
+				"search_context_size_medium": 0.02,
+				"search_context_size_high": 0.02
+			},
+			"litellm_provider": "anthropic",
+			"mode": "chat"
+		},
+		"claude-haiku-4-5": {
+			"input_cost_per_token": 0.000001,
+			"output_cost_per_token": 0.000005,
+			"search_context_cost_per_query": {
+				"search_context_size_medium": 0.01
+			},
+			"litellm_provider": "anthropic",
+			"mode": "chat"
+		}
+	}`
+	report, err := Compare([]byte(fixture))
+	require.NoError(t, err)
+
+	// Haiku matches the local $0.01/request rate; only Sonnet drifts
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, "claude-sonnet-4-6", report.Findings[0].Model)
+	assert.Contains(t, report.Findings[0].Issue, "web search rate is $0.0100/request locally but $0.0200/request upstream")
+}
+
+func TestCompareLongContextRates(t *testing.T) {
+	fixture := `{
+		"claude-sonnet-4-6": {
+			"input_cost_per_token": 0.000003,
+			"input_cost_per_token_above_200k_tokens": 0.000006,
+			"output_cost_per_token_above_200k_tokens": 0.000025,
+			"litellm_provider": "anthropic",
+			"mode": "chat"
+		},
+		"claude-opus-4-8": {
+			"input_cost_per_token": 0.000005,
+			"input_cost_per_token_above_200k_tokens": 0.00001,
+			"litellm_provider": "anthropic",
+			"mode": "chat"
+		}
+	}`
+	report, err := Compare([]byte(fixture))
+	require.NoError(t, err)
+
+	// Opus 4.8 has no local tier; Sonnet's long-context output rate drifts
+	require.Len(t, report.Findings, 2)
+	assert.Equal(t, "claude-opus-4-8", report.Findings[0].Model)
+	assert.Contains(t, report.Findings[0].Issue, "no long-context tier")
+	assert.Equal(t, "claude-sonnet-4-6", report.Findings[1].Model)
+	assert.Contains(t, report.Findings[1].Issue, "long context output rate is $22.50/M locally but $25.00/M upstream")
+}
+
 func TestCompareInvalidJSON(t *testing.T) {
 	_, err := Compare([]byte("not json"))
 	assert.Error(t, err)
@@ -174,6 +258,125 @@ func TestFetchUpstream(t *testing.T) {
 	})
 }
 
+func TestLoadUpstream(t *testing.T) {
+	t.Run("local file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "litellm.json")
+		require.NoError(t, os.WriteFile(path, []byte(fixtureInSync), 0o600))
+
+		data, err := LoadUpstream(context.Background(), path)
+		require.NoError(t, err)
+		assert.Equal(t, fixtureInSync, string(data))
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadUpstream(context.Background(), filepath.Join(t.TempDir(), "absent.json"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("url", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"ok": true}`))
+		}))
+		defer srv.Close()
+
+		data, err := LoadUpstream(context.Background(), srv.URL)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"ok": true}`, string(data))
+	})
+}
+
+func TestReportJSON(t *testing.T) {
+	report, err := Compare([]byte(fixtureInSync))
+	require.NoError(t, err)
+	out, err := report.JSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"in_sync": true, "checked": 2, "findings": []}`, string(out))
+
+	report, err = Compare([]byte(`{
+		"claude-opus-4-8": {
+			"input_cost_per_token": 0.000007,
+			"output_cost_per_token": 0.000025,
+			"litellm_provider": "anthropic",
+			"mode": "chat"
+		}
+	}`))
+	require.NoError(t, err)
+	out, err = report.JSON()
+	require.NoError(t, err)
+
+	var decoded struct {
+		InSync   bool `json:"in_sync"`
+		Findings []struct {
+			Model    string         `json:"model"`
+			Kind     string         `json:"kind"`
+			Upstream map[string]any `json:"upstream"`
+		} `json:"findings"`
+	}
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	assert.False(t, decoded.InSync)
+	require.Len(t, decoded.Findings, 1)
+	assert.Equal(t, "claude-opus-4-8", decoded.Findings[0].Model)
+	assert.Equal(t, "rate-input", decoded.Findings[0].Kind)
+	assert.InDelta(t, 7.0, decoded.Findings[0].Upstream["input"], 1e-9)
+}
+
+func TestWriteOverrides(t *testing.T) {
+	fixture := `{
+		"claude-opus-4-8": {
+			"input_cost_per_token": 0.000007,
+			"output_cost_per_token": 0.000025,
+			"litellm_provider": "anthropic",
+			"mode": "chat"
+		},
+		"claude-zenith-1": {
+			"input_cost_per_token": 0.00002,
+			"output_cost_per_token": 0.0001,
+			"cache_read_input_token_cost": 0.000001,
+			"litellm_provider": "anthropic",
+			"mode": "chat"
+		}
+	}`
+	report, err := Compare([]byte(fixture))
+	require.NoError(t, err)
+
+	overrides := report.Overrides()
+	require.NotNil(t, overrides)
+	require.Len(t, overrides.Models, 2)
+
+	// Drifted model: upstream input, local rates where upstream has none
+	opus := overrides.Models["claude-opus-4-8"][0]
+	assert.Equal(t, 7.0, *opus.Input)
+	assert.Equal(t, 6.25, *opus.CacheWrite)
+	// Missing model: upstream rates, standard multiples where upstream has none
+	zenith := overrides.Models["claude-zenith-1"][0]
+	assert.InDelta(t, 20.0, *zenith.Input, 1e-9)
+	assert.InDelta(t, 1.0, *zenith.CacheRead, 1e-9)
+	assert.InDelta(t, 25.0, *zenith.CacheWrite, 1e-9)
+
+	path := filepath.Join(t.TempDir(), "pricing.json")
+	n, err := report.WriteOverrides(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// An existing file is never replaced
+	_, err = report.WriteOverrides(path)
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	// Loading the generated file brings the pricing tables in sync
+	require.NoError(t, pricing.LoadFile(path))
+	t.Cleanup(func() {
+		empty := filepath.Join(t.TempDir(), "empty.json")
+		require.NoError(t, os.WriteFile(empty, []byte(`{"models": {}}`), 0o600))
+		require.NoError(t, pricing.LoadFile(empty))
+	})
+	report, err = Compare([]byte(fixture))
+	require.NoError(t, err)
+	for _, f := range report.Findings {
+		assert.Equal(t, "display-name", f.Kind, f.Issue)
+	}
+	assert.Nil(t, report.Overrides())
+}
+
 func TestFormatDriftOutput(t *testing.T) {
 	report := &Report{
 		Checked: 5,
@@ -184,6 +387,6 @@ func TestFormatDriftOutput(t *testing.T) {
 	out := report.Format()
 	assert.True(t, strings.HasPrefix(out, "DRIFT:"))
 	assert.Contains(t, out, "claude-test-1")
-	assert.Contains(t, out, "NormaliseModelName")
+	assert.Contains(t, out, "registry.json")
 	assert.Contains(t, out, "make test && ./ccu -check-models")
 }
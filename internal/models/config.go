@@ -5,16 +5,46 @@ import "time"
 // APIConfig holds configuration for the optional embedded HTTP API server
 type APIConfig struct {
 	Enabled      bool
-	Port         int      // default 19840
+	Port         int      // default 19840; 0 = no TCP listener, only the socket
 	BindAddr     string   // default "0.0.0.0"
 	Token        string   // shared secret; empty = no auth
+	TokensFile   string   // JSON file of named, scoped tokens, reloaded when it changes
+	SocketPath   string   // Unix socket also served on, trusted by file permissions; empty = none
+	RateLimit    float64  // requests per second per client; 0 = unlimited
+	RateBurst    int      // requests a client may make at once before RateLimit applies
 	AllowedCIDRs []string // e.g. ["192.168.0.0/24", "10.0.0.1/32"]; empty = allow all
+
+	// TLS; with none of these set the server speaks plain HTTP
+	TLSCert       string // PEM certificate file, used with TLSKey
+	TLSKey        string // PEM private key file
+	TLSSelfSigned bool   // without TLSCert, serve a self-signed certificate kept in ConfigDir
+	TLSClientCA   string // PEM CA bundle; when set, clients must present a certificate it signed
+	ConfigDir     string // ~/.ccu, where the self-signed certificate is kept
+}
+
+// MQTTConfig holds configuration for publishing status to an MQTT broker
+type MQTTConfig struct {
+	Broker          string // e.g. "tcp://homeassistant.local:1883"; empty = don't publish
+	Username        string
+	Password        string
+	ClientID        string // default "ccu-<hostname>-<pid>"
+	TopicPrefix     string // default "ccu"
+	DiscoveryPrefix string // Home Assistant discovery prefix, default "homeassistant"; empty = no discovery
+}
+
+// ModelCheckConfig holds options for the -check-models run
+type ModelCheckConfig struct {
+	Source    string // upstream dataset URL or local file path
+	Format    string // "text" or "json"
+	WritePath string // new pricing file to write upstream rates to; empty = don't write
 }
 
 // Config holds application configuration
 type Config struct {
-	// Data paths
-	DataPath string
+	// DataPaths are the Claude data roots to read, in priority order: a
+	// message logged under several roots is counted from the first. Empty =
+	// discover the standard locations on every load.
+	DataPaths []string
 
 	// Plan configuration
 	Plan           string
@@ -31,15 +61,33 @@ type Config struct {
 
 	// Feature flags
 	ShowWeekly bool
+	Watch      bool // Reload as soon as JSONL files are written, not just per tick
+	ParseCache bool // Keep parsed JSONL in the user cache dir between runs
 
 	// Report mode (non-interactive output to stdout)
 	ReportMode ReportMode
 
 	// CheckModels compares ccu's model tables against upstream rates and exits
 	CheckModels bool
+	ModelCheck  ModelCheckConfig
+
+	// Doctor (`ccu doctor`) reports transcript lines ccu can't parse or
+	// doesn't recognise, then exits
+	Doctor bool
+
+	// ModelsFile is a JSON model registry file merged over the built-in
+	// registry at startup; empty = built-in models only
+	ModelsFile string
+
+	// PricingFile is a JSON pricing overrides file merged over the built-in
+	// rates at startup; empty = built-in rates only
+	PricingFile string
 
 	// API server configuration
 	API APIConfig
+
+	// MQTT publishing configuration
+	MQTT MQTTConfig
 }
 
 // ViewMode represents the display mode
@@ -73,7 +121,7 @@ const (
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
-		DataPath:       "", // Will be set to ~/.claude/projects
+		DataPaths:      nil, // Discovered: CLAUDE_CONFIG_DIR, ~/.config/claude, ~/.claude
 		Plan:           "max5",
 		ViewMode:       ViewModeRealtime,
 		RefreshRate:    30 * time.Second, // Default for JSONL mode, adjusted to 60s for OAuth
@@ -81,6 +129,8 @@ func DefaultConfig() *Config {
 		Theme:          ThemeAuto,
 		HoursBack:      24,
 		ShowWeekly:     true, // Shows estimated weekly usage based on recent activity
+		Watch:          true,
+		ParseCache:     true,
 		CustomToken:    0,
 		CustomCost:     0,
 		CustomMessages: 0,
@@ -88,6 +138,10 @@ func DefaultConfig() *Config {
 			Port:     19840,
 			BindAddr: "0.0.0.0",
 		},
+		MQTT: MQTTConfig{
+			TopicPrefix:     "ccu",
+			DiscoveryPrefix: "homeassistant",
+		},
 	}
 }
 
@@ -1,7 +1,7 @@
 package models
 
 import (
-	"strings"
+	"slices"
 	"time"
 )
 
@@ -11,11 +11,21 @@ type UsageEntry struct {
 	InputTokens         int       `json:"input_tokens"`
 	OutputTokens        int       `json:"output_tokens"`
 	CacheCreationTokens int       `json:"cache_creation_input_tokens"`
-	CacheReadTokens     int       `json:"cache_read_input_tokens"`
-	CostUSD             float64   `json:"cost_usd"`
-	Model               string    `json:"model"`
-	MessageID           string    `json:"message_id"`
-	RequestID           string    `json:"request_id"`
+	// CacheCreation5mTokens and CacheCreation1hTokens break CacheCreationTokens
+	// down by cache TTL. Both are zero in transcripts that predate the breakdown.
+	CacheCreation5mTokens int     `json:"cache_creation_5m_input_tokens"`
+	CacheCreation1hTokens int     `json:"cache_creation_1h_input_tokens"`
+	CacheReadTokens       int     `json:"cache_read_input_tokens"`
+	WebSearchRequests     int     `json:"web_search_requests"` // Server tool calls, billed per request
+	WebFetchRequests      int     `json:"web_fetch_requests"`
+	LongContext           bool    `json:"long_context"` // Prompt exceeded the model's long-context pricing threshold
+	Unpriced              bool    `json:"unpriced"`     // Model had no price; CostUSD is at fallback rates
+	CostUSD               float64 `json:"cost_usd"`
+	Model                 string  `json:"model"`
+	Provider              string  `json:"provider"` // Provider ID from the model registry, e.g. "anthropic"
+	Source                string  `json:"source"`   // Data root the entry was read from
+	MessageID             string  `json:"message_id"`
+	RequestID             string  `json:"request_id"`
 }
 
 // TotalTokens returns the sum of all token types
@@ -23,12 +33,52 @@ func (e *UsageEntry) TotalTokens() int {
 	return e.InputTokens + e.OutputTokens + e.CacheCreationTokens + e.CacheReadTokens
 }
 
+// PromptTokens returns the tokens sent to the model: fresh input plus cache
+// writes and reads. Long-context pricing tiers are selected on this figure.
+func (e *UsageEntry) PromptTokens() int {
+	return e.InputTokens + e.CacheCreationTokens + e.CacheReadTokens
+}
+
 // DisplayTokens returns only input + output tokens (matching Python UI display)
 // Cache tokens are excluded from UI display to match Python implementation
 func (e *UsageEntry) DisplayTokens() int {
 	return e.InputTokens + e.OutputTokens
 }
 
+// CacheCreationSplit returns cache write tokens by TTL. Entries without a
+// breakdown report the whole aggregate as 5-minute writes, which is the default
+// TTL and how they were billed. Any part of the aggregate the breakdown doesn't
+// account for is also treated as 5-minute.
+func (e *UsageEntry) CacheCreationSplit() (fiveMin, oneHour int) {
+	oneHour = e.CacheCreation1hTokens
+	fiveMin = max(e.CacheCreation5mTokens, e.CacheCreationTokens-oneHour)
+	return fiveMin, oneHour
+}
+
+// IsAnthropic reports whether the entry was served by Anthropic and so counts
+// towards plan limits. Entries without a provider predate tagging and were
+// all Claude usage.
+func (e *UsageEntry) IsAnthropic() bool {
+	return e.Provider == "" || e.Provider == ProviderAnthropic
+}
+
+// AnthropicEntries returns the entries that count towards plan limits,
+// dropping usage proxied to other providers. The input is returned as-is
+// when there is nothing to drop.
+func AnthropicEntries(entries []UsageEntry) []UsageEntry {
+	i := slices.IndexFunc(entries, func(e UsageEntry) bool { return !e.IsAnthropic() })
+	if i < 0 {
+		return entries
+	}
+	result := slices.Clone(entries[:i])
+	for _, e := range entries[i+1:] {
+		if e.IsAnthropic() {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
 // Hash returns the deduplication key for this entry. The message_id and
 // request_id pair is already unique, so the key is a plain concatenation
 // rather than a cryptographic hash - it only ever feeds an in-memory map.
@@ -36,63 +86,14 @@ func (e *UsageEntry) Hash() string {
 	return e.MessageID + ":" + e.RequestID
 }
 
-// NormaliseModelName standardises model names for consistent grouping
+// NormaliseModelName standardises model names for consistent grouping,
+// resolving dated releases and aliases to a canonical ID via the model
+// registry. Names the registry doesn't know are returned unchanged.
 func NormaliseModelName(model string) string {
-	// Convert to lowercase for case-insensitive matching
-	modelLower := strings.ToLower(model)
-
-	// Map various model names to standard names
-	// Check for version-specific patterns first (newest → oldest)
-	switch {
-	case strings.Contains(modelLower, "fable"):
-		return "claude-fable-5"
-	case strings.Contains(modelLower, "mythos"):
-		return "claude-mythos-5"
-	case strings.Contains(modelLower, "opus"):
-		if strings.Contains(modelLower, "4-8") || strings.Contains(modelLower, "4.8") {
-			return "claude-opus-4-8"
-		}
-		if strings.Contains(modelLower, "4-7") || strings.Contains(modelLower, "4.7") {
-			return "claude-opus-4-7"
-		}
-		if strings.Contains(modelLower, "4-6") || strings.Contains(modelLower, "4.6") {
-			return "claude-opus-4-6"
-		}
-		if strings.Contains(modelLower, "4-5") || strings.Contains(modelLower, "4.5") {
-			return "claude-opus-4-5"
-		}
-		if strings.Contains(modelLower, "4-1") || strings.Contains(modelLower, "4.1") {
-			return "claude-opus-4-1"
-		}
-		if strings.Contains(modelLower, "3") {
-			return "claude-3-opus"
-		}
-		return "claude-opus-4"
-	case strings.Contains(modelLower, "sonnet"):
-		if strings.Contains(modelLower, "4-6") || strings.Contains(modelLower, "4.6") {
-			return "claude-sonnet-4-6"
-		}
-		if strings.Contains(modelLower, "4-5") || strings.Contains(modelLower, "4.5") {
-			return "claude-sonnet-4-5"
-		}
-		if strings.Contains(modelLower, "3-5") || strings.Contains(modelLower, "3.5") {
-			return "claude-3-5-sonnet"
-		}
-		if strings.Contains(modelLower, "4") {
-			return "claude-sonnet-4"
-		}
-		return "claude-3-sonnet"
-	case strings.Contains(modelLower, "haiku"):
-		if strings.Contains(modelLower, "4-5") || strings.Contains(modelLower, "4.5") {
-			return "claude-haiku-4-5"
-		}
-		if strings.Contains(modelLower, "3-5") || strings.Contains(modelLower, "3.5") {
-			return "claude-3-5-haiku"
-		}
-		return "claude-3-haiku"
-	default:
-		return model
+	if m, ok := LookupModel(model); ok {
+		return m.ID
 	}
+	return model
 }
 
 // ModelStats tracks per-model statistics
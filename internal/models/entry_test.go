@@ -173,3 +173,62 @@ func TestUsageEntryHash(t *testing.T) {
 	assert.Equal(t, a.Hash(), b.Hash())
 	assert.NotEqual(t, a.Hash(), c.Hash())
 }
+
+func TestCacheCreationSplit(t *testing.T) {
+	tests := []struct {
+		name        string
+		entry       UsageEntry
+		wantFiveMin int
+		wantOneHour int
+	}{
+		{
+			name:        "no breakdown counts aggregate as 5-minute",
+			entry:       UsageEntry{CacheCreationTokens: 1000},
+			wantFiveMin: 1000,
+		},
+		{
+			name:        "full breakdown",
+			entry:       UsageEntry{CacheCreationTokens: 1000, CacheCreation5mTokens: 400, CacheCreation1hTokens: 600},
+			wantFiveMin: 400,
+			wantOneHour: 600,
+		},
+		{
+			name:        "only 1-hour reported, remainder is 5-minute",
+			entry:       UsageEntry{CacheCreationTokens: 1000, CacheCreation1hTokens: 600},
+			wantFiveMin: 400,
+			wantOneHour: 600,
+		},
+		{
+			name: "no cache writes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fiveMin, oneHour := tt.entry.CacheCreationSplit()
+			assert.Equal(t, tt.wantFiveMin, fiveMin)
+			assert.Equal(t, tt.wantOneHour, oneHour)
+		})
+	}
+}
+
+func TestAnthropicEntries(t *testing.T) {
+	entries := []UsageEntry{
+		{MessageID: "a", Provider: ProviderAnthropic},
+		{MessageID: "b", Provider: "openai"},
+		{MessageID: "c"}, // untagged entries predate providers and are Claude usage
+		{MessageID: "d", Provider: ProviderOther},
+	}
+
+	got := AnthropicEntries(entries)
+	ids := make([]string, 0, len(got))
+	for _, e := range got {
+		ids = append(ids, e.MessageID)
+	}
+	assert.Equal(t, []string{"a", "c"}, ids)
+	assert.Len(t, entries, 4, "input must not be modified")
+
+	// Nothing to drop returns the same slice
+	claude := entries[:1]
+	assert.Same(t, &claude[0], &AnthropicEntries(claude)[0])
+}
@@ -0,0 +1,383 @@
+package models
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Family groups models for display, e.g. every Opus release
+type Family struct {
+	ID     string `json:"id"`     // lowercase key, matched as a substring of model names
+	Name   string `json:"name"`   // display name, e.g. "Opus"
+	Colour string `json:"colour"` // #RRGGBB; empty = the UI's unknown-model colour
+}
+
+// Provider identifies who serves a model. Claude Code sessions proxied through
+// a gateway can log other vendors' models, which are not plan usage.
+type Provider struct {
+	ID       string   `json:"id"`       // lowercase key stored on usage entries, e.g. "openai"
+	Name     string   `json:"name"`     // display name, e.g. "OpenAI"
+	Prefixes []string `json:"prefixes"` // matched against the start of the name's last path segment
+}
+
+// ProviderAnthropic is the provider of every registry model that doesn't name
+// another, and of any name that resolves to a Claude family
+const ProviderAnthropic = "anthropic"
+
+// ProviderOther is reported for names no provider claims
+const ProviderOther = "other"
+
+// SyntheticModel is the model Claude Code records for messages it generates
+// locally (e.g. interruptions and errors); they never reach an API
+const SyntheticModel = "<synthetic>"
+
+// ModelInfo describes one canonical model. Names resolve to it by exact ID,
+// then by alias, then by pattern: each pattern is space-separated substrings
+// that must all appear in the lowercased name, e.g. "opus 4-6".
+type ModelInfo struct {
+	ID          string   `json:"id"`
+	Family      string   `json:"family,omitempty"`   // required for Anthropic models
+	Provider    string   `json:"provider,omitempty"` // defaults to ProviderAnthropic
+	DisplayName string   `json:"display_name"`
+	PricingKey  string   `json:"pricing_key,omitempty"` // defaults to ID
+	Aliases     []string `json:"aliases,omitempty"`
+	Patterns    []string `json:"patterns,omitempty"`
+}
+
+// Registry is the model table. Order matters: the first family or model to
+// match a name wins, so specific versions must precede their family catch-all.
+type Registry struct {
+	Families  []Family    `json:"families"`
+	Models    []ModelInfo `json:"models"`
+	Providers []Provider  `json:"providers"`
+}
+
+//go:embed registry.json
+var defaultRegistryJSON []byte
+
+var (
+	registryMu     sync.RWMutex
+	activeRegistry *Registry // built-in registry plus any loaded user file
+)
+
+func init() {
+	r, err := parseRegistry(defaultRegistryJSON)
+	if err != nil {
+		panic(fmt.Sprintf("models: built-in registry invalid: %v", err))
+	}
+	activeRegistry = r
+}
+
+// currentRegistry returns the active registry. Registries are never mutated
+// once active, so callers may read it without holding the lock.
+func currentRegistry() *Registry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return activeRegistry
+}
+
+// Fingerprint identifies the active registry. Providers and pricing keys
+// resolved with one fingerprint may differ once it changes.
+func Fingerprint() uint64 {
+	h := fnv.New64a()
+	_ = json.NewEncoder(h).Encode(currentRegistry())
+	return h.Sum64()
+}
+
+// LookupModel resolves a model name from usage data or the API to its
+// registry entry
+func LookupModel(name string) (ModelInfo, bool) {
+	return currentRegistry().lookup(name)
+}
+
+// LookupModelExact resolves a name by ID or alias only, without patterns
+func LookupModelExact(name string) (ModelInfo, bool) {
+	return currentRegistry().lookupExact(strings.ToLower(name))
+}
+
+// PricingKey returns the key a model is priced under. Names the registry
+// doesn't know are their own key.
+func PricingKey(name string) string {
+	m, ok := LookupModel(name)
+	if !ok {
+		return name
+	}
+	if m.PricingKey != "" {
+		return m.PricingKey
+	}
+	return m.ID
+}
+
+// FamilyOf returns the family of a model or display name: the resolved
+// model's family if the registry knows the name, otherwise the first family
+// whose ID appears in it (so "Haiku 4.5" and unreleased versions still match).
+func FamilyOf(name string) (Family, bool) {
+	r := currentRegistry()
+	if m, ok := r.lookup(name); ok {
+		return r.family(m.Family)
+	}
+	lower := strings.ToLower(name)
+	for _, f := range r.Families {
+		if strings.Contains(lower, f.ID) {
+			return f, true
+		}
+	}
+	return Family{}, false
+}
+
+// ProviderOf returns the provider serving a model: the registry model's
+// provider, Anthropic for any name in a Claude family, otherwise the first
+// provider with a matching prefix. Names nothing claims are ProviderOther.
+func ProviderOf(name string) Provider {
+	r := currentRegistry()
+	id := ""
+	if m, ok := r.lookup(name); ok {
+		id = m.provider()
+	} else if _, ok := FamilyOf(name); ok {
+		id = ProviderAnthropic
+	} else {
+		lower := strings.ToLower(name)
+		lower = lower[strings.LastIndex(lower, "/")+1:]
+		for _, p := range r.Providers {
+			if slices.ContainsFunc(p.Prefixes, func(prefix string) bool { return strings.HasPrefix(lower, prefix) }) {
+				return p
+			}
+		}
+		return Provider{ID: ProviderOther, Name: "Other"}
+	}
+	if p, ok := r.provider(id); ok {
+		return p
+	}
+	return Provider{ID: id, Name: id}
+}
+
+// IsAnthropic reports whether a model is served by Anthropic, so its usage
+// counts towards plan limits
+func IsAnthropic(name string) bool {
+	return ProviderOf(name).ID == ProviderAnthropic
+}
+
+// Families returns the registry's families in priority order
+func Families() []Family {
+	return slices.Clone(currentRegistry().Families)
+}
+
+func (r *Registry) lookup(name string) (ModelInfo, bool) {
+	lower := strings.ToLower(name)
+	if m, ok := r.lookupExact(lower); ok {
+		return m, true
+	}
+	for _, m := range r.Models {
+		for _, pattern := range m.Patterns {
+			if matchesPattern(lower, pattern) {
+				return m, true
+			}
+		}
+	}
+	return ModelInfo{}, false
+}
+
+func (r *Registry) lookupExact(lower string) (ModelInfo, bool) {
+	for _, m := range r.Models {
+		if m.ID == lower || slices.Contains(m.Aliases, lower) {
+			return m, true
+		}
+	}
+	return ModelInfo{}, false
+}
+
+func (r *Registry) provider(id string) (Provider, bool) {
+	for _, p := range r.Providers {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Provider{}, false
+}
+
+// provider returns the model's provider ID, defaulting to Anthropic
+func (m ModelInfo) provider() string {
+	if m.Provider == "" {
+		return ProviderAnthropic
+	}
+	return m.Provider
+}
+
+func (r *Registry) family(id string) (Family, bool) {
+	for _, f := range r.Families {
+		if f.ID == id {
+			return f, true
+		}
+	}
+	return Family{}, false
+}
+
+// matchesPattern reports whether every space-separated part of pattern
+// appears in name
+func matchesPattern(name, pattern string) bool {
+	for part := range strings.FieldsSeq(pattern) {
+		if !strings.Contains(name, part) {
+			return false
+		}
+	}
+	return true
+}
+
+var colourPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// validate checks that names are lowercase and unique and every model's
+// family and provider exist. IDs and aliases are compared against lowercased names, so
+// mixed case would never match.
+func (r *Registry) validate() error {
+	families := make(map[string]bool, len(r.Families))
+	for _, f := range r.Families {
+		switch {
+		case f.ID == "" || f.ID != strings.ToLower(f.ID):
+			return fmt.Errorf("family id %q must be non-empty lowercase", f.ID)
+		case families[f.ID]:
+			return fmt.Errorf("duplicate family %q", f.ID)
+		case f.Name == "":
+			return fmt.Errorf("family %q has no name", f.ID)
+		case f.Colour != "" && !colourPattern.MatchString(f.Colour):
+			return fmt.Errorf("family %q: colour %q must be #RRGGBB", f.ID, f.Colour)
+		}
+		families[f.ID] = true
+	}
+
+	providers := make(map[string]bool, len(r.Providers))
+	for _, p := range r.Providers {
+		switch {
+		case p.ID == "" || p.ID != strings.ToLower(p.ID):
+			return fmt.Errorf("provider id %q must be non-empty lowercase", p.ID)
+		case p.ID == ProviderOther:
+			return fmt.Errorf("provider id %q is reserved", p.ID)
+		case providers[p.ID]:
+			return fmt.Errorf("duplicate provider %q", p.ID)
+		case p.Name == "":
+			return fmt.Errorf("provider %q has no name", p.ID)
+		}
+		for _, prefix := range p.Prefixes {
+			if prefix == "" || prefix != strings.ToLower(prefix) {
+				return fmt.Errorf("provider %q: prefix %q must be non-empty lowercase", p.ID, prefix)
+			}
+		}
+		providers[p.ID] = true
+	}
+	if !providers[ProviderAnthropic] {
+		return fmt.Errorf("provider %q is required", ProviderAnthropic)
+	}
+
+	names := make(map[string]string)
+	for _, m := range r.Models {
+		if m.ID == "" || m.ID != strings.ToLower(m.ID) {
+			return fmt.Errorf("model id %q must be non-empty lowercase", m.ID)
+		}
+		if !providers[m.provider()] {
+			return fmt.Errorf("model %q: unknown provider %q", m.ID, m.Provider)
+		}
+		// Only Claude models need a family; other vendors' models have no
+		// colour or weekly limit of their own
+		if (m.Family != "" || m.provider() == ProviderAnthropic) && !families[m.Family] {
+			return fmt.Errorf("model %q: unknown family %q", m.ID, m.Family)
+		}
+		for _, name := range append([]string{m.ID}, m.Aliases...) {
+			if name != strings.ToLower(name) {
+				return fmt.Errorf("model %q: alias %q must be lowercase", m.ID, name)
+			}
+			if other, dup := names[name]; dup {
+				return fmt.Errorf("model %q: %q is already used by %q", m.ID, name, other)
+			}
+			names[name] = m.ID
+		}
+		for _, pattern := range m.Patterns {
+			if strings.TrimSpace(pattern) == "" {
+				return fmt.Errorf("model %q has an empty pattern", m.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func parseRegistry(data []byte) (*Registry, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var r Registry
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// LoadRegistryFile merges a user registry file over the built-in one and makes
+// the result active. User families and models take priority: they replace any
+// built-in entry with the same ID and are matched before the built-ins, so a
+// new release can be mapped before ccu knows about it. On any error the active
+// registry is left unchanged.
+func LoadRegistryFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading models file: %w", err)
+	}
+
+	merged, err := mergeRegistry(data)
+	if err != nil {
+		return fmt.Errorf("models file %s: %w", path, err)
+	}
+
+	registryMu.Lock()
+	activeRegistry = merged
+	registryMu.Unlock()
+	return nil
+}
+
+// mergeRegistry merges user registry JSON over the built-in registry
+func mergeRegistry(data []byte) (*Registry, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var user Registry
+	if err := dec.Decode(&user); err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+
+	builtin, err := parseRegistry(defaultRegistryJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &Registry{
+		Families:  slices.Clone(user.Families),
+		Models:    slices.Clone(user.Models),
+		Providers: slices.Clone(user.Providers),
+	}
+	for _, f := range builtin.Families {
+		if !slices.ContainsFunc(user.Families, func(u Family) bool { return u.ID == f.ID }) {
+			merged.Families = append(merged.Families, f)
+		}
+	}
+	for _, m := range builtin.Models {
+		if !slices.ContainsFunc(user.Models, func(u ModelInfo) bool { return u.ID == m.ID }) {
+			merged.Models = append(merged.Models, m)
+		}
+	}
+	for _, p := range builtin.Providers {
+		if !slices.ContainsFunc(user.Providers, func(u Provider) bool { return u.ID == p.ID }) {
+			merged.Providers = append(merged.Providers, p)
+		}
+	}
+
+	if err := merged.validate(); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
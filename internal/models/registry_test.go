@@ -0,0 +1,172 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadTestRegistry writes content to a temp models file and loads it,
+// restoring the built-in registry when the test ends
+func loadTestRegistry(t *testing.T, content string) error {
+	t.Helper()
+	builtin := currentRegistry()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		activeRegistry = builtin
+		registryMu.Unlock()
+	})
+	path := filepath.Join(t.TempDir(), "models.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return LoadRegistryFile(path)
+}
+
+func TestLookupModel(t *testing.T) {
+	m, ok := LookupModel("claude-opus-4-6-20260101")
+	require.True(t, ok)
+	assert.Equal(t, "claude-opus-4-6", m.ID)
+	assert.Equal(t, "opus", m.Family)
+	assert.Equal(t, "Opus 4.6", m.DisplayName)
+
+	_, ok = LookupModel("gpt-4")
+	assert.False(t, ok)
+
+	// Exact lookups ignore patterns
+	_, ok = LookupModelExact("claude-opus-4-6-20260101")
+	assert.False(t, ok)
+	m, ok = LookupModelExact("Claude-Opus-4-6")
+	require.True(t, ok)
+	assert.Equal(t, "claude-opus-4-6", m.ID)
+}
+
+func TestPricingKey(t *testing.T) {
+	assert.Equal(t, "claude-opus-4-8", PricingKey("claude-opus-4-8-20260301"))
+	assert.Equal(t, "claude-fable-5", PricingKey("claude-mythos-5"))
+	assert.Equal(t, "gpt-4", PricingKey("gpt-4"))
+}
+
+func TestFamilyOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"claude-sonnet-4-6", "Sonnet", true},
+		{"Haiku 4.5", "Haiku", true},
+		{"claude-opus-5-20270101", "Opus", true}, // unreleased version still has a family
+		{"Fable", "Fable", true},
+		{"Nimbus", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			family, ok := FamilyOf(tt.name)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, family.Name)
+		})
+	}
+}
+
+func TestProviderOf(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"claude-sonnet-4-6", ProviderAnthropic},
+		{"claude-zenith-1", ProviderAnthropic},                   // unreleased Claude model
+		{"us.anthropic.claude-opus-4-8-v1:0", ProviderAnthropic}, // Bedrock ID
+		{"Opus 5", ProviderAnthropic},
+		{"gpt-4o-2024-08-06", "openai"},
+		{"o3-mini", "openai"},
+		{"openrouter/google/gemini-2.5-pro", "google"},
+		{"deepseek-chat", "deepseek"},
+		{"nimbus-1", ProviderOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ProviderOf(tt.name).ID)
+		})
+	}
+	assert.Equal(t, "OpenAI", ProviderOf("gpt-4").Name)
+	assert.True(t, IsAnthropic("claude-haiku-4-5"))
+	assert.False(t, IsAnthropic("gpt-4"))
+}
+
+func TestLoadRegistryFileProviders(t *testing.T) {
+	require.NoError(t, loadTestRegistry(t, `{
+		"providers": [{"id": "nimbus", "name": "Nimbus Labs", "prefixes": ["nimbus"]}],
+		"models": [{"id": "gpt-4o", "provider": "openai", "display_name": "GPT-4o", "patterns": ["gpt-4o"]}]
+	}`))
+
+	assert.Equal(t, "Nimbus Labs", ProviderOf("nimbus-1").Name)
+	// Third-party models need no family and resolve dated IDs to one key
+	assert.Equal(t, "gpt-4o", PricingKey("gpt-4o-2024-08-06"))
+	assert.Equal(t, "openai", ProviderOf("gpt-4o-2024-08-06").ID)
+	_, ok := FamilyOf("gpt-4o")
+	assert.False(t, ok)
+}
+
+func TestLoadRegistryFile(t *testing.T) {
+	require.NoError(t, loadTestRegistry(t, `{
+		"families": [{"id": "zenith", "name": "Zenith", "colour": "#123456"}],
+		"models": [
+			{"id": "claude-opus-4-9", "family": "opus", "display_name": "Opus 4.9", "patterns": ["opus 4-9", "opus 4.9"]},
+			{"id": "claude-zenith-1", "family": "zenith", "display_name": "Zenith 1", "aliases": ["zen"], "pricing_key": "claude-opus-4-8"}
+		]
+	}`))
+
+	// User models take priority over the Opus catch-all
+	assert.Equal(t, "claude-opus-4-9", NormaliseModelName("claude-opus-4-9-20270101"))
+	assert.Equal(t, "claude-opus-4", NormaliseModelName("claude-opus-4-20250514"))
+
+	assert.Equal(t, "claude-zenith-1", NormaliseModelName("ZEN"))
+	assert.Equal(t, "claude-opus-4-8", PricingKey("claude-zenith-1"))
+	family, ok := FamilyOf("claude-zenith-1")
+	require.True(t, ok)
+	assert.Equal(t, "#123456", family.Colour)
+
+	// Built-in families are kept after the user's
+	assert.Equal(t, "zenith", Families()[0].ID)
+	assert.Len(t, Families(), 6)
+}
+
+func TestFingerprint(t *testing.T) {
+	builtin := Fingerprint()
+	assert.Equal(t, builtin, Fingerprint(), "fingerprint must be stable")
+
+	require.NoError(t, loadTestRegistry(t, `{"providers": [{"id": "acme", "name": "Acme", "prefixes": ["acme-"]}]}`))
+	assert.NotEqual(t, builtin, Fingerprint())
+}
+
+func TestLoadRegistryFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"invalid json", `{`, "parsing"},
+		{"unknown field", `{"models": [{"id": "x", "family": "opus", "pattern": ["x"]}]}`, "unknown field"},
+		{"unknown family", `{"models": [{"id": "x", "family": "nimbus"}]}`, `unknown family "nimbus"`},
+		{"uppercase id", `{"models": [{"id": "Claude-X", "family": "opus"}]}`, "lowercase"},
+		{"alias clash", `{"models": [{"id": "x", "family": "opus", "aliases": ["claude-opus-4-8"]}]}`, "already used"},
+		{"bad colour", `{"families": [{"id": "x", "name": "X", "colour": "red"}]}`, "#RRGGBB"},
+		{"unknown provider", `{"models": [{"id": "x", "family": "opus", "provider": "nimbus"}]}`, `unknown provider "nimbus"`},
+		{"claude model without family", `{"models": [{"id": "x"}]}`, `unknown family ""`},
+		{"reserved provider", `{"providers": [{"id": "other", "name": "Other"}]}`, "reserved"},
+		{"uppercase prefix", `{"providers": [{"id": "nimbus", "name": "Nimbus", "prefixes": ["Nimbus"]}]}`, "lowercase"},
+		{"empty pattern", `{"models": [{"id": "x", "family": "opus", "patterns": [" "]}]}`, "empty pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := loadTestRegistry(t, tt.content)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+
+			// A rejected file leaves the built-in registry active
+			assert.Equal(t, "claude-opus-4", NormaliseModelName("claude-opus-4-9"))
+		})
+	}
+}
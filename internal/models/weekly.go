@@ -1,7 +1,5 @@
 package models
 
-import "strings"
-
 // WeeklyLimits defines weekly limits by plan
 type WeeklyLimits struct {
 	SonnetHours float64
@@ -41,10 +39,11 @@ func GetWeeklyLimits(plan string) WeeklyLimits {
 // callers should present the raw utilisation percentage rather than invent one.
 func WeeklyHoursForModel(plan, displayName string) float64 {
 	limits := GetWeeklyLimits(plan)
-	switch {
-	case strings.Contains(strings.ToLower(displayName), "sonnet"):
+	family, _ := FamilyOf(displayName)
+	switch family.ID {
+	case "sonnet":
 		return limits.SonnetHours
-	case strings.Contains(strings.ToLower(displayName), "opus"):
+	case "opus":
 		return limits.OpusHours
 	default:
 		return 0
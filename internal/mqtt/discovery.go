@@ -0,0 +1,202 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/sammcj/ccu/internal/api"
+)
+
+// Availability payloads, which are also Home Assistant's defaults
+const (
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+)
+
+// payloadUnknown sets a Home Assistant sensor's state to unknown, for values
+// ccu doesn't have (no active session, or no OAuth for weekly figures)
+const payloadUnknown = "None"
+
+// entity is one sensor: a retained state topic and, when discovery is on, the
+// Home Assistant config describing it
+type entity struct {
+	Name        string
+	StateTopic  string
+	Unit        string
+	DeviceClass string
+	StateClass  string
+	Icon        string
+
+	value string
+}
+
+// discoveryPayload is a Home Assistant MQTT discovery config for a sensor
+type discoveryPayload struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	StateTopic        string          `json:"state_topic"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	Unit              string          `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string          `json:"device_class,omitempty"`
+	StateClass        string          `json:"state_class,omitempty"`
+	Icon              string          `json:"icon,omitempty"`
+	Device            discoveryDevice `json:"device"`
+}
+
+// discoveryDevice groups every ccu sensor under one Home Assistant device
+type discoveryDevice struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+	Model       string   `json:"model"`
+	SWVersion   string   `json:"sw_version,omitempty"`
+}
+
+// entitiesFor maps status onto sensors keyed by object ID. Each scoped weekly
+// limit gets its own pair, keyed by oauth.Limit.Key, so they come and go as
+// Anthropic adds and drops limits.
+func (p *Publisher) entitiesFor(status *api.StatusResponse) map[string]entity {
+	prefix := p.cfg.TopicPrefix
+	entities := make(map[string]entity)
+	add := func(id string, e entity) {
+		if e.value == "" {
+			e.value = payloadUnknown
+		}
+		entities[id] = e
+	}
+
+	// Missing sections are zero values, reported as unknown
+	var session api.SessionSection
+	haveSession := status.Session != nil
+	if haveSession {
+		session = *status.Session
+	}
+	add("session_utilisation", percentEntity("Session usage", prefix+"/session/utilisation_pct", "mdi:timer-sand",
+		haveSession, session.UtilisationPct))
+	add("session_resets_at", timestampEntity("Session reset", prefix+"/session/resets_at", session.ResetsAt))
+	add("session_cost", entity{
+		Name:        "Session cost",
+		StateTopic:  prefix + "/session/cost_usd",
+		Unit:        "USD",
+		DeviceClass: "monetary",
+		value:       formatIf(haveSession, session.CostUSD, 2),
+	})
+
+	var all api.WeeklyAllSection
+	haveWeekly := status.Weekly != nil && status.Weekly.AllModels != nil
+	if haveWeekly {
+		all = *status.Weekly.AllModels
+	}
+	add("weekly_utilisation", percentEntity("Weekly usage", prefix+"/weekly/utilisation_pct", "mdi:calendar-week",
+		haveWeekly, all.UtilisationPct))
+	add("weekly_resets_at", timestampEntity("Weekly reset", prefix+"/weekly/resets_at", all.ResetsAt))
+
+	if status.Weekly != nil {
+		for key, section := range status.Weekly.Scoped {
+			slug := slugify(key)
+			label := section.Model
+			if section.Surface != "" {
+				label += " (" + section.Surface + ")"
+			}
+			topic := prefix + "/weekly/scoped/" + slug
+			add("weekly_"+slug+"_utilisation", percentEntity(label+" weekly usage", topic+"/utilisation_pct", "mdi:calendar-week",
+				true, section.UtilisationPct))
+			add("weekly_"+slug+"_resets_at", timestampEntity(label+" weekly reset", topic+"/resets_at", section.ResetsAt))
+		}
+	}
+
+	var burn api.BurnRateSection
+	haveBurn := status.BurnRate != nil
+	if haveBurn {
+		burn = *status.BurnRate
+	}
+	add("burn_rate", entity{
+		Name:       "Burn rate",
+		StateTopic: prefix + "/burn_rate/cost_per_hour_usd",
+		Unit:       "USD/h",
+		StateClass: "measurement",
+		Icon:       "mdi:fire",
+		value:      formatIf(haveBurn, burn.CostPerHourUSD, 2),
+	})
+	add("token_rate", entity{
+		Name:       "Token rate",
+		StateTopic: prefix + "/burn_rate/tokens_per_min",
+		Unit:       "tokens/min",
+		StateClass: "measurement",
+		Icon:       "mdi:speedometer",
+		value:      formatIf(haveBurn, burn.TokensPerMin, 0),
+	})
+	return entities
+}
+
+func percentEntity(name, topic, icon string, known bool, pct float64) entity {
+	return entity{
+		Name:       name,
+		StateTopic: topic,
+		Unit:       "%",
+		StateClass: "measurement",
+		Icon:       icon,
+		value:      formatIf(known, pct, 1),
+	}
+}
+
+// timestampEntity is a reset time, which Home Assistant shows as a countdown
+func timestampEntity(name, topic, rfc3339 string) entity {
+	return entity{Name: name, StateTopic: topic, DeviceClass: "timestamp", value: rfc3339}
+}
+
+// formatIf formats v to prec decimal places, or returns "" (unknown) when it
+// isn't known. Rounding also saves republishing on insignificant changes.
+func formatIf(known bool, v float64, prec int) string {
+	if !known {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', prec, 64)
+}
+
+// discoveryConfig renders e's Home Assistant discovery config
+func (p *Publisher) discoveryConfig(id string, e entity) string {
+	node := p.nodeID()
+	data, _ := json.Marshal(discoveryPayload{
+		Name:              e.Name,
+		UniqueID:          node + "_" + id,
+		StateTopic:        e.StateTopic,
+		AvailabilityTopic: p.availabilityTopic(),
+		Unit:              e.Unit,
+		DeviceClass:       e.DeviceClass,
+		StateClass:        e.StateClass,
+		Icon:              e.Icon,
+		Device: discoveryDevice{
+			Identifiers: []string{node},
+			Name:        "Claude Code Usage",
+			Model:       "ccu",
+			SWVersion:   p.version,
+		},
+	})
+	return string(data)
+}
+
+// configTopic is where Home Assistant looks for entity id's config
+func (p *Publisher) configTopic(id string) string {
+	return p.cfg.DiscoveryPrefix + "/sensor/" + p.nodeID() + "/" + id + "/config"
+}
+
+// nodeID identifies this ccu to Home Assistant. It follows the topic prefix,
+// so ccu on several machines with their own prefixes are separate devices.
+func (p *Publisher) nodeID() string {
+	return slugify(p.cfg.TopicPrefix)
+}
+
+// slugify lowercases s and replaces anything but letters and digits with
+// underscores, for topic levels and IDs (e.g. "fable/web" -> "fable_web")
+func slugify(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,104 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sammcj/ccu/internal/api"
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig(broker string) models.MQTTConfig {
+	return models.MQTTConfig{Broker: broker, ClientID: "ccu-test", TopicPrefix: "ccu", DiscoveryPrefix: "homeassistant"}
+}
+
+// testStatus has every section, and scoped weekly limits for Fable on every
+// surface and on the web alone
+func testStatus() api.StatusResponse {
+	return api.StatusResponse{
+		SchemaVersion: api.SchemaVersion,
+		Session: &api.SessionSection{
+			UtilisationPct: 42.5,
+			ResetsAt:       "2026-02-18T15:00:00Z",
+			CostUSD:        1.234,
+		},
+		Weekly: &api.WeeklySection{
+			AllModels: &api.WeeklyAllSection{UtilisationPct: 61, ResetsAt: "2026-02-20T09:00:00Z"},
+			Scoped: map[string]*api.WeeklyModelSection{
+				"fable":     {Model: "Fable", UtilisationPct: 12.34, ResetsAt: "2026-02-21T09:00:00Z"},
+				"fable/web": {Model: "Fable", Surface: "web", UtilisationPct: 80},
+			},
+		},
+		BurnRate: &api.BurnRateSection{TokensPerMin: 1234.6, CostPerHourUSD: 2.881},
+	}
+}
+
+func TestEntitiesFor(t *testing.T) {
+	p := New(testConfig("tcp://localhost:1883"), "1.2.3")
+	status := testStatus()
+
+	values := make(map[string]string)
+	for _, e := range p.entitiesFor(&status) {
+		values[e.StateTopic] = e.value
+	}
+	assert.Equal(t, map[string]string{
+		"ccu/session/utilisation_pct":                 "42.5",
+		"ccu/session/resets_at":                       "2026-02-18T15:00:00Z",
+		"ccu/session/cost_usd":                        "1.23",
+		"ccu/weekly/utilisation_pct":                  "61.0",
+		"ccu/weekly/resets_at":                        "2026-02-20T09:00:00Z",
+		"ccu/weekly/scoped/fable/utilisation_pct":     "12.3",
+		"ccu/weekly/scoped/fable/resets_at":           "2026-02-21T09:00:00Z",
+		"ccu/weekly/scoped/fable_web/utilisation_pct": "80.0",
+		"ccu/weekly/scoped/fable_web/resets_at":       payloadUnknown,
+		"ccu/burn_rate/cost_per_hour_usd":             "2.88",
+		"ccu/burn_rate/tokens_per_min":                "1235",
+	}, values)
+
+	// Without OAuth or an active session those sensors are unknown, not zero,
+	// and there are no scoped limits
+	entities := p.entitiesFor(&api.StatusResponse{})
+	assert.Len(t, entities, 7)
+	for id, e := range entities {
+		assert.Equal(t, payloadUnknown, e.value, id)
+	}
+}
+
+func TestDiscoveryConfig(t *testing.T) {
+	p := New(testConfig("tcp://localhost:1883"), "1.2.3")
+	status := testStatus()
+	entities := p.entitiesFor(&status)
+
+	id := "weekly_fable_web_utilisation"
+	assert.Equal(t, "homeassistant/sensor/ccu/"+id+"/config", p.configTopic(id))
+
+	var cfg map[string]any
+	require.NoError(t, json.Unmarshal([]byte(p.discoveryConfig(id, entities[id])), &cfg))
+	assert.Equal(t, map[string]any{
+		"name":                "Fable (web) weekly usage",
+		"unique_id":           "ccu_weekly_fable_web_utilisation",
+		"state_topic":         "ccu/weekly/scoped/fable_web/utilisation_pct",
+		"availability_topic":  "ccu/availability",
+		"unit_of_measurement": "%",
+		"state_class":         "measurement",
+		"icon":                "mdi:calendar-week",
+		"device": map[string]any{
+			"identifiers": []any{"ccu"},
+			"name":        "Claude Code Usage",
+			"model":       "ccu",
+			"sw_version":  "1.2.3",
+		},
+	}, cfg)
+
+	require.NoError(t, json.Unmarshal([]byte(p.discoveryConfig("session_resets_at", entities["session_resets_at"])), &cfg))
+	assert.Equal(t, "timestamp", cfg["device_class"])
+}
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "fable", slugify("fable"))
+	assert.Equal(t, "fable_web", slugify("fable/web"))
+	assert.Equal(t, "home_ccu_laptop", slugify("home/ccu-Laptop"))
+	assert.Equal(t, "opus_4_6", slugify("opus 4.6"))
+}
@@ -0,0 +1,240 @@
+// Package mqtt publishes the API's status response to an MQTT broker as
+// retained topics, with Home Assistant discovery configs so each value shows up
+// as a sensor without hand-written YAML.
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sammcj/ccu/internal/api"
+	"github.com/sammcj/ccu/internal/models"
+)
+
+// publishTimeout bounds how long a publish waits for the broker's ack before
+// the value is retried on the next update
+const publishTimeout = 5 * time.Second
+
+// Publisher pushes status updates to an MQTT broker. Updates are handed to a
+// background goroutine, so Update never blocks the UI on the network.
+type Publisher struct {
+	cfg     models.MQTTConfig
+	version string
+	client  paho.Client
+
+	mu     sync.Mutex
+	latest *api.StatusResponse
+	// Topics the broker holds a retained message on, whether sent by this
+	// process or found on connect from an earlier run, so ones the status no
+	// longer has can be cleared
+	onBroker map[string]bool
+
+	updates chan struct{} // a newer status is in latest, or a retained topic was found
+	resend  chan struct{} // (re)connected, or Home Assistant restarted
+
+	// Owned by the run goroutine: the payload last acked on each topic
+	sent map[string]string
+
+	done chan struct{}
+}
+
+// New creates a publisher for cfg. version is reported as the Home Assistant
+// device's software version.
+func New(cfg models.MQTTConfig, version string) *Publisher {
+	if cfg.ClientID == "" {
+		host, _ := os.Hostname()
+		cfg.ClientID = fmt.Sprintf("ccu-%s-%d", host, os.Getpid())
+	}
+	p := &Publisher{
+		cfg:      cfg,
+		version:  version,
+		updates:  make(chan struct{}, 1),
+		resend:   make(chan struct{}, 1),
+		onBroker: make(map[string]bool),
+		sent:     make(map[string]string),
+		done:     make(chan struct{}),
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		// The broker marks every sensor unavailable if ccu goes away without
+		// disconnecting
+		SetWill(p.availabilityTopic(), payloadOffline, 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(10 * time.Second).
+		SetMaxReconnectInterval(time.Minute).
+		SetOnConnectHandler(p.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Printf("mqtt: connection lost: %v", err)
+		})
+	p.client = paho.NewClient(opts)
+	return p
+}
+
+// Update queues status to be published, replacing any update not yet sent
+func (p *Publisher) Update(status api.StatusResponse) {
+	p.mu.Lock()
+	p.latest = &status
+	p.mu.Unlock()
+	signal(p.updates)
+}
+
+// Start connects to the broker and publishes updates until ctx is cancelled,
+// then marks the sensors offline and disconnects. The broker is retried in
+// the background, so an unreachable broker is logged rather than returned.
+func (p *Publisher) Start(ctx context.Context) error {
+	defer close(p.done)
+	log.Printf("mqtt: publishing to %s under %s/", p.cfg.Broker, p.cfg.TopicPrefix)
+	p.client.Connect()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if p.client.IsConnectionOpen() {
+				p.client.Publish(p.availabilityTopic(), 1, true, payloadOffline).WaitTimeout(time.Second)
+			}
+			p.client.Disconnect(250)
+			return ctx.Err()
+		case <-p.resend:
+			// Retained messages may have been lost with the broker, and Home
+			// Assistant needs its discovery configs again after a restart
+			clear(p.sent)
+			p.publish()
+		case <-p.updates:
+			p.publish()
+		}
+	}
+}
+
+// Done is closed once Start has returned
+func (p *Publisher) Done() <-chan struct{} {
+	return p.done
+}
+
+// onConnect runs on paho's goroutine after every (re)connect
+func (p *Publisher) onConnect(c paho.Client) {
+	log.Printf("mqtt: connected to %s", p.cfg.Broker)
+	c.Publish(p.availabilityTopic(), 1, true, payloadOnline)
+
+	// The broker replays what it retains on the topics that come and go, so
+	// sensors for limits dropped while ccu wasn't running are cleared too
+	c.Subscribe(p.cfg.TopicPrefix+"/weekly/scoped/#", 0, p.onRetained)
+	if p.cfg.DiscoveryPrefix != "" {
+		c.Subscribe(p.cfg.DiscoveryPrefix+"/sensor/"+p.nodeID()+"/+/config", 0, p.onRetained)
+		// Home Assistant announces itself here when it starts
+		c.Subscribe(p.cfg.DiscoveryPrefix+"/status", 0, func(_ paho.Client, msg paho.Message) {
+			if string(msg.Payload()) == payloadOnline {
+				signal(p.resend)
+			}
+		})
+	}
+	signal(p.resend)
+}
+
+// onRetained records a retained message the broker replayed on subscribing.
+// Live messages, including this process's own publishes, are ignored.
+func (p *Publisher) onRetained(_ paho.Client, msg paho.Message) {
+	if !msg.Retained() || len(msg.Payload()) == 0 {
+		return
+	}
+	p.mu.Lock()
+	p.onBroker[msg.Topic()] = true
+	p.mu.Unlock()
+	signal(p.updates)
+}
+
+// publish sends the latest status: removals for retained topics it no longer
+// has, discovery configs for the entities it has, then every state topic
+// whose value changed
+func (p *Publisher) publish() {
+	p.mu.Lock()
+	status := p.latest
+	retained := slices.Sorted(maps.Keys(p.onBroker))
+	p.mu.Unlock()
+	if status == nil || !p.client.IsConnectionOpen() {
+		return // sent in full on connect
+	}
+
+	entities := p.entitiesFor(status)
+	want := make(map[string]bool, 2*len(entities))
+	for id, e := range entities {
+		want[e.StateTopic] = true
+		if p.cfg.DiscoveryPrefix != "" {
+			want[p.configTopic(id)] = true
+		}
+	}
+	for _, topic := range retained {
+		switch {
+		case want[topic]:
+		case status.Weekly != nil:
+			// A scoped limit Anthropic stopped reporting: an empty retained
+			// config removes the entity, and an empty state clears its value
+			p.send(topic, "")
+		case strings.HasPrefix(topic, p.cfg.TopicPrefix+"/weekly/scoped/"):
+			// Without weekly data (OAuth off, backing off or not fetched
+			// yet) there's no telling which limits still apply, so the
+			// entities are kept and their values are unknown
+			p.send(topic, payloadUnknown)
+		}
+	}
+
+	if p.cfg.DiscoveryPrefix != "" {
+		for id, e := range entities {
+			p.send(p.configTopic(id), p.discoveryConfig(id, e))
+		}
+	}
+	for _, e := range entities {
+		p.send(e.StateTopic, e.value)
+	}
+}
+
+// send publishes payload retained on topic unless it is what the broker
+// already holds, and reports whether the broker has it
+func (p *Publisher) send(topic, payload string) bool {
+	if last, ok := p.sent[topic]; ok && last == payload {
+		return true
+	}
+	token := p.client.Publish(topic, 1, true, payload)
+	if !token.WaitTimeout(publishTimeout) {
+		log.Printf("mqtt: publishing %s timed out", topic)
+		return false
+	}
+	if err := token.Error(); err != nil {
+		log.Printf("mqtt: publishing %s: %v", topic, err)
+		return false
+	}
+	p.mu.Lock()
+	if payload == "" {
+		delete(p.sent, topic)
+		delete(p.onBroker, topic)
+	} else {
+		p.sent[topic] = payload
+		p.onBroker[topic] = true
+	}
+	p.mu.Unlock()
+	return true
+}
+
+func (p *Publisher) availabilityTopic() string {
+	return p.cfg.TopicPrefix + "/availability"
+}
+
+// signal wakes the receiver of ch without blocking if it is already pending
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
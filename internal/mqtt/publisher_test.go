@@ -0,0 +1,192 @@
+package mqtt
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startBroker runs an in-process MQTT broker on a loopback port and returns
+// it with its URL
+func startBroker(t *testing.T) (*mochi.Server, string) {
+	t.Helper()
+	broker := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	require.NoError(t, broker.AddHook(new(auth.AllowHook), nil))
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	require.NoError(t, broker.AddListener(tcp))
+	require.NoError(t, broker.Serve())
+	t.Cleanup(func() { broker.Close() })
+	return broker, "tcp://" + tcp.Address()
+}
+
+// startPublisher runs a publisher until the test ends
+func startPublisher(t *testing.T, broker string) *Publisher {
+	t.Helper()
+	p := New(testConfig(broker), "1.2.3")
+	ctx, cancel := context.WithCancel(context.Background())
+	go p.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		<-p.Done()
+	})
+	return p
+}
+
+// retained returns the broker's retained payload for topic, or "" when it
+// holds none
+func retained(broker *mochi.Server, topic string) string {
+	pk, ok := broker.Topics.Retained.Get(topic)
+	if !ok {
+		return ""
+	}
+	return string(pk.Payload)
+}
+
+func eventuallyRetained(t *testing.T, broker *mochi.Server, topic, want string) {
+	t.Helper()
+	assert.Eventually(t, func() bool { return retained(broker, topic) == want }, 5*time.Second, 10*time.Millisecond,
+		"%s: want %q, have %q", topic, want, retained(broker, topic))
+}
+
+func TestPublisher(t *testing.T) {
+	broker, url := startBroker(t)
+	p := startPublisher(t, url)
+
+	status := testStatus()
+	p.Update(status)
+
+	eventuallyRetained(t, broker, "ccu/availability", payloadOnline)
+	eventuallyRetained(t, broker, "ccu/session/utilisation_pct", "42.5")
+	eventuallyRetained(t, broker, "ccu/weekly/scoped/fable_web/utilisation_pct", "80.0")
+	eventuallyRetained(t, broker, "ccu/burn_rate/cost_per_hour_usd", "2.88")
+	fableWeb := "homeassistant/sensor/ccu/weekly_fable_web_utilisation/config"
+	assert.Contains(t, retained(broker, fableWeb), `"name":"Fable (web) weekly usage"`)
+	assert.NotEmpty(t, retained(broker, "homeassistant/sensor/ccu/session_resets_at/config"))
+
+	// Anthropic drops the web limit and the session ends: the entity is
+	// removed and the session sensors become unknown
+	delete(status.Weekly.Scoped, "fable/web")
+	status.Session = nil
+	p.Update(status)
+
+	eventuallyRetained(t, broker, fableWeb, "")
+	eventuallyRetained(t, broker, "ccu/weekly/scoped/fable_web/utilisation_pct", "")
+	eventuallyRetained(t, broker, "ccu/session/utilisation_pct", payloadUnknown)
+	assert.NotEmpty(t, retained(broker, "homeassistant/sensor/ccu/weekly_fable_utilisation/config"), "other limits are kept")
+
+	// Home Assistant announcing itself gets the discovery configs again, e.g.
+	// after its broker lost them
+	sessionConfig := "homeassistant/sensor/ccu/session_utilisation/config"
+	require.NoError(t, broker.Publish(sessionConfig, nil, true, 0))
+	require.Empty(t, retained(broker, sessionConfig))
+	require.NoError(t, broker.Publish("homeassistant/status", []byte(payloadOnline), false, 0))
+	eventuallyRetained(t, broker, sessionConfig, p.discoveryConfig("session_utilisation", p.entitiesFor(&status)["session_utilisation"]))
+}
+
+func TestPublisher_NoWeeklyKeepsScoped(t *testing.T) {
+	broker, url := startBroker(t)
+	p := startPublisher(t, url)
+
+	status := testStatus()
+	p.Update(status)
+	fableWeb := "ccu/weekly/scoped/fable_web/utilisation_pct"
+	eventuallyRetained(t, broker, fableWeb, "80.0")
+
+	// OAuth data goes missing, e.g. while rate limited: the limits may still
+	// apply, so their sensors become unknown rather than being removed
+	status.Weekly = nil
+	p.Update(status)
+
+	eventuallyRetained(t, broker, fableWeb, payloadUnknown)
+	eventuallyRetained(t, broker, "ccu/weekly/scoped/fable_web/resets_at", payloadUnknown)
+	eventuallyRetained(t, broker, "ccu/weekly/utilisation_pct", payloadUnknown)
+	assert.NotEmpty(t, retained(broker, "homeassistant/sensor/ccu/weekly_fable_web_utilisation/config"))
+	assert.NotEmpty(t, retained(broker, "homeassistant/sensor/ccu/weekly_fable_utilisation/config"))
+
+	// Once it's back the values return
+	p.Update(testStatus())
+	eventuallyRetained(t, broker, fableWeb, "80.0")
+}
+
+func TestPublisher_StaleFromEarlierRun(t *testing.T) {
+	broker, url := startBroker(t)
+	// An earlier run announced a limit Anthropic dropped while ccu was stopped
+	oldConfig := "homeassistant/sensor/ccu/weekly_opus_utilisation/config"
+	oldState := "ccu/weekly/scoped/opus/utilisation_pct"
+	require.NoError(t, broker.Publish(oldConfig, []byte(`{"name":"Opus weekly usage"}`), true, 0))
+	require.NoError(t, broker.Publish(oldState, []byte("12.0"), true, 0))
+	// Another ccu's topics are left alone
+	otherConfig := "homeassistant/sensor/laptop/weekly_opus_utilisation/config"
+	require.NoError(t, broker.Publish(otherConfig, []byte(`{"name":"Opus weekly usage"}`), true, 0))
+
+	p := startPublisher(t, url)
+	p.Update(testStatus())
+
+	eventuallyRetained(t, broker, oldConfig, "")
+	eventuallyRetained(t, broker, oldState, "")
+	eventuallyRetained(t, broker, "ccu/weekly/scoped/fable_web/utilisation_pct", "80.0")
+	assert.NotEmpty(t, retained(broker, "homeassistant/sensor/ccu/weekly_fable_web_utilisation/config"))
+	assert.NotEmpty(t, retained(broker, otherConfig))
+}
+
+func TestPublisher_Shutdown(t *testing.T) {
+	broker, url := startBroker(t)
+	p := New(testConfig(url), "1.2.3")
+	ctx, cancel := context.WithCancel(context.Background())
+	go p.Start(ctx)
+
+	p.Update(testStatus())
+	eventuallyRetained(t, broker, "ccu/session/utilisation_pct", "42.5")
+
+	cancel()
+	<-p.Done()
+	assert.Equal(t, payloadOffline, retained(broker, "ccu/availability"))
+	assert.Equal(t, "42.5", retained(broker, "ccu/session/utilisation_pct"), "values stay retained")
+}
+
+func TestPublisher_NoDiscovery(t *testing.T) {
+	broker, url := startBroker(t)
+	cfg := testConfig(url)
+	cfg.DiscoveryPrefix = ""
+	oldState := "ccu/weekly/scoped/opus/utilisation_pct"
+	require.NoError(t, broker.Publish(oldState, []byte("12.0"), true, 0))
+	p := New(cfg, "1.2.3")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { cancel(); <-p.Done() }()
+	go p.Start(ctx)
+
+	p.Update(testStatus())
+	eventuallyRetained(t, broker, "ccu/session/utilisation_pct", "42.5")
+	eventuallyRetained(t, broker, oldState, "")
+	for topic := range broker.Topics.Retained.GetAll() {
+		assert.NotContains(t, topic, "homeassistant/")
+	}
+}
+
+func TestPublisher_UnreachableBroker(t *testing.T) {
+	p := New(testConfig("tcp://127.0.0.1:1"), "1.2.3")
+	ctx, cancel := context.WithCancel(context.Background())
+	go p.Start(ctx)
+
+	// Updates never block, and shutdown doesn't wait for the broker
+	for range 10 {
+		p.Update(testStatus())
+	}
+	cancel()
+	select {
+	case <-p.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not return after cancel")
+	}
+}
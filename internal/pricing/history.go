@@ -0,0 +1,312 @@
+package pricing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+)
+
+// PricePoint is a model's rates from EffectiveFrom onwards. A zero
+// EffectiveFrom has no start date, so the rates also cover all earlier usage.
+type PricePoint struct {
+	EffectiveFrom time.Time
+	Pricing
+}
+
+// priceTable maps pricing keys (see models.PricingKey) to price history, oldest first
+type priceTable map[string][]PricePoint
+
+var (
+	tableMu sync.RWMutex
+	active  priceTable // built-ins merged with any loaded overrides file
+)
+
+func init() {
+	active = builtinTable()
+	if err := active.validate(); err != nil {
+		panic(fmt.Sprintf("pricing: built-in table invalid: %v", err))
+	}
+}
+
+// builtinTable converts ModelPricing into an undated price history
+func builtinTable() priceTable {
+	table := make(priceTable, len(ModelPricing))
+	for model, p := range ModelPricing {
+		table[model] = []PricePoint{{Pricing: p}}
+	}
+	return table
+}
+
+// validate checks the invariants lookups rely on: every history is sorted with
+// no duplicate dates and no negative rates, and fallbackModel has an undated
+// price so an entry can always be priced.
+func (t priceTable) validate() error {
+	fallback := t[fallbackModel]
+	if len(fallback) == 0 || !fallback[0].EffectiveFrom.IsZero() {
+		return fmt.Errorf("fallback model %q must have an undated price (no effective_from)", fallbackModel)
+	}
+
+	for model, history := range t {
+		if len(history) == 0 {
+			return fmt.Errorf("model %q has no prices", model)
+		}
+		for i, point := range history {
+			if i > 0 && !point.EffectiveFrom.After(history[i-1].EffectiveFrom) {
+				return fmt.Errorf("model %q: prices must have distinct effective_from dates", model)
+			}
+			if err := point.Pricing.validate(); err != nil {
+				return fmt.Errorf("model %q: %w", model, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate rejects negative rates and a long-context tier without a threshold
+func (p Pricing) validate() error {
+	for _, rate := range []float64{p.Input, p.Output, p.CacheCreation, p.CacheCreation1h, p.CacheRead} {
+		if rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return fmt.Errorf("invalid rate %v", rate)
+		}
+	}
+	if p.LongContext != nil {
+		if p.LongContext.Threshold <= 0 {
+			return fmt.Errorf("long context threshold must be positive")
+		}
+		return p.LongContext.Rates.validate()
+	}
+	return nil
+}
+
+// Lookup returns the rates for a model in effect at the given time. ok is
+// false when the model has no price at that time: Claude models then get the
+// fallback model's rates, and other providers' models zero rates, since
+// Sonnet's price says nothing about what another vendor charges.
+func Lookup(model string, at time.Time) (p Pricing, ok bool) {
+	tableMu.RLock()
+	defer tableMu.RUnlock()
+
+	if p, ok := active.priceAt(models.PricingKey(model), at); ok {
+		return p, true
+	}
+	if !models.IsAnthropic(model) {
+		return Pricing{}, false
+	}
+	p, _ = active.priceAt(fallbackModel, at)
+	return p, false
+}
+
+// Fingerprint identifies the active rates, built-in and file alike. Costs
+// stored with one fingerprint are stale once it changes.
+func Fingerprint() uint64 {
+	tableMu.RLock()
+	defer tableMu.RUnlock()
+
+	h := fnv.New64a()
+	// Encoding a map sorts its keys, so equal tables hash equally
+	_ = json.NewEncoder(h).Encode(active)
+	_ = json.NewEncoder(h).Encode(ServerTools)
+	return h.Sum64()
+}
+
+// priceAt returns the latest price point that took effect at or before at
+func (t priceTable) priceAt(model string, at time.Time) (Pricing, bool) {
+	history := t[model]
+	i := sort.Search(len(history), func(i int) bool {
+		return history[i].EffectiveFrom.After(at)
+	})
+	if i == 0 {
+		return Pricing{}, false
+	}
+	return history[i-1].Pricing, true
+}
+
+// File is the on-disk pricing overrides format. Rates are USD per 1M tokens.
+// Cache rates default to the usual multiples of input when omitted.
+//
+//	{"models": {"claude-opus-4-8": [
+//	  {"input": 5, "output": 25},
+//	  {"effective_from": "2026-11-01", "input": 4, "output": 20}
+//	]}}
+type File struct {
+	Models map[string][]FilePrice `json:"models"`
+}
+
+// FilePrice is one dated (or undated) price for a model in a File
+type FilePrice struct {
+	EffectiveFrom string `json:"effective_from,omitempty"` // YYYY-MM-DD (UTC); empty = undated
+	FileRates
+	LongContext *FileLongContext `json:"long_context,omitempty"`
+}
+
+// FileRates holds the per-1M rates of a FilePrice; nil means omitted
+type FileRates struct {
+	Input        *float64 `json:"input"`
+	Output       *float64 `json:"output"`
+	CacheWrite   *float64 `json:"cache_write,omitempty"`
+	CacheWrite1h *float64 `json:"cache_write_1h,omitempty"`
+	CacheRead    *float64 `json:"cache_read,omitempty"`
+}
+
+// FileLongContext is the long-context tier of a FilePrice
+type FileLongContext struct {
+	Threshold int `json:"threshold,omitempty"` // prompt tokens; defaults to 200k
+	FileRates
+}
+
+// NewFilePrice returns an undated FilePrice stating every rate of p explicitly
+func NewFilePrice(p Pricing) FilePrice {
+	fp := FilePrice{FileRates: newFileRates(p)}
+	if p.LongContext != nil {
+		fp.LongContext = &FileLongContext{
+			Threshold: p.LongContext.Threshold,
+			FileRates: newFileRates(p.LongContext.Rates),
+		}
+	}
+	return fp
+}
+
+func newFileRates(p Pricing) FileRates {
+	return FileRates{
+		Input:        &p.Input,
+		Output:       &p.Output,
+		CacheWrite:   &p.CacheCreation,
+		CacheWrite1h: &p.CacheCreation1h,
+		CacheRead:    &p.CacheRead,
+	}
+}
+
+// Standard returns pricing with cache rates at the usual multiples of input:
+// 1.25x for 5-minute writes, 2x for 1-hour writes and 0.1x for reads
+func Standard(input, output float64) Pricing {
+	return Pricing{
+		Input:           input,
+		Output:          output,
+		CacheCreation:   input * 1.25,
+		CacheCreation1h: input * 2,
+		CacheRead:       input * 0.1,
+	}
+}
+
+// toPricing fills in omitted cache rates as Standard does
+func (r FileRates) toPricing() (Pricing, error) {
+	if r.Input == nil || r.Output == nil {
+		return Pricing{}, fmt.Errorf("input and output rates are required")
+	}
+	p := Standard(*r.Input, *r.Output)
+	if r.CacheWrite != nil {
+		p.CacheCreation = *r.CacheWrite
+	}
+	if r.CacheWrite1h != nil {
+		p.CacheCreation1h = *r.CacheWrite1h
+	}
+	if r.CacheRead != nil {
+		p.CacheRead = *r.CacheRead
+	}
+	return p, nil
+}
+
+// LoadFile merges a pricing overrides file over the built-in table and makes
+// the result active. A file price replaces the built-in price with the same
+// effective date (undated replaces undated) and otherwise extends that model's
+// history. On any error the active table is left unchanged.
+func LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading pricing file: %w", err)
+	}
+
+	table, err := parseOverrides(data)
+	if err != nil {
+		return fmt.Errorf("pricing file %s: %w", path, err)
+	}
+
+	tableMu.Lock()
+	active = table
+	tableMu.Unlock()
+	return nil
+}
+
+// parseOverrides parses an overrides file and returns the merged, validated table
+func parseOverrides(data []byte) (priceTable, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields() // a misspelt rate would otherwise silently price at zero
+	var file File
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+
+	table := builtinTable()
+	for model, points := range file.Models {
+		if key := models.PricingKey(model); key != model {
+			return nil, fmt.Errorf("model %q is priced under %q; use that key", model, key)
+		}
+		dates := make(map[time.Time]bool, len(points))
+		for _, fp := range points {
+			point, err := fp.toPricePoint()
+			if err != nil {
+				return nil, fmt.Errorf("model %q: %w", model, err)
+			}
+			if dates[point.EffectiveFrom] {
+				return nil, fmt.Errorf("model %q: duplicate effective_from %q", model, fp.EffectiveFrom)
+			}
+			dates[point.EffectiveFrom] = true
+			table[model] = mergePoint(table[model], point)
+		}
+	}
+
+	if err := table.validate(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+func (fp FilePrice) toPricePoint() (PricePoint, error) {
+	var point PricePoint
+	if fp.EffectiveFrom != "" {
+		t, err := time.Parse("2006-01-02", fp.EffectiveFrom)
+		if err != nil {
+			return point, fmt.Errorf("effective_from %q: want YYYY-MM-DD", fp.EffectiveFrom)
+		}
+		point.EffectiveFrom = t
+	}
+
+	p, err := fp.toPricing()
+	if err != nil {
+		return point, err
+	}
+	if fp.LongContext != nil {
+		rates, err := fp.LongContext.toPricing()
+		if err != nil {
+			return point, fmt.Errorf("long_context: %w", err)
+		}
+		threshold := fp.LongContext.Threshold
+		if threshold == 0 {
+			threshold = longContextThreshold
+		}
+		p.LongContext = &LongContextTier{Threshold: threshold, Rates: rates}
+	}
+	point.Pricing = p
+	return point, nil
+}
+
+// mergePoint inserts point into a sorted history, replacing any existing
+// point with the same effective date
+func mergePoint(history []PricePoint, point PricePoint) []PricePoint {
+	history = slices.DeleteFunc(slices.Clone(history), func(p PricePoint) bool {
+		return p.EffectiveFrom.Equal(point.EffectiveFrom)
+	})
+	i := sort.Search(len(history), func(i int) bool {
+		return history[i].EffectiveFrom.After(point.EffectiveFrom)
+	})
+	return slices.Insert(history, i, point)
+}
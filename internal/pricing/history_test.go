@@ -0,0 +1,166 @@
+package pricing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadTestFile writes content to a temp pricing file and loads it, restoring
+// the built-in table when the test ends
+func loadTestFile(t *testing.T, content string) error {
+	t.Helper()
+	t.Cleanup(func() {
+		tableMu.Lock()
+		active = builtinTable()
+		tableMu.Unlock()
+	})
+	path := filepath.Join(t.TempDir(), "pricing.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return LoadFile(path)
+}
+
+func TestBuiltinTableIsValid(t *testing.T) {
+	require.NoError(t, builtinTable().validate())
+}
+
+func TestLookup(t *testing.T) {
+	p, ok := Lookup("claude-opus-4-8-20260301", time.Now())
+	assert.True(t, ok)
+	assert.Equal(t, 5.00, p.Input)
+
+	// Unknown models fall back to Sonnet rates and report it
+	p, ok = Lookup("claude-zenith-1", time.Now())
+	assert.False(t, ok)
+	assert.Equal(t, ModelPricing[fallbackModel].Input, p.Input)
+}
+
+func TestLoadFileEffectiveDates(t *testing.T) {
+	require.NoError(t, loadTestFile(t, `{"models": {"claude-opus-4-8": [
+		{"effective_from": "2026-11-01", "input": 4, "output": 20}
+	]}}`))
+
+	before := time.Date(2026, 10, 31, 23, 59, 0, 0, time.UTC)
+	after := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	// Usage before the change keeps the built-in rate
+	p, ok := Lookup("claude-opus-4-8", before)
+	assert.True(t, ok)
+	assert.Equal(t, 5.00, p.Input)
+
+	p, _ = Lookup("claude-opus-4-8", after)
+	assert.Equal(t, 4.00, p.Input)
+	assert.Equal(t, 20.00, p.Output)
+	// Omitted cache rates derive from input
+	assert.InDelta(t, 5.00, p.CacheCreation, 1e-9)
+	assert.InDelta(t, 8.00, p.CacheCreation1h, 1e-9)
+	assert.InDelta(t, 0.40, p.CacheRead, 1e-9)
+
+	// CalculateCost prices each entry at its own timestamp
+	entry := models.UsageEntry{Model: "claude-opus-4-8", InputTokens: 1_000_000}
+	entry.Timestamp = before
+	assert.InDelta(t, 5.00, CalculateCost(entry), 1e-9)
+	entry.Timestamp = after
+	assert.InDelta(t, 4.00, CalculateCost(entry), 1e-9)
+}
+
+func TestLoadFileOverridesAndNewModels(t *testing.T) {
+	require.NoError(t, loadTestFile(t, `{"models": {
+		"claude-haiku-4-5": [{"input": 2, "output": 10, "cache_read": 0}],
+		"claude-zenith-1": [{"effective_from": "2026-09-01", "input": 20, "output": 100,
+			"long_context": {"input": 40, "output": 150}}]
+	}}`))
+
+	// An undated file price replaces the undated built-in price
+	p, ok := Lookup("claude-haiku-4-5", time.Now())
+	assert.True(t, ok)
+	assert.Equal(t, 2.00, p.Input)
+	assert.Equal(t, 0.0, p.CacheRead)
+
+	// A new model is priced from its effective date; earlier usage falls back
+	p, ok = Lookup("claude-zenith-1", time.Date(2026, 9, 2, 0, 0, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, 20.00, p.Input)
+	require.NotNil(t, p.LongContext)
+	assert.Equal(t, longContextThreshold, p.LongContext.Threshold)
+	assert.Equal(t, 40.00, p.LongContext.Rates.Input)
+
+	_, ok = Lookup("claude-zenith-1", time.Date(2026, 8, 31, 0, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+}
+
+func TestFingerprint(t *testing.T) {
+	builtin := Fingerprint()
+	assert.Equal(t, builtin, Fingerprint(), "fingerprint must be stable")
+
+	require.NoError(t, loadTestFile(t, `{"models": {"claude-haiku-4-5": [{"input": 2, "output": 10}]}}`))
+	assert.NotEqual(t, builtin, Fingerprint())
+}
+
+func TestThirdPartyPricing(t *testing.T) {
+	// Unpriced models from other providers get zero rates, not Sonnet's
+	p, ok := Lookup("gpt-4o", time.Now())
+	assert.False(t, ok)
+	assert.Zero(t, p.Input)
+
+	require.NoError(t, loadTestFile(t, `{"models": {"gpt-4o": [{"input": 2.5, "output": 10, "cache_read": 1.25}]}}`))
+	entry := models.UsageEntry{Model: "gpt-4o", Timestamp: time.Now(), InputTokens: 1_000_000, CacheReadTokens: 1_000_000}
+	assert.True(t, IsPriced(entry))
+	assert.InDelta(t, 3.75, CalculateCost(entry), 1e-9)
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"invalid json", `{`, "parsing"},
+		{"unknown field", `{"models": {"claude-opus-4-8": [{"input": 5, "outptu": 25}]}}`, "unknown field"},
+		{"non-canonical key", `{"models": {"claude-opus-4-9": [{"input": 5, "output": 25}]}}`, `priced under "claude-opus-4"`},
+		{"shared pricing key", `{"models": {"claude-mythos-5": [{"input": 5, "output": 25}]}}`, `priced under "claude-fable-5"`},
+		{"missing output", `{"models": {"claude-opus-4-8": [{"input": 5}]}}`, "input and output rates are required"},
+		{"bad date", `{"models": {"claude-opus-4-8": [{"effective_from": "01/11/2026", "input": 5, "output": 25}]}}`, "want YYYY-MM-DD"},
+		{"negative rate", `{"models": {"claude-opus-4-8": [{"input": -5, "output": 25}]}}`, "invalid rate"},
+		{"duplicate date", `{"models": {"claude-opus-4-8": [
+			{"effective_from": "2026-11-01", "input": 5, "output": 25},
+			{"effective_from": "2026-11-01", "input": 4, "output": 20}
+		]}}`, "duplicate effective_from"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := loadTestFile(t, tt.content)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+
+			// A rejected file leaves the built-in rates active
+			p, _ := Lookup("claude-opus-4-8", time.Now())
+			assert.Equal(t, 5.00, p.Input)
+		})
+	}
+}
+
+func TestLoadFileMissing(t *testing.T) {
+	err := LoadFile(filepath.Join(t.TempDir(), "absent.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestValidateFallbackInvariant(t *testing.T) {
+	table := builtinTable()
+	table[fallbackModel] = []PricePoint{{
+		EffectiveFrom: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Pricing:       ModelPricing[fallbackModel],
+	}}
+	err := table.validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must have an undated price")
+
+	delete(table, fallbackModel)
+	assert.Error(t, table.validate())
+}
@@ -1,166 +1,262 @@
 package pricing
 
 import (
-	"fmt"
+	"cmp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/sammcj/ccu/internal/models"
 )
 
-// fallbackModel is the pricing key used when a normalised model name is not
-// found in ModelPricing. It MUST remain a valid key in ModelPricing; the
-// init() check below fails fast if that invariant ever regresses (for example
-// after a model rename in models.NormaliseModelName).
+// fallbackModel is the pricing key used when a model has no price. It MUST
+// remain a valid key in ModelPricing with an undated price; the table
+// validation in history.go fails fast at init (and rejects a pricing file at
+// load) if that invariant ever regresses, for example after a model rename in
+// the model registry.
 const fallbackModel = "claude-sonnet-4-6"
 
-func init() {
-	if _, ok := ModelPricing[fallbackModel]; !ok {
-		panic(fmt.Sprintf("pricing: fallback model %q missing from ModelPricing", fallbackModel))
+// Pricing holds per-million token costs for a model. CacheCreation is the
+// default 5-minute cache write rate (1.25x input); CacheCreation1h is the
+// extended 1-hour TTL rate (2x input).
+type Pricing struct {
+	Input           float64          `json:"input"`
+	Output          float64          `json:"output"`
+	CacheCreation   float64          `json:"cache_write"`
+	CacheCreation1h float64          `json:"cache_write_1h"`
+	CacheRead       float64          `json:"cache_read"`
+	LongContext     *LongContextTier `json:"long_context,omitempty"` // nil when the model has a single flat rate
+}
+
+// LongContextTier is a premium rate set that replaces a model's standard rates
+// for the whole request once its prompt exceeds Threshold tokens. The prompt
+// is input plus cache write plus cache read tokens, as Anthropic bills it.
+type LongContextTier struct {
+	Threshold int     `json:"threshold"`
+	Rates     Pricing `json:"rates"`
+}
+
+// longContextThreshold is the prompt size above which 1M-context models bill
+// at their premium tier
+const longContextThreshold = 200_000
+
+// sonnetLongContext is the premium tier shared by the Sonnet 4 family
+var sonnetLongContext = &LongContextTier{
+	Threshold: longContextThreshold,
+	Rates: Pricing{
+		Input:           6.00,
+		Output:          22.50,
+		CacheCreation:   7.50,
+		CacheCreation1h: 12.00,
+		CacheRead:       0.60,
+	},
+}
+
+// ratesFor returns the rates that apply to a request with the given prompt size
+func (p Pricing) ratesFor(promptTokens int) Pricing {
+	if p.LongContext != nil && promptTokens > p.LongContext.Threshold {
+		return p.LongContext.Rates
 	}
+	return p
 }
 
-// Pricing holds per-million token costs for a model
-type Pricing struct {
-	Input         float64
-	Output        float64
-	CacheCreation float64
-	CacheRead     float64
+// ServerToolPricing holds per-request costs (USD) for server-side tools. The
+// API bills these per call on top of the tokens the tool's results consume,
+// and the rate is the same whichever model made the call.
+type ServerToolPricing struct {
+	WebSearch float64
+	WebFetch  float64
 }
 
-// ModelPricing contains pricing for all known models (per 1M tokens in USD)
+// ServerTools is the current server tool price list: $10 per 1,000 web
+// searches. Web fetch carries no per-request charge, only the tokens it adds.
+var ServerTools = ServerToolPricing{
+	WebSearch: 10.00 / 1000,
+	WebFetch:  0,
+}
+
+// ModelPricing contains the built-in pricing for all known models (per 1M
+// tokens in USD), keyed by models.PricingKey. Costs are calculated from the
+// active table, which merges a pricing file over these; use Lookup rather
+// than reading the map directly.
 var ModelPricing = map[string]Pricing{
 	// Fable 5 uses a new tokenizer (~30% more tokens for the same content than
 	// Opus-tier models), so its token counts are not directly comparable to
-	// other models' burn rates. Pricing maths is unaffected.
+	// other models' burn rates. Pricing maths is unaffected. Mythos 5 is the
+	// same model (Project Glasswing) and is priced under this key.
 	"claude-fable-5": {
-		Input:         10.00,
-		Output:        50.00,
-		CacheCreation: 12.50,
-		CacheRead:     1.00,
-	},
-	// Mythos 5 is the same model as Fable 5 (Project Glasswing), same pricing
-	"claude-mythos-5": {
-		Input:         10.00,
-		Output:        50.00,
-		CacheCreation: 12.50,
-		CacheRead:     1.00,
+		Input:           10.00,
+		Output:          50.00,
+		CacheCreation:   12.50,
+		CacheCreation1h: 20.00,
+		CacheRead:       1.00,
 	},
 	"claude-opus-4-8": {
-		Input:         5.00,
-		Output:        25.00,
-		CacheCreation: 6.25,
-		CacheRead:     0.50,
+		Input:           5.00,
+		Output:          25.00,
+		CacheCreation:   6.25,
+		CacheCreation1h: 10.00,
+		CacheRead:       0.50,
 	},
 	"claude-opus-4-7": {
-		Input:         5.00,
-		Output:        25.00,
-		CacheCreation: 6.25,
-		CacheRead:     0.50,
+		Input:           5.00,
+		Output:          25.00,
+		CacheCreation:   6.25,
+		CacheCreation1h: 10.00,
+		CacheRead:       0.50,
 	},
 	"claude-opus-4-6": {
-		Input:         5.00,
-		Output:        25.00,
-		CacheCreation: 6.25,
-		CacheRead:     0.50,
+		Input:           5.00,
+		Output:          25.00,
+		CacheCreation:   6.25,
+		CacheCreation1h: 10.00,
+		CacheRead:       0.50,
+		LongContext: &LongContextTier{
+			Threshold: longContextThreshold,
+			Rates: Pricing{
+				Input:           10.00,
+				Output:          37.50,
+				CacheCreation:   12.50,
+				CacheCreation1h: 20.00,
+				CacheRead:       1.00,
+			},
+		},
 	},
 	"claude-opus-4-5": {
-		Input:         5.00,
-		Output:        25.00,
-		CacheCreation: 6.25,
-		CacheRead:     0.50,
+		Input:           5.00,
+		Output:          25.00,
+		CacheCreation:   6.25,
+		CacheCreation1h: 10.00,
+		CacheRead:       0.50,
 	},
 	"claude-sonnet-4-6": {
-		Input:         3.00,
-		Output:        15.00,
-		CacheCreation: 3.75,
-		CacheRead:     0.30,
+		Input:           3.00,
+		Output:          15.00,
+		CacheCreation:   3.75,
+		CacheCreation1h: 6.00,
+		CacheRead:       0.30,
+		LongContext:     sonnetLongContext,
 	},
 	"claude-sonnet-4-5": {
-		Input:         3.00,
-		Output:        15.00,
-		CacheCreation: 3.75,
-		CacheRead:     0.30,
+		Input:           3.00,
+		Output:          15.00,
+		CacheCreation:   3.75,
+		CacheCreation1h: 6.00,
+		CacheRead:       0.30,
+		LongContext:     sonnetLongContext,
 	},
 	"claude-haiku-4-5": {
-		Input:         1.00,
-		Output:        5.00,
-		CacheCreation: 1.25,
-		CacheRead:     0.10,
+		Input:           1.00,
+		Output:          5.00,
+		CacheCreation:   1.25,
+		CacheCreation1h: 2.00,
+		CacheRead:       0.10,
 	},
 	"claude-opus-4-1": {
-		Input:         15.00,
-		Output:        75.00,
-		CacheCreation: 18.75,
-		CacheRead:     1.50,
+		Input:           15.00,
+		Output:          75.00,
+		CacheCreation:   18.75,
+		CacheCreation1h: 30.00,
+		CacheRead:       1.50,
 	},
 	"claude-opus-4": {
-		Input:         15.00,
-		Output:        75.00,
-		CacheCreation: 18.75,
-		CacheRead:     1.50,
+		Input:           15.00,
+		Output:          75.00,
+		CacheCreation:   18.75,
+		CacheCreation1h: 30.00,
+		CacheRead:       1.50,
 	},
 	"claude-3-opus": {
-		Input:         15.00,
-		Output:        75.00,
-		CacheCreation: 18.75,
-		CacheRead:     1.50,
+		Input:           15.00,
+		Output:          75.00,
+		CacheCreation:   18.75,
+		CacheCreation1h: 30.00,
+		CacheRead:       1.50,
 	},
 	"claude-3-sonnet": {
-		Input:         3.00,
-		Output:        15.00,
-		CacheCreation: 3.75,
-		CacheRead:     0.30,
+		Input:           3.00,
+		Output:          15.00,
+		CacheCreation:   3.75,
+		CacheCreation1h: 6.00,
+		CacheRead:       0.30,
 	},
 	"claude-3-5-sonnet": {
-		Input:         3.00,
-		Output:        15.00,
-		CacheCreation: 3.75,
-		CacheRead:     0.30,
+		Input:           3.00,
+		Output:          15.00,
+		CacheCreation:   3.75,
+		CacheCreation1h: 6.00,
+		CacheRead:       0.30,
 	},
 	"claude-sonnet-4": {
-		Input:         3.00,
-		Output:        15.00,
-		CacheCreation: 3.75,
-		CacheRead:     0.30,
+		Input:           3.00,
+		Output:          15.00,
+		CacheCreation:   3.75,
+		CacheCreation1h: 6.00,
+		CacheRead:       0.30,
+		LongContext:     sonnetLongContext,
 	},
 	"claude-3-haiku": {
-		Input:         0.25,
-		Output:        1.25,
-		CacheCreation: 0.30,
-		CacheRead:     0.03,
+		Input:           0.25,
+		Output:          1.25,
+		CacheCreation:   0.30,
+		CacheCreation1h: 0.50,
+		CacheRead:       0.03,
 	},
 	"claude-3-5-haiku": {
-		Input:         0.80,
-		Output:        4.00,
-		CacheCreation: 1.00,
-		CacheRead:     0.08,
+		Input:           0.80,
+		Output:          4.00,
+		CacheCreation:   1.00,
+		CacheCreation1h: 1.60,
+		CacheRead:       0.08,
 	},
 }
 
-// CalculateCost calculates the cost of a usage entry in USD
+// IsPriced reports whether an entry's model has a price at its timestamp.
+// Unpriced Claude entries are costed at fallbackModel rates and other
+// providers' entries at zero.
+func IsPriced(entry models.UsageEntry) bool {
+	_, ok := Lookup(entry.Model, entry.Timestamp)
+	return ok
+}
+
+// IsLongContext reports whether an entry's prompt crossed its model's
+// long-context threshold, so the whole request bills at the premium tier
+func IsLongContext(entry models.UsageEntry) bool {
+	p, _ := Lookup(entry.Model, entry.Timestamp)
+	return p.LongContext != nil && entry.PromptTokens() > p.LongContext.Threshold
+}
+
+// CalculateCost calculates the cost of a usage entry in USD at the rates in
+// effect at its timestamp. Unknown Claude models are priced at Sonnet rates
+// and unknown models from other providers at zero.
 func CalculateCost(entry models.UsageEntry) float64 {
-	normalisedModel := models.NormaliseModelName(entry.Model)
-	pricing, ok := ModelPricing[normalisedModel]
-	if !ok {
-		// Use Sonnet pricing as default for unknown models
-		pricing = ModelPricing[fallbackModel]
-	}
+	p, _ := Lookup(entry.Model, entry.Timestamp)
+	pricing := p.ratesFor(entry.PromptTokens())
 
 	cost := 0.0
 	cost += float64(entry.InputTokens) * pricing.Input / 1_000_000
 	cost += float64(entry.OutputTokens) * pricing.Output / 1_000_000
-	cost += float64(entry.CacheCreationTokens) * pricing.CacheCreation / 1_000_000
+	cacheWrite5m, cacheWrite1h := entry.CacheCreationSplit()
+	cost += float64(cacheWrite5m) * pricing.CacheCreation / 1_000_000
+	cost += float64(cacheWrite1h) * pricing.CacheCreation1h / 1_000_000
 	cost += float64(entry.CacheReadTokens) * pricing.CacheRead / 1_000_000
+	cost += serverToolCost(entry)
 
 	return cost
 }
 
-// CalculateCostForTokens calculates cost for a specific model and token counts
+// serverToolCost returns the per-request charge for the server tools an entry used
+func serverToolCost(entry models.UsageEntry) float64 {
+	return float64(entry.WebSearchRequests)*ServerTools.WebSearch +
+		float64(entry.WebFetchRequests)*ServerTools.WebFetch
+}
+
+// CalculateCostForTokens calculates cost for a specific model and token counts.
+// cacheCreation is priced at the default 5-minute cache write rate, and all
+// tokens at current rates.
 func CalculateCostForTokens(model string, input, output, cacheCreation, cacheRead int) float64 {
-	normalisedModel := models.NormaliseModelName(model)
-	pricing, ok := ModelPricing[normalisedModel]
-	if !ok {
-		pricing = ModelPricing[fallbackModel]
-	}
+	p, _ := Lookup(model, time.Now())
+	pricing := p.ratesFor(input + cacheCreation + cacheRead)
 
 	cost := 0.0
 	cost += float64(input) * pricing.Input / 1_000_000
@@ -171,6 +267,58 @@ func CalculateCostForTokens(model string, input, output, cacheCreation, cacheRea
 	return cost
 }
 
+// UnpricedModel summarises usage of a model with no price, which was costed
+// at fallback rates (zero for providers other than Anthropic)
+type UnpricedModel struct {
+	Model        string
+	Provider     string
+	Requests     int
+	Tokens       int
+	FallbackCost float64 // USD at fallback rates, included in reported totals
+	FirstSeen    time.Time
+	LastSeen     time.Time
+}
+
+// UnpricedModels aggregates the entries flagged Unpriced by model, highest
+// fallback cost first. Returns nil when every entry was priced.
+func UnpricedModels(entries []models.UsageEntry) []UnpricedModel {
+	byModel := make(map[string]*UnpricedModel)
+	for _, entry := range entries {
+		if !entry.Unpriced {
+			continue
+		}
+		u := byModel[entry.Model]
+		if u == nil {
+			u = &UnpricedModel{Model: entry.Model, Provider: entry.Provider, FirstSeen: entry.Timestamp, LastSeen: entry.Timestamp}
+			byModel[entry.Model] = u
+		}
+		u.Requests++
+		u.Tokens += entry.TotalTokens()
+		u.FallbackCost += entry.CostUSD
+		if entry.Timestamp.Before(u.FirstSeen) {
+			u.FirstSeen = entry.Timestamp
+		}
+		if entry.Timestamp.After(u.LastSeen) {
+			u.LastSeen = entry.Timestamp
+		}
+	}
+	if len(byModel) == 0 {
+		return nil
+	}
+
+	result := make([]UnpricedModel, 0, len(byModel))
+	for _, u := range byModel {
+		result = append(result, *u)
+	}
+	slices.SortFunc(result, func(a, b UnpricedModel) int {
+		if c := cmp.Compare(b.FallbackCost, a.FallbackCost); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Model, b.Model)
+	})
+	return result
+}
+
 // GetPricingSource returns the pricing source description
 func GetPricingSource() string {
 	return "Anthropic API pricing (https://www.anthropic.com/pricing)"
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/sammcj/ccu/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCalculateCost(t *testing.T) {
@@ -81,6 +83,70 @@ func TestCalculateCost(t *testing.T) {
 			// = 0.005 + 0.0125 + 0.00125 + 0.00015 = 0.0189
 			want: 0.0189,
 		},
+		{
+			name: "opus 4.8 with 5-minute and 1-hour cache writes",
+			entry: models.UsageEntry{
+				Timestamp:             time.Now(),
+				InputTokens:           1000,
+				OutputTokens:          500,
+				CacheCreationTokens:   1000,
+				CacheCreation5mTokens: 400,
+				CacheCreation1hTokens: 600,
+				Model:                 "claude-opus-4-8",
+			},
+			// (1000 * 5 / 1M) + (500 * 25 / 1M) + (400 * 6.25 / 1M) + (600 * 10 / 1M)
+			// = 0.005 + 0.0125 + 0.0025 + 0.006 = 0.026
+			want: 0.026,
+		},
+		{
+			name: "sonnet with web search requests",
+			entry: models.UsageEntry{
+				Timestamp:         time.Now(),
+				InputTokens:       1000,
+				OutputTokens:      500,
+				WebSearchRequests: 3,
+				WebFetchRequests:  2,
+				Model:             "claude-sonnet-4-6",
+			},
+			// Tokens: 0.0105 as above. Web search: 3 * $0.01 = 0.03.
+			// Web fetch has no per-request charge.
+			want: 0.0405,
+		},
+		{
+			name: "sonnet 4.6 above the long-context threshold",
+			entry: models.UsageEntry{
+				Timestamp:       time.Now(),
+				InputTokens:     150_000,
+				OutputTokens:    1000,
+				CacheReadTokens: 60_000,
+				Model:           "claude-sonnet-4-6",
+			},
+			// 210k prompt tokens, so every token bills at the premium tier
+			// (150000 * 6 / 1M) + (1000 * 22.50 / 1M) + (60000 * 0.60 / 1M)
+			// = 0.9 + 0.0225 + 0.036 = 0.9585
+			want: 0.9585,
+		},
+		{
+			name: "sonnet 4.6 at exactly the long-context threshold",
+			entry: models.UsageEntry{
+				Timestamp:    time.Now(),
+				InputTokens:  200_000,
+				OutputTokens: 1000,
+				Model:        "claude-sonnet-4-6",
+			},
+			// Premium applies only above 200k: (200000 * 3 / 1M) + (1000 * 15 / 1M) = 0.615
+			want: 0.615,
+		},
+		{
+			name: "haiku 4.5 large prompt has no long-context tier",
+			entry: models.UsageEntry{
+				Timestamp:   time.Now(),
+				InputTokens: 300_000,
+				Model:       "claude-haiku-4-5",
+			},
+			// 300000 * 1 / 1M = 0.3
+			want: 0.3,
+		},
 		{
 			name: "sonnet 4.6 basic usage",
 			entry: models.UsageEntry{
@@ -123,6 +189,14 @@ func TestCalculateCostForTokens(t *testing.T) {
 			// (10000 * 0.25 / 1M) + (5000 * 1.25 / 1M) = 0.0025 + 0.00625 = 0.00875
 			want: 0.00875,
 		},
+		{
+			name:      "sonnet 4.5 long context",
+			model:     "claude-sonnet-4-5",
+			input:     190_000,
+			cacheRead: 20_000,
+			// (190000 * 6 / 1M) + (20000 * 0.60 / 1M) = 1.14 + 0.012 = 1.152
+			want: 1.152,
+		},
 	}
 
 	for _, tt := range tests {
@@ -134,3 +208,57 @@ func TestCalculateCostForTokens(t *testing.T) {
 		})
 	}
 }
+
+func TestUnpricedModels(t *testing.T) {
+	day := time.Date(2026, 7, 1, 10, 0, 0, 0, time.UTC)
+	entries := []models.UsageEntry{
+		{Timestamp: day, Model: "claude-sonnet-4-6", InputTokens: 100, CostUSD: 5},
+		{Timestamp: day.Add(time.Hour), Model: "claude-zenith-1", InputTokens: 100, OutputTokens: 50, CostUSD: 0.5, Unpriced: true},
+		{Timestamp: day, Model: "claude-zenith-1", InputTokens: 200, CostUSD: 0.25, Unpriced: true},
+		{Timestamp: day, Model: "claude-nimbus-2", InputTokens: 10, CostUSD: 1, Unpriced: true},
+	}
+
+	got := UnpricedModels(entries)
+	require.Len(t, got, 2)
+
+	// Highest fallback cost first
+	assert.Equal(t, "claude-nimbus-2", got[0].Model)
+	zenith := got[1]
+	assert.Equal(t, "claude-zenith-1", zenith.Model)
+	assert.Equal(t, 2, zenith.Requests)
+	assert.Equal(t, 350, zenith.Tokens)
+	assert.InDelta(t, 0.75, zenith.FallbackCost, 1e-9)
+	assert.Equal(t, day, zenith.FirstSeen)
+	assert.Equal(t, day.Add(time.Hour), zenith.LastSeen)
+
+	assert.Nil(t, UnpricedModels(entries[:1]))
+}
+
+func TestIsPriced(t *testing.T) {
+	now := time.Now()
+	assert.True(t, IsPriced(models.UsageEntry{Model: "claude-opus-4-8-20260301", Timestamp: now}))
+	assert.True(t, IsPriced(models.UsageEntry{Model: "claude-mythos-5", Timestamp: now}))
+	assert.False(t, IsPriced(models.UsageEntry{Model: "claude-zenith-1", Timestamp: now}))
+}
+
+func TestIsLongContext(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry models.UsageEntry
+		want  bool
+	}{
+		{"sonnet over threshold via cache", models.UsageEntry{Model: "claude-sonnet-4-6", InputTokens: 1000, CacheCreationTokens: 100_000, CacheReadTokens: 100_000}, true},
+		{"sonnet at threshold", models.UsageEntry{Model: "claude-sonnet-4-6", InputTokens: 200_000}, false},
+		{"opus 4.6 over threshold", models.UsageEntry{Model: "claude-opus-4-6", InputTokens: 250_000}, true},
+		{"haiku has no tier", models.UsageEntry{Model: "claude-haiku-4-5", InputTokens: 250_000}, false},
+		{"output tokens do not count", models.UsageEntry{Model: "claude-sonnet-4-6", InputTokens: 100, OutputTokens: 250_000}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsLongContext(tt.entry); got != tt.want {
+				t.Errorf("IsLongContext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/sammcj/ccu/internal/analysis"
 	"github.com/sammcj/ccu/internal/models"
 	"github.com/sammcj/ccu/internal/oauth"
+	"github.com/sammcj/ccu/internal/pricing"
 )
 
 // Column positions (1-based) for ANSI cursor positioning
@@ -68,8 +69,9 @@ type DashboardData struct {
 	Limits                 models.Limits
 	CurrentSession         *models.SessionBlock
 	AllSessions            []models.SessionBlock
-	OAuthData              *oauth.UsageData // Optional OAuth-fetched data
-	OAuthUnavailableReason string           // Reason OAuth is unavailable (for fallback display)
+	OAuthData              *oauth.UsageData        // Optional OAuth-fetched data
+	OAuthUnavailableReason string                  // Reason OAuth is unavailable (for fallback display)
+	UnpricedModels         []pricing.UnpricedModel // Models costed at fallback rates
 }
 
 // RenderDashboard renders the realtime dashboard in a single-column layout
@@ -142,9 +144,44 @@ func RenderDashboard(data DashboardData) string {
 		}
 	}
 
+	if warning := renderUnpricedWarning(data.UnpricedModels); warning != "" {
+		output = append(output, warning)
+	}
+
 	return strings.Join(output, "\n")
 }
 
+// maxUnpricedListed caps how many unpriced models a warning names
+const maxUnpricedListed = 3
+
+// renderUnpricedWarning warns that some usage was costed at fallback rates,
+// so cost figures may be off until the model is added to the pricing table.
+// Only Claude models are named: other providers' usage is not plan usage and
+// never reaches the dashboard's figures.
+func renderUnpricedWarning(unpriced []pricing.UnpricedModel) string {
+	unpriced = slices.DeleteFunc(slices.Clone(unpriced), func(u pricing.UnpricedModel) bool {
+		return u.Provider != "" && u.Provider != models.ProviderAnthropic
+	})
+	if len(unpriced) == 0 {
+		return ""
+	}
+	return WarningStyle.Render("⚠️  Unpriced models costed at Sonnet rates: " + formatUnpricedList(unpriced))
+}
+
+// formatUnpricedList names unpriced models with their fallback cost, highest
+// first, summarising any beyond maxUnpricedListed
+func formatUnpricedList(unpriced []pricing.UnpricedModel) string {
+	parts := make([]string, 0, maxUnpricedListed+1)
+	for i, u := range unpriced {
+		if i == maxUnpricedListed {
+			parts = append(parts, fmt.Sprintf("and %d more", len(unpriced)-i))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s ($%.2f, %s requests)", u.Model, u.FallbackCost, formatNumber(u.Requests)))
+	}
+	return strings.Join(parts, ", ")
+}
+
 // getSessionDistributionString returns just the distribution part without the label
 // Uses cost-based distribution as this is more meaningful than token counts
 // (e.g., Haiku is cheap so high token counts don't reflect actual usage impact)
@@ -280,10 +317,13 @@ func renderBurnRates(tokenBurnRate, costBurnRate float64, limits models.Limits,
 // Helper functions
 
 // FormatModelNameSimple returns simplified model names for display.
-// Converts full API names like "claude-opus-4-5-20251101" to "Opus 4.5".
+// Converts full API names like "claude-opus-4-5-20251101" to "Opus 4.5": a
+// name the model registry knows by ID or alias gets its display name, anything
+// else is split around a registry family name, so an unreleased version of a
+// known family still reads well.
 // Exported so modelcheck can verify new models render with a friendly name.
 func FormatModelNameSimple(model string) string {
-	name := strings.TrimPrefix(model, "claude-")
+	name := model
 
 	// Remove date suffix if present (8-digit date like -20251101)
 	parts := strings.Split(name, "-")
@@ -295,21 +335,24 @@ func FormatModelNameSimple(model string) string {
 		}
 	}
 
+	if m, ok := models.LookupModelExact(name); ok && m.DisplayName != "" {
+		return m.DisplayName
+	}
+	name = strings.TrimPrefix(name, "claude-")
+
 	// Find model family and extract version
-	families := []string{"fable", "mythos", "opus", "sonnet", "haiku"}
-	for _, family := range families {
-		if strings.Contains(name, family) {
-			_, after, _ := strings.Cut(name, family)
+	for _, family := range models.Families() {
+		if strings.Contains(name, family.ID) {
+			_, after, _ := strings.Cut(name, family.ID)
 			afterFamily := strings.TrimPrefix(after, "-")
 
 			// Convert version dashes to dots (e.g., "4-5" -> "4.5")
 			version := strings.ReplaceAll(afterFamily, "-", ".")
 
-			familyName := strings.ToUpper(family[:1]) + family[1:]
 			if version != "" {
-				return familyName + " " + version
+				return family.Name + " " + version
 			}
-			return familyName
+			return family.Name
 		}
 	}
 
@@ -500,7 +543,7 @@ func renderPredictionWithOAuth(oauthData *oauth.UsageData, session *models.Sessi
 	utilisationPercent, resetTime, _ := oauthData.EffectiveFiveHour(now)
 
 	purpleStyle := lipgloss.NewStyle().Foreground(ColorPrediction)
-	pinkStyle := lipgloss.NewStyle().Foreground(ColorOpus)
+	pinkStyle := lipgloss.NewStyle().Foreground(ColorOpus())
 
 	var costDepletionStr string
 	var costStyle lipgloss.Style
@@ -9,6 +9,7 @@ import (
 	"github.com/sammcj/ccu/internal/analysis"
 	"github.com/sammcj/ccu/internal/models"
 	"github.com/sammcj/ccu/internal/oauth"
+	"github.com/sammcj/ccu/internal/pricing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -30,9 +31,12 @@ func TestFormatModelNameSimple(t *testing.T) {
 		{"claude-sonnet-4", "Sonnet 4"},
 		{"opus-4-5", "Opus 4.5"},
 		{"sonnet", "Sonnet"},
-		{"claude-3-opus", "Opus"},
-		{"claude-3-5-sonnet", "Sonnet"},
-		{"claude-3-haiku", "Haiku"},
+
+		// Legacy version-first IDs use their registry display name
+		{"claude-3-opus", "Opus 3"},
+		{"claude-3-5-sonnet", "Sonnet 3.5"},
+		{"claude-3-haiku", "Haiku 3"},
+		{"claude-3-5-sonnet-20241022", "Sonnet 3.5"},
 
 		// Fable / Mythos
 		{"claude-fable-5", "Fable 5"},
@@ -279,6 +283,34 @@ func TestRenderSessionCacheHitRateNoActivity(t *testing.T) {
 	assert.Empty(t, renderSessionCacheHitRate(&models.SessionBlock{}, barWidth))
 }
 
+func TestRenderUnpricedWarning(t *testing.T) {
+	assert.Empty(t, renderUnpricedWarning(nil))
+
+	unpriced := []pricing.UnpricedModel{
+		{Model: "claude-zenith-1", FallbackCost: 2.5, Requests: 1200},
+		{Model: "claude-nimbus-2", FallbackCost: 1, Requests: 3},
+		{Model: "claude-zephyr-1", FallbackCost: 0.5, Requests: 2},
+		{Model: "claude-cirrus-1", FallbackCost: 0.25, Requests: 1},
+		{Model: "claude-stratus-1", FallbackCost: 0.1, Requests: 1},
+	}
+	got := renderUnpricedWarning(unpriced)
+	assert.Contains(t, got, "Unpriced models costed at Sonnet rates")
+	assert.Contains(t, got, "claude-zenith-1 ($2.50, 1,200 requests)")
+	assert.Contains(t, got, "claude-zephyr-1")
+	assert.NotContains(t, got, "claude-cirrus-1")
+	assert.Contains(t, got, "and 2 more")
+}
+
+func TestRenderUnpricedWarningIgnoresOtherProviders(t *testing.T) {
+	external := []pricing.UnpricedModel{{Model: "gpt-4o", Provider: "openai", Requests: 5}}
+	assert.Empty(t, renderUnpricedWarning(external))
+
+	mixed := append(external, pricing.UnpricedModel{Model: "claude-zenith-1", Provider: models.ProviderAnthropic, Requests: 1})
+	got := renderUnpricedWarning(mixed)
+	assert.Contains(t, got, "claude-zenith-1")
+	assert.NotContains(t, got, "gpt-4o")
+}
+
 func TestWeeklySectionShownWithoutPerModelFields(t *testing.T) {
 	// The API always returns the combined seven_day field but only includes
 	// seven_day_sonnet/seven_day_opus above a usage threshold. The weekly
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"cmp"
 	"fmt"
 	"maps"
 	"slices"
@@ -12,17 +13,42 @@ import (
 	"github.com/sammcj/ccu/internal/pricing"
 )
 
-// reportRowFormat is the shared 9-column layout for report table rows.
-const reportRowFormat = "%-*s  %-30s  %12s  %12s  %16s  %18s  %10s  %16s  %12s\n"
+// reportRowFormat is the shared 10-column layout for report table rows.
+const reportRowFormat = "%-*s  %-30s  %12s  %12s  %14s  %14s  %18s  %10s  %16s  %12s\n"
 
-// ModelStats holds token statistics for a single model
+// reportWidth is the width of the separator rules, matching reportRowFormat
+// with the widest (12-character) period column.
+const reportWidth = 168
+
+// ModelStats holds token statistics for a single model.
+// CacheCreationTokens is the 5-minute plus 1-hour cache write total.
 type ModelStats struct {
-	InputTokens         int
-	OutputTokens        int
-	CacheCreationTokens int
-	CacheReadTokens     int
-	TotalTokens         int
-	TotalCost           float64
+	InputTokens           int
+	OutputTokens          int
+	CacheCreationTokens   int
+	CacheCreation5mTokens int
+	CacheCreation1hTokens int
+	CacheReadTokens       int
+	TotalTokens           int
+	TotalCost             float64
+	Requests              int
+	LongContextRequests   int     // requests billed at a long-context premium tier
+	LongContextCost       float64 // cost of those requests, included in TotalCost
+}
+
+// add accumulates another set of stats into ms
+func (ms *ModelStats) add(other *ModelStats) {
+	ms.InputTokens += other.InputTokens
+	ms.OutputTokens += other.OutputTokens
+	ms.CacheCreationTokens += other.CacheCreationTokens
+	ms.CacheCreation5mTokens += other.CacheCreation5mTokens
+	ms.CacheCreation1hTokens += other.CacheCreation1hTokens
+	ms.CacheReadTokens += other.CacheReadTokens
+	ms.TotalTokens += other.TotalTokens
+	ms.TotalCost += other.TotalCost
+	ms.Requests += other.Requests
+	ms.LongContextRequests += other.LongContextRequests
+	ms.LongContextCost += other.LongContextCost
 }
 
 // ReportStats holds aggregated statistics for a time period
@@ -53,12 +79,13 @@ func generateReport(entries []models.UsageEntry, period, periodType string, time
 		return "No usage data found.\n"
 	}
 
-	stats := aggregateForReport(entries, period, timezone)
-	return renderReport(stats, periodType, timezone)
+	stats := AggregateForReport(entries, period, timezone)
+	return renderReport(stats, periodType, timezone, pricing.UnpricedModels(entries))
 }
 
-// aggregateForReport aggregates entries by period (daily or monthly) and by model
-func aggregateForReport(entries []models.UsageEntry, period string, timezone *time.Location) []ReportStats {
+// AggregateForReport aggregates entries by period ("daily", "weekly" or
+// "monthly") and by model. Periods are sorted oldest first.
+func AggregateForReport(entries []models.UsageEntry, period string, timezone *time.Location) []ReportStats {
 	statsMap := make(map[string]*ReportStats)
 
 	for _, entry := range entries {
@@ -103,10 +130,18 @@ func aggregateForReport(entries []models.UsageEntry, period string, timezone *ti
 
 		ms.InputTokens += entry.InputTokens
 		ms.OutputTokens += entry.OutputTokens
+		cacheWrite5m, cacheWrite1h := entry.CacheCreationSplit()
 		ms.CacheCreationTokens += entry.CacheCreationTokens
+		ms.CacheCreation5mTokens += cacheWrite5m
+		ms.CacheCreation1hTokens += cacheWrite1h
 		ms.CacheReadTokens += entry.CacheReadTokens
 		ms.TotalTokens += entry.TotalTokens()
 		ms.TotalCost += entry.CostUSD
+		ms.Requests++
+		if entry.LongContext {
+			ms.LongContextRequests++
+			ms.LongContextCost += entry.CostUSD
+		}
 
 		s.TotalTokens += entry.TotalTokens()
 	}
@@ -124,8 +159,9 @@ func aggregateForReport(entries []models.UsageEntry, period string, timezone *ti
 	return result
 }
 
-// renderReport renders the report as a formatted table
-func renderReport(stats []ReportStats, periodType string, timezone *time.Location) string {
+// renderReport renders the report as a formatted table. unpriced lists models
+// costed at fallback rates, footnoted so their share of the totals is visible.
+func renderReport(stats []ReportStats, periodType string, timezone *time.Location, unpriced []pricing.UnpricedModel) string {
 	var sb strings.Builder
 
 	// Get timezone name
@@ -133,7 +169,7 @@ func renderReport(stats []ReportStats, periodType string, timezone *time.Locatio
 
 	// Header
 	fmt.Fprintf(&sb, "Claude Code Token Usage Report - %s (%s)\n", periodType, tzName)
-	sb.WriteString(strings.Repeat("─", 154) + "\n")
+	sb.WriteString(strings.Repeat("─", reportWidth) + "\n")
 
 	// Column headers - use wider model column for full names
 	var periodLabel string
@@ -150,12 +186,11 @@ func renderReport(stats []ReportStats, periodType string, timezone *time.Locatio
 		periodWidth = 12
 	}
 	fmt.Fprintf(&sb, reportRowFormat,
-		periodWidth, periodLabel, "Model", "Input", "Output", "Cache Create", "Cache Read", "CacheHit%", "Total Tokens", "Est. Cost")
-	sb.WriteString(strings.Repeat("─", 154) + "\n")
+		periodWidth, periodLabel, "Model", "Input", "Output", "Cache Write 5m", "Cache Write 1h", "Cache Read", "CacheHit%", "Total Tokens", "Est. Cost")
+	sb.WriteString(strings.Repeat("─", reportWidth) + "\n")
 
 	// Grand totals
-	var totalInput, totalOutput, totalCacheCreate, totalCacheRead, totalTokens int
-	var totalCost float64
+	var total ModelStats
 
 	// Track partial periods
 	hasPartialPeriod := false
@@ -200,6 +235,7 @@ func renderReport(stats []ReportStats, periodType string, timezone *time.Locatio
 		modelNames := getSortedModelNames(s.ModelStats)
 
 		// Print each model on its own row
+		var period ModelStats
 		for i, modelName := range modelNames {
 			ms := s.ModelStats[modelName]
 
@@ -209,58 +245,20 @@ func renderReport(stats []ReportStats, periodType string, timezone *time.Locatio
 				displayPeriod = periodStr
 			}
 
-			modelHitRate := analysis.CalculateCacheHitRate(ms.InputTokens, ms.CacheCreationTokens, ms.CacheReadTokens)
-			fmt.Fprintf(&sb, reportRowFormat,
-				periodWidth,
-				displayPeriod,
-				truncate(modelName, 30),
-				formatNumber(ms.InputTokens),
-				formatNumber(ms.OutputTokens),
-				formatNumber(ms.CacheCreationTokens),
-				formatNumber(ms.CacheReadTokens),
-				fmt.Sprintf("%.1f%%", modelHitRate),
-				formatNumber(ms.TotalTokens),
-				fmt.Sprintf("$%.2f", ms.TotalCost))
-
-			// Accumulate grand totals
-			totalInput += ms.InputTokens
-			totalOutput += ms.OutputTokens
-			totalCacheCreate += ms.CacheCreationTokens
-			totalCacheRead += ms.CacheReadTokens
-			totalTokens += ms.TotalTokens
-			totalCost += ms.TotalCost
+			writeReportRow(&sb, periodWidth, displayPeriod, truncate(modelName, 30), ms)
+
+			// Accumulate period and grand totals
+			period.add(ms)
+			total.add(ms)
 		}
 
 		// Add period subtotal if multiple models
 		if len(modelNames) > 1 {
-			var periodInput, periodOutput, periodCacheCreate, periodCacheRead, periodTokens int
-			var periodCost float64
-			for _, ms := range s.ModelStats {
-				periodInput += ms.InputTokens
-				periodOutput += ms.OutputTokens
-				periodCacheCreate += ms.CacheCreationTokens
-				periodCacheRead += ms.CacheReadTokens
-				periodTokens += ms.TotalTokens
-				periodCost += ms.TotalCost
-			}
-
 			subtotalLabel := "Subtotal"
 			if isPartial {
 				subtotalLabel = "Subtotal *"
 			}
-
-			periodHitRate := analysis.CalculateCacheHitRate(periodInput, periodCacheCreate, periodCacheRead)
-			fmt.Fprintf(&sb, reportRowFormat,
-				periodWidth,
-				"",
-				subtotalLabel,
-				formatNumber(periodInput),
-				formatNumber(periodOutput),
-				formatNumber(periodCacheCreate),
-				formatNumber(periodCacheRead),
-				fmt.Sprintf("%.1f%%", periodHitRate),
-				formatNumber(periodTokens),
-				fmt.Sprintf("$%.2f", periodCost))
+			writeReportRow(&sb, periodWidth, "", subtotalLabel, &period)
 		}
 
 		// Add blank line between periods for readability
@@ -268,29 +266,98 @@ func renderReport(stats []ReportStats, periodType string, timezone *time.Locatio
 	}
 
 	// Grand total row
-	sb.WriteString(strings.Repeat("─", 154) + "\n")
-	totalHitRate := analysis.CalculateCacheHitRate(totalInput, totalCacheCreate, totalCacheRead)
-	fmt.Fprintf(&sb, reportRowFormat,
-		periodWidth,
-		"TOTAL", "",
-		formatNumber(totalInput),
-		formatNumber(totalOutput),
-		formatNumber(totalCacheCreate),
-		formatNumber(totalCacheRead),
-		fmt.Sprintf("%.1f%%", totalHitRate),
-		formatNumber(totalTokens),
-		fmt.Sprintf("$%.2f", totalCost))
+	sb.WriteString(strings.Repeat("─", reportWidth) + "\n")
+	writeReportRow(&sb, periodWidth, "TOTAL", "", &total)
 
 	// Footer
 	sb.WriteString("\n")
 	if hasPartialPeriod {
 		sb.WriteString("* Partial period (current month/week/day)\n")
 	}
+	if total.LongContextRequests > 0 {
+		share := 0.0
+		if total.TotalCost > 0 {
+			share = total.LongContextCost / total.TotalCost * 100
+		}
+		fmt.Fprintf(&sb, "Long context: %s request(s) above 200k prompt tokens billed at premium rates, $%.2f (%.1f%% of cost)\n",
+			formatNumber(total.LongContextRequests), total.LongContextCost, share)
+	}
+	if external := externalSpend(stats); len(external) > 0 {
+		parts := make([]string, 0, len(external))
+		externalTotal := 0.0
+		for _, p := range external {
+			parts = append(parts, fmt.Sprintf("%s $%.2f", p.name, p.cost))
+			externalTotal += p.cost
+		}
+		fmt.Fprintf(&sb, "Providers: Anthropic plan usage $%.2f, external API spend $%.2f (%s)\n",
+			total.TotalCost-externalTotal, externalTotal, strings.Join(parts, ", "))
+	}
 	fmt.Fprintf(&sb, "Pricing: %s\n", pricing.GetPricingSource())
+	if len(unpriced) > 0 {
+		sb.WriteString("Unpriced models (not in the pricing table; Claude models costed at Sonnet rates, other providers at $0; add them with -pricing-file):\n")
+		for _, u := range unpriced {
+			fmt.Fprintf(&sb, "  %-30s  $%.2f  %s requests  %s tokens  %s to %s\n",
+				truncate(u.Model, 30), u.FallbackCost, formatNumber(u.Requests), formatNumber(u.Tokens),
+				u.FirstSeen.In(timezone).Format("2006-01-02"), u.LastSeen.In(timezone).Format("2006-01-02"))
+		}
+	}
 
 	return sb.String()
 }
 
+// providerCost is one provider's share of a report's cost
+type providerCost struct {
+	name string
+	cost float64
+}
+
+// externalSpend totals cost by provider for models not served by Anthropic,
+// highest first. Returns nil when all usage was Anthropic's.
+func externalSpend(stats []ReportStats) []providerCost {
+	byProvider := make(map[string]*providerCost)
+	for _, s := range stats {
+		for modelName, ms := range s.ModelStats {
+			provider := models.ProviderOf(modelName)
+			if provider.ID == models.ProviderAnthropic {
+				continue
+			}
+			if byProvider[provider.ID] == nil {
+				byProvider[provider.ID] = &providerCost{name: provider.Name}
+			}
+			byProvider[provider.ID].cost += ms.TotalCost
+		}
+	}
+
+	var result []providerCost
+	for _, p := range byProvider {
+		result = append(result, *p)
+	}
+	slices.SortFunc(result, func(a, b providerCost) int {
+		if c := cmp.Compare(b.cost, a.cost); c != 0 {
+			return c
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	return result
+}
+
+// writeReportRow renders one reportRowFormat row for the given stats
+func writeReportRow(sb *strings.Builder, periodWidth int, period, label string, ms *ModelStats) {
+	hitRate := analysis.CalculateCacheHitRate(ms.InputTokens, ms.CacheCreationTokens, ms.CacheReadTokens)
+	fmt.Fprintf(sb, reportRowFormat,
+		periodWidth,
+		period,
+		label,
+		formatNumber(ms.InputTokens),
+		formatNumber(ms.OutputTokens),
+		formatNumber(ms.CacheCreation5mTokens),
+		formatNumber(ms.CacheCreation1hTokens),
+		formatNumber(ms.CacheReadTokens),
+		fmt.Sprintf("%.1f%%", hitRate),
+		formatNumber(ms.TotalTokens),
+		fmt.Sprintf("$%.2f", ms.TotalCost))
+}
+
 // getSortedModelNames returns model names sorted alphabetically
 func getSortedModelNames(modelStats map[string]*ModelStats) []string {
 	return slices.Sorted(maps.Keys(modelStats))
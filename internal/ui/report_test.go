@@ -36,7 +36,7 @@ func TestAggregateForReport_Daily(t *testing.T) {
 		makeEntry(day3, "claude-sonnet-4", 50, 25, 50, 100, 0.005),
 	}
 
-	stats := aggregateForReport(entries, "daily", tz)
+	stats := AggregateForReport(entries, "daily", tz)
 
 	assert.Len(t, stats, 3, "should have 3 daily periods")
 
@@ -77,7 +77,7 @@ func TestAggregateForReport_Weekly(t *testing.T) {
 		makeEntry(week51Entry, "claude-sonnet-4", 200, 100, 300, 400, 0.02),
 	}
 
-	stats := aggregateForReport(entries, "weekly", tz)
+	stats := AggregateForReport(entries, "weekly", tz)
 
 	assert.Len(t, stats, 2, "should have 2 weekly periods")
 
@@ -107,7 +107,7 @@ func TestAggregateForReport_Monthly(t *testing.T) {
 		makeEntry(dec, "claude-sonnet-4", 200, 100, 300, 400, 0.02),
 	}
 
-	stats := aggregateForReport(entries, "monthly", tz)
+	stats := AggregateForReport(entries, "monthly", tz)
 
 	assert.Len(t, stats, 2, "should have 2 monthly periods")
 
@@ -134,7 +134,7 @@ func TestAggregateForReport_MultipleModels(t *testing.T) {
 		makeEntry(day.Add(2*time.Hour), "claude-haiku-4-5", 50, 25, 100, 150, 0.002),
 	}
 
-	stats := aggregateForReport(entries, "daily", tz)
+	stats := AggregateForReport(entries, "daily", tz)
 
 	assert.Len(t, stats, 1, "should have 1 daily period")
 	assert.Len(t, stats[0].ModelStats, 3, "should have 3 models in period")
@@ -168,7 +168,7 @@ func TestAggregateForReport_TotalTokenCalculation(t *testing.T) {
 		makeEntry(ts, "claude-sonnet-4", input, output, cacheCreate, cacheRead, 0.01),
 	}
 
-	stats := aggregateForReport(entries, "daily", tz)
+	stats := AggregateForReport(entries, "daily", tz)
 
 	assert.Len(t, stats, 1)
 	ms := stats[0].ModelStats["claude-sonnet-4"]
@@ -184,7 +184,7 @@ func TestAggregateForReport_EmptyEntries(t *testing.T) {
 	tz := time.UTC
 	entries := []models.UsageEntry{}
 
-	stats := aggregateForReport(entries, "daily", tz)
+	stats := AggregateForReport(entries, "daily", tz)
 
 	assert.Len(t, stats, 0, "empty entries should return empty stats")
 }
@@ -296,6 +296,81 @@ func TestRenderReport_CacheHitRateColumn(t *testing.T) {
 	assert.Contains(t, report, "80.0%", "row should show computed cache hit rate")
 }
 
+func TestRenderReport_CacheWriteSplit(t *testing.T) {
+	tz := time.UTC
+	day := time.Date(2025, 12, 15, 10, 0, 0, 0, tz)
+
+	withBreakdown := makeEntry(day, "claude-sonnet-4", 100, 50, 3000, 0, 0.10)
+	withBreakdown.CacheCreation5mTokens = 1000
+	withBreakdown.CacheCreation1hTokens = 2000
+	// Older transcript with only the aggregate: counted as 5-minute writes
+	legacy := makeEntry(day, "claude-sonnet-4", 100, 50, 500, 0, 0.10)
+
+	stats := AggregateForReport([]models.UsageEntry{withBreakdown, legacy}, "daily", tz)
+	assert.Len(t, stats, 1)
+	ms := stats[0].ModelStats["claude-sonnet-4"]
+	assert.Equal(t, 1500, ms.CacheCreation5mTokens)
+	assert.Equal(t, 2000, ms.CacheCreation1hTokens)
+	assert.Equal(t, 3500, ms.CacheCreationTokens)
+
+	report := GenerateDailyReport([]models.UsageEntry{withBreakdown, legacy}, tz)
+	assert.Contains(t, report, "Cache Write 5m")
+	assert.Contains(t, report, "Cache Write 1h")
+	assert.Contains(t, report, "1,500")
+	assert.Contains(t, report, "2,000")
+}
+
+func TestRenderReport_LongContextFooter(t *testing.T) {
+	tz := time.UTC
+	day := time.Date(2025, 12, 15, 10, 0, 0, 0, tz)
+
+	long := makeEntry(day, "claude-sonnet-4-6", 250_000, 50, 0, 0, 1.50)
+	long.LongContext = true
+	standard := makeEntry(day, "claude-sonnet-4-6", 100, 50, 0, 0, 0.50)
+
+	stats := AggregateForReport([]models.UsageEntry{long, standard}, "daily", tz)
+	ms := stats[0].ModelStats["claude-sonnet-4-6"]
+	assert.Equal(t, 1, ms.LongContextRequests)
+	assert.InDelta(t, 1.50, ms.LongContextCost, 1e-9)
+
+	report := GenerateDailyReport([]models.UsageEntry{long, standard}, tz)
+	assert.Contains(t, report, "Long context: 1 request(s) above 200k prompt tokens billed at premium rates, $1.50 (75.0% of cost)")
+
+	// No footer when nothing crossed the threshold
+	report = GenerateDailyReport([]models.UsageEntry{standard}, tz)
+	assert.NotContains(t, report, "Long context")
+}
+
+func TestRenderReport_UnpricedFootnote(t *testing.T) {
+	tz := time.UTC
+	day := time.Date(2025, 12, 15, 10, 0, 0, 0, tz)
+
+	unknown := makeEntry(day, "claude-zenith-1", 1000, 500, 0, 0, 0.75)
+	unknown.Unpriced = true
+	known := makeEntry(day, "claude-sonnet-4-6", 100, 50, 0, 0, 0.50)
+
+	report := GenerateDailyReport([]models.UsageEntry{unknown, known}, tz)
+	assert.Contains(t, report, "Unpriced models (not in the pricing table; Claude models costed at Sonnet rates, other providers at $0")
+	assert.Regexp(t, `claude-zenith-1\s+\$0\.75\s+1 requests\s+1,500 tokens\s+2025-12-15 to 2025-12-15`, report)
+
+	report = GenerateDailyReport([]models.UsageEntry{known}, tz)
+	assert.NotContains(t, report, "Unpriced")
+}
+
+func TestRenderReport_ProviderSplit(t *testing.T) {
+	tz := time.UTC
+	day := time.Date(2025, 12, 15, 10, 0, 0, 0, tz)
+	claude := makeEntry(day, "claude-sonnet-4-6", 100, 50, 0, 0, 3.00)
+	gpt := makeEntry(day, "gpt-4o", 100, 50, 0, 0, 1.25)
+	gemini := makeEntry(day, "gemini-2.5-pro", 100, 50, 0, 0, 0.50)
+
+	report := GenerateDailyReport([]models.UsageEntry{claude, gpt, gemini}, tz)
+	assert.Contains(t, report, "Providers: Anthropic plan usage $3.00, external API spend $1.75 (OpenAI $1.25, Google $0.50)")
+
+	report = GenerateDailyReport([]models.UsageEntry{claude}, tz)
+	assert.NotContains(t, report, "Providers:")
+}
+
 func TestAggregateForReport_SortsChronologically(t *testing.T) {
 	tz := time.UTC
 
@@ -310,7 +385,7 @@ func TestAggregateForReport_SortsChronologically(t *testing.T) {
 		makeEntry(nov, "claude-sonnet-4", 100, 50, 200, 300, 0.01),
 	}
 
-	stats := aggregateForReport(entries, "monthly", tz)
+	stats := AggregateForReport(entries, "monthly", tz)
 
 	assert.Len(t, stats, 3)
 	// Should be sorted chronologically: Oct, Nov, Dec
@@ -1,9 +1,8 @@
 package ui
 
 import (
-	"strings"
-
 	"github.com/charmbracelet/lipgloss"
+	"github.com/sammcj/ccu/internal/models"
 )
 
 // ============================================================================
@@ -28,15 +27,18 @@ var (
 
 // Model Name Colours
 // Used for colour-coding model names in session distribution and per-model weekly bars.
-// Opus already occupies the pale end of the pink range, so Fable takes a saturated
-// hot pink to stay distinguishable from it at a glance.
-var (
-	ColorSonnet = lipgloss.Color("#0088FF") // Blue - Used for: "Sonnet 4.5", "Sonnet 4", "Sonnet 3.5" model names
-	ColorOpus   = lipgloss.Color("#FFB3D9") // Mellow pink - Used for: "Opus 4.5", "Opus 4", "Opus 3" model names
-	ColorHaiku  = lipgloss.Color("#9B72CF") // Violet - Used for: "Haiku 4.5", "Haiku 3.5", "Haiku 3" model names
-	ColorFable  = lipgloss.Color("#FF4FA3") // Hot pink - Used for: "Fable" model names
-	ColorMythos = lipgloss.Color("#E5484D") // Red - Used for: "Mythos" model names
+// The hex values live with each family in internal/models/registry.json, so a user
+// models file can recolour a family or add one. They are looked up on each call rather
+// than at init, which runs before the models file is loaded. Opus already occupies the
+// pale end of the pink range, so Fable takes a saturated hot pink to stay
+// distinguishable from it.
+func ColorSonnet() lipgloss.Color { return familyColour("sonnet") } // Blue - Used for: "Sonnet 4.5", "Sonnet 4", "Sonnet 3.5" model names
+func ColorOpus() lipgloss.Color   { return familyColour("opus") }   // Mellow pink - Used for: "Opus 4.5", "Opus 4", "Opus 3" model names
+func ColorHaiku() lipgloss.Color  { return familyColour("haiku") }  // Violet - Used for: "Haiku 4.5", "Haiku 3.5", "Haiku 3" model names
+func ColorFable() lipgloss.Color  { return familyColour("fable") }  // Hot pink - Used for: "Fable" model names
+func ColorMythos() lipgloss.Color { return familyColour("mythos") } // Red - Used for: "Mythos" model names
 
+var (
 	// ColorModelUnknown is the fallback for a model name we don't recognise —
 	// the API can introduce a new model at any time and we still need to label it.
 	ColorModelUnknown = lipgloss.Color("#D4D4E0") // Off-white grey
@@ -261,18 +263,18 @@ func GetTimeRemainingStyle(percent float64) lipgloss.Style {
 // GetModelColour returns the colour for a model name, falling back to a neutral
 // off-white for models CCU doesn't know about yet.
 func GetModelColour(modelName string) lipgloss.Color {
-	lowerModel := strings.ToLower(modelName)
-	switch {
-	case strings.Contains(lowerModel, "fable"):
-		return ColorFable
-	case strings.Contains(lowerModel, "mythos"):
-		return ColorMythos
-	case strings.Contains(lowerModel, "opus"):
-		return ColorOpus
-	case strings.Contains(lowerModel, "sonnet"):
-		return ColorSonnet
-	case strings.Contains(lowerModel, "haiku"):
-		return ColorHaiku
+	if family, ok := models.FamilyOf(modelName); ok && family.Colour != "" {
+		return lipgloss.Color(family.Colour)
+	}
+	return ColorModelUnknown
+}
+
+// familyColour returns a registry family's colour, for the named colour vars
+func familyColour(id string) lipgloss.Color {
+	for _, family := range models.Families() {
+		if family.ID == id && family.Colour != "" {
+			return lipgloss.Color(family.Colour)
+		}
 	}
 	return ColorModelUnknown
 }
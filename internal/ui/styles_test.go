@@ -1,9 +1,13 @@
 package ui
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/sammcj/ccu/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetModelColour(t *testing.T) {
@@ -12,12 +16,12 @@ func TestGetModelColour(t *testing.T) {
 		model string
 		want  string
 	}{
-		{name: "fable", model: "Fable", want: string(ColorFable)},
-		{name: "mythos", model: "Mythos", want: string(ColorMythos)},
-		{name: "opus", model: "claude-opus-4-20250514", want: string(ColorOpus)},
-		{name: "sonnet", model: "claude-sonnet-4", want: string(ColorSonnet)},
-		{name: "haiku", model: "Haiku 4.5", want: string(ColorHaiku)},
-		{name: "case insensitive", model: "FABLE", want: string(ColorFable)},
+		{name: "fable", model: "Fable", want: string(ColorFable())},
+		{name: "mythos", model: "Mythos", want: string(ColorMythos())},
+		{name: "opus", model: "claude-opus-4-20250514", want: string(ColorOpus())},
+		{name: "sonnet", model: "claude-sonnet-4", want: string(ColorSonnet())},
+		{name: "haiku", model: "Haiku 4.5", want: string(ColorHaiku())},
+		{name: "case insensitive", model: "FABLE", want: string(ColorFable())},
 		{name: "unknown model falls back", model: "Nimbus", want: string(ColorModelUnknown)},
 		{name: "empty falls back", model: "", want: string(ColorModelUnknown)},
 	}
@@ -33,11 +37,11 @@ func TestGetModelColour(t *testing.T) {
 // become indistinguishable.
 func TestModelColoursAreDistinct(t *testing.T) {
 	colours := map[string]string{
-		"sonnet":  string(ColorSonnet),
-		"opus":    string(ColorOpus),
-		"haiku":   string(ColorHaiku),
-		"fable":   string(ColorFable),
-		"mythos":  string(ColorMythos),
+		"sonnet":  string(ColorSonnet()),
+		"opus":    string(ColorOpus()),
+		"haiku":   string(ColorHaiku()),
+		"fable":   string(ColorFable()),
+		"mythos":  string(ColorMythos()),
 		"unknown": string(ColorModelUnknown),
 	}
 
@@ -49,3 +53,24 @@ func TestModelColoursAreDistinct(t *testing.T) {
 		seen[colour] = model
 	}
 }
+
+// A models file is loaded after package init, so the named colours must
+// follow it rather than keep the built-in hex values
+func TestModelColoursFollowModelsFile(t *testing.T) {
+	dir := t.TempDir()
+	load := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		require.NoError(t, models.LoadRegistryFile(path))
+	}
+	builtin := ColorSonnet()
+	t.Cleanup(func() { load("empty.json", `{}`) })
+
+	load("models.json", `{"families": [{"id": "sonnet", "name": "Sonnet", "colour": "#123456"}]}`)
+	assert.Equal(t, "#123456", string(ColorSonnet()))
+	assert.Equal(t, ColorSonnet(), GetModelColour("claude-sonnet-4"))
+
+	load("empty.json", `{}`)
+	assert.Equal(t, builtin, ColorSonnet())
+}
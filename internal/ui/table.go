@@ -141,24 +141,11 @@ func formatModels(models map[string]bool) string {
 	return strings.Join(names, "/")
 }
 
-// modelFamily maps a normalised model name to its display family, matching the
-// substring approach used by GetModelColour. Returns "" for unrecognised names.
+// modelFamily maps a normalised model name to its registry family's display
+// name, as GetModelColour does for colour. Returns "" for unrecognised names.
 func modelFamily(model string) string {
-	lower := strings.ToLower(model)
-	switch {
-	case strings.Contains(lower, "fable"):
-		return "Fable"
-	case strings.Contains(lower, "mythos"):
-		return "Mythos"
-	case strings.Contains(lower, "opus"):
-		return "Opus"
-	case strings.Contains(lower, "sonnet"):
-		return "Sonnet"
-	case strings.Contains(lower, "haiku"):
-		return "Haiku"
-	default:
-		return ""
-	}
+	family, _ := models.FamilyOf(model)
+	return family.Name
 }
 
 // formatNumber formats large numbers with thousands separators.